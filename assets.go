@@ -0,0 +1,86 @@
+package tensile
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultAssetParallelism is the assets of a page fetched at once when
+// Config.AssetParallelism is 0, as browsers open six connections per host
+const DefaultAssetParallelism = 6
+
+// Bytes of an HTML page searched for assets, at most
+const maxPageBody = 10 << 20
+
+// Whether a response is an HTML page
+func isHTML(h http.Header) bool {
+	mt, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mt == "text/html" || mt == "application/xhtml+xml"
+}
+
+// Fetch the assets of the page r answered, from the origin of page, over t.
+// The page load time runs from the start of the request for the page until
+// the last asset is read.
+func (a *attack) loadAssets(t http.RoundTripper, page *http.Request, r *response) {
+	var urls []string
+	for _, s := range Assets(r.page.Bytes(), page.URL) {
+		if u, err := url.Parse(s); err == nil && u.Scheme == page.URL.Scheme && u.Host == page.URL.Host {
+			urls = append(urls, s)
+		}
+	}
+	r.page = nil
+	n := a.cfg.AssetParallelism
+	if n == 0 {
+		n = DefaultAssetParallelism
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, n)
+	)
+	for _, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(u string) {
+			defer wg.Done()
+			size, err := a.fetchAsset(t, page, u)
+			<-sem
+			mu.Lock()
+			defer mu.Unlock()
+			r.size += size
+			if err != nil {
+				r.fail(CheckAsset, err)
+			}
+		}(u)
+	}
+	wg.Wait()
+	r.pageLoad = time.Since(r.start)
+}
+
+// Fetch an asset of page, returning its size
+func (a *attack) fetchAsset(t http.RoundTripper, page *http.Request, u string) (int64, error) {
+	req, err := http.NewRequestWithContext(page.Context(), http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", page.Header.Get("User-Agent"))
+	req.Header.Set("Referer", page.URL.String())
+	resp, err := t.RoundTrip(req)
+	if err != nil {
+		return 0, fmt.Errorf("asset %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(io.Discard, resp.Body)
+	switch {
+	case err != nil:
+		return n, fmt.Errorf("asset %s: %w", u, err)
+	case resp.StatusCode >= 400:
+		return n, fmt.Errorf("asset %s: %s", u, resp.Status)
+	}
+	return n, nil
+}
@@ -0,0 +1,31 @@
+package tensile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPageAssets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><script src="/app.js"></script><img src="/logo.png"></html>`))
+			return
+		}
+		w.Write([]byte("asset"))
+	}))
+	defer srv.Close()
+	a := NewAttacker(WithURL(srv.URL+"/"), WithRequests(2), WithConcurrency(1), WithPageAssets(2))
+	if c := a.Config(); !c.PageAssets || c.AssetParallelism != 2 {
+		t.Fatalf("WithPageAssets set %v, %d", c.PageAssets, c.AssetParallelism)
+	}
+	res, err := a.Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Errors != 0 || res.PageLoad == nil {
+		t.Fatalf("page load %v, %d errors", res.PageLoad, res.Errors)
+	}
+}
@@ -0,0 +1,494 @@
+package tensile
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Attacker runs attacks. An Attacker has no shared state between attacks, so
+// any number of attacks may run at once, from one or many Attackers. Only
+// Progress sums the attacks running from an Attacker.
+type Attacker struct {
+	cfg Config
+
+	mu      sync.Mutex
+	running map[*attack]bool
+}
+
+// Option configures an Attacker
+type Option func(*Attacker)
+
+// NewAttacker returns an Attacker configured by opts. The defaults are 50
+// requests to http://localhost/, 5 at a time, stopping at the first error.
+func NewAttacker(opts ...Option) *Attacker {
+	a := &Attacker{cfg: Config{
+		URL:        "http://localhost/",
+		Requests:   50,
+		Concurrent: 5,
+		MaxErrors:  1,
+	}}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// WithConfig replaces the whole configuration
+func WithConfig(cfg Config) Option {
+	return func(a *Attacker) { a.cfg = cfg }
+}
+
+// WithURL sets the target URL
+func WithURL(u string) Option {
+	return func(a *Attacker) { a.cfg.URL = u }
+}
+
+// WithTargets sends requests to each of targets in turn
+func WithTargets(targets ...Target) Option {
+	return func(a *Attacker) { a.cfg.Targets = targets }
+}
+
+// WithRequests sets the total number of requests
+func WithRequests(n int) Option {
+	return func(a *Attacker) { a.cfg.Requests = n }
+}
+
+// WithDuration stops sending requests after d. With a duration, requests may
+// be 0 for no limit.
+func WithDuration(d time.Duration) Option {
+	return func(a *Attacker) { a.cfg.Duration = d }
+}
+
+// WithMaxDuration caps the whole run at d, cancelling requests still in
+// flight shortly after
+func WithMaxDuration(d time.Duration) Option {
+	return func(a *Attacker) { a.cfg.MaxDuration = d }
+}
+
+// WithDrainTimeout cancels requests still in flight d after the end of
+// Duration or MaxDuration, or at once if d is negative
+func WithDrainTimeout(d time.Duration) Option {
+	return func(a *Attacker) { a.cfg.DrainTimeout = d }
+}
+
+// WithRate sends requests at r per second, limited by the concurrency
+func WithRate(r float64) Option {
+	return func(a *Attacker) { a.cfg.Rate = r }
+}
+
+// WithPattern varies the rate over the attack with p
+func WithPattern(p Pattern) Option {
+	return func(a *Attacker) { a.cfg.Pattern = p }
+}
+
+// WithPhases summarises results separately for each phase
+func WithPhases(p ...Phase) Option {
+	return func(a *Attacker) { a.cfg.Phases = p }
+}
+
+// WithBurst sends requests in bursts of n every interval
+func WithBurst(n int, interval time.Duration) Option {
+	return func(a *Attacker) { a.cfg.Burst, a.cfg.BurstInterval = n, interval }
+}
+
+// WithConcurrency sets the maximum concurrent requests
+func WithConcurrency(n int) Option {
+	return func(a *Attacker) { a.cfg.Concurrent = n }
+}
+
+// WithMaxErrors sets the maximum errors before stopping, -1 for unlimited
+func WithMaxErrors(n int) Option {
+	return func(a *Attacker) { a.cfg.MaxErrors = n }
+}
+
+// WithTags sets the metadata recorded in the results
+func WithTags(t Tags) Option {
+	return func(a *Attacker) { a.cfg.Tags = t }
+}
+
+// WithLogger sets the logger for diagnostics
+func WithLogger(l *slog.Logger) Option {
+	return func(a *Attacker) { a.cfg.Logger = l }
+}
+
+// WithDebug dumps the headers of the first n exchanges to w, or stderr if nil,
+// including the bodies if bodies is set
+func WithDebug(n int, bodies bool, w io.Writer) Option {
+	return func(a *Attacker) {
+		a.cfg.Debug, a.cfg.DebugBodies, a.cfg.DebugOutput = n, bodies, w
+	}
+}
+
+// WithSaveErrors saves up to max failed exchanges to files in dir
+func WithSaveErrors(dir string, max int) Option {
+	return func(a *Attacker) { a.cfg.SaveErrors, a.cfg.SaveErrorsMax = dir, max }
+}
+
+// WithRetries retries transient failures up to n times, with exponential
+// backoff from base
+func WithRetries(n int, base time.Duration) Option {
+	return func(a *Attacker) { a.cfg.Retries, a.cfg.RetryBackoff = n, base }
+}
+
+// WithHedge sends a duplicate of any request that hasn't answered within d,
+// using whichever answers first
+func WithHedge(d time.Duration) Option {
+	return func(a *Attacker) { a.cfg.Hedge = d }
+}
+
+// WithSlowLog logs the details of requests taking at least d
+func WithSlowLog(d time.Duration) Option {
+	return func(a *Attacker) { a.cfg.SlowLog = d }
+}
+
+// WithExpectSHA256 fails responses whose body doesn't have the SHA-256 hash
+// sum
+func WithExpectSHA256(sum []byte) Option {
+	return func(a *Attacker) { a.cfg.ExpectSHA256 = sum }
+}
+
+// WithHeaderChecks fails responses whose headers don't pass every check
+func WithHeaderChecks(checks ...HeaderCheck) Option {
+	return func(a *Attacker) { a.cfg.HeaderChecks = checks }
+}
+
+// WithAuditHeaders counts the values of the headers over all responses
+func WithAuditHeaders(names ...string) Option {
+	return func(a *Attacker) { a.cfg.AuditHeaders = names }
+}
+
+// WithCompress requests compressed responses with the encodings, gzip or
+// deflate, decompressing bodies up to max bytes
+func WithCompress(max int64, encodings ...string) Option {
+	return func(a *Attacker) { a.cfg.Compress, a.cfg.MaxDecoded = encodings, max }
+}
+
+// WithCacheBust adds a random query parameter to every request to bypass
+// caches
+func WithCacheBust() Option {
+	return func(a *Attacker) { a.cfg.CacheBust = true }
+}
+
+// WithMethod sets the method of every request
+func WithMethod(m string) Option {
+	return func(a *Attacker) { a.cfg.Method = m }
+}
+
+// WithBody sends b with every request
+func WithBody(b Body) Option {
+	return func(a *Attacker) { a.cfg.Body = b }
+}
+
+// WithChunked sends the body chunked, in chunks of at most size bytes if not
+// 0, with delay between them
+func WithChunked(size int, delay time.Duration) Option {
+	return func(a *Attacker) { a.cfg.Chunked, a.cfg.ChunkSize, a.cfg.ChunkDelay = true, size, delay }
+}
+
+// WithExpectContinue sends requests with a body with Expect: 100-continue,
+// sending the body after timeout if the server hasn't answered
+func WithExpectContinue(timeout time.Duration) Option {
+	return func(a *Attacker) { a.cfg.ExpectContinue = timeout }
+}
+
+// WithRange requests the range b in every request
+func WithRange(b ByteRange) Option {
+	return func(a *Attacker) { a.cfg.Range = &b }
+}
+
+// WithGRPC makes every request a unary gRPC call of the method at the path
+// of the URL, with the request message msg, encoded as protobuf
+func WithGRPC(msg []byte) Option {
+	return func(a *Attacker) {
+		a.cfg.GRPC = true
+		a.cfg.Body = GRPCMessage(msg)
+	}
+}
+
+// WithGraphQL fails responses with GraphQL errors, for requests with a
+// GraphQLBody
+func WithGraphQL() Option {
+	return func(a *Attacker) { a.cfg.GraphQL = true }
+}
+
+// WithLongPoll makes requests long polls, each of the concurrent virtual
+// users polling again as soon as its last poll is answered
+func WithLongPoll() Option {
+	return func(a *Attacker) { a.cfg.LongPoll = true }
+}
+
+// WithPool sets the limits of the connection pool, see Config
+func WithPool(maxIdle, maxIdlePerHost, maxPerHost int) Option {
+	return func(a *Attacker) {
+		a.cfg.MaxIdleConns = maxIdle
+		a.cfg.MaxIdleConnsPerHost = maxIdlePerHost
+		a.cfg.MaxConnsPerHost = maxPerHost
+	}
+}
+
+// WithTransportShards spreads the workers over n connection pools, see
+// Config.Shards
+func WithTransportShards(n int) Option {
+	return func(a *Attacker) { a.cfg.TransportShards = n }
+}
+
+// WithPrewarm opens the keep-alive connections before the attack starts
+func WithPrewarm() Option {
+	return func(a *Attacker) { a.cfg.Prewarm = true }
+}
+
+// WithLocalAddrs makes connections from each of ips in turn
+func WithLocalAddrs(ips ...net.IP) Option {
+	return func(a *Attacker) { a.cfg.LocalAddrs = ips }
+}
+
+// WithNetwork only makes connections over network, tcp4 or tcp6
+func WithNetwork(network string) Option {
+	return func(a *Attacker) { a.cfg.Network = network }
+}
+
+// WithDNSOnce resolves each host once, rather than for every connection
+func WithDNSOnce() Option {
+	return func(a *Attacker) { a.cfg.DNSOnce = true }
+}
+
+// WithDNSServer resolves hosts with the DNS server at addr, a host:port
+func WithDNSServer(addr string) Option {
+	return func(a *Attacker) { a.cfg.DNSServer = addr }
+}
+
+// WithHosts connects to the given addresses of hosts, by lower case name,
+// rather than resolving them
+func WithHosts(hosts map[string][]net.IP) Option {
+	return func(a *Attacker) { a.cfg.Hosts = hosts }
+}
+
+// WithSpreadAddrs spreads connections evenly over the addresses of hosts,
+// breaking the results down by backend address
+func WithSpreadAddrs() Option {
+	return func(a *Attacker) { a.cfg.SpreadAddrs = true }
+}
+
+// WithByBackend breaks the results down by backend address
+func WithByBackend() Option {
+	return func(a *Attacker) { a.cfg.ByBackend = true }
+}
+
+// WithTLSResume resumes TLS sessions on new connections
+func WithTLSResume() Option {
+	return func(a *Attacker) { a.cfg.TLSResume = true }
+}
+
+// WithMethods chooses the method of each request from methods by weight
+func WithMethods(methods ...MethodWeight) Option {
+	return func(a *Attacker) { a.cfg.Methods = methods }
+}
+
+// WithBreaker pauses dispatching for cooldown whenever the error rate over
+// window reaches errorRate
+func WithBreaker(errorRate float64, window, cooldown time.Duration) Option {
+	return func(a *Attacker) { a.cfg.Breaker = &Breaker{errorRate, window, cooldown} }
+}
+
+// WithPortRange makes connections from local ports min to max in turn
+func WithPortRange(min, max int) Option {
+	return func(a *Attacker) { a.cfg.PortMin, a.cfg.PortMax = min, max }
+}
+
+// WithSocketOptions sets the socket options of connections, see Config
+func WithSocketOptions(nagle bool, linger time.Duration, sendBuffer, recvBuffer int) Option {
+	return func(a *Attacker) {
+		a.cfg.Nagle, a.cfg.Linger = nagle, linger
+		a.cfg.SendBuffer, a.cfg.RecvBuffer = sendBuffer, recvBuffer
+	}
+}
+
+// WithRandomRange requests a random range of n bytes in every request
+func WithRandomRange(n int64) Option {
+	return func(a *Attacker) { a.cfg.RandomRange = n }
+}
+
+// WithRevalidate makes requests conditional on the validators of the latest
+// full response from their target
+func WithRevalidate() Option {
+	return func(a *Attacker) { a.cfg.Revalidate = true }
+}
+
+// WithBounded bounds memory use however long the attack runs, leaving out
+// the timeline
+func WithBounded() Option {
+	return func(a *Attacker) { a.cfg.Bounded = true }
+}
+
+// WithUserAgents sends requests with each of uas in turn, such as
+// BrowserUserAgents
+func WithUserAgents(uas ...string) Option {
+	return func(a *Attacker) { a.cfg.UserAgents = uas }
+}
+
+// WithFuzz sends a random value of up to maxLen bytes, or DefaultFuzzMaxLen
+// if 0, for each of headers and the query parameters params
+func WithFuzz(headers, params []string, maxLen int) Option {
+	return func(a *Attacker) { a.cfg.FuzzHeaders, a.cfg.FuzzParams, a.cfg.FuzzMaxLen = headers, params, maxLen }
+}
+
+// WithHeaderSize pads requests with headers of n bytes in all
+func WithHeaderSize(n int) Option {
+	return func(a *Attacker) { a.cfg.HeaderSize = n }
+}
+
+// WithMaxBandwidth reads each response body at up to n bytes a second
+func WithMaxBandwidth(n int64) Option {
+	return func(a *Attacker) { a.cfg.MaxBandwidth = n }
+}
+
+// WithStream times the chunks of response bodies, counting gaps of at least
+// stallThreshold, or DefaultStallThreshold if 0, as stalls
+func WithStream(stallThreshold time.Duration) Option {
+	return func(a *Attacker) { a.cfg.Stream, a.cfg.StallThreshold = true, stallThreshold }
+}
+
+// WithJSONMetrics reports the durations at the paths of metrics in JSON
+// responses
+func WithJSONMetrics(metrics ...JSONMetric) Option {
+	return func(a *Attacker) { a.cfg.JSONMetrics = metrics }
+}
+
+// WithRequestModifier calls m with each request before it's sent
+func WithRequestModifier(m RequestModifier) Option {
+	return func(a *Attacker) { a.cfg.RequestModifier = m }
+}
+
+// WithResponseValidator calls v with each response, failing those it
+// returns an error for
+func WithResponseValidator(v ResponseValidator) Option {
+	return func(a *Attacker) { a.cfg.ResponseValidator = v }
+}
+
+// WithSeed makes the random choices of the attack from seed, to repeat a run
+func WithSeed(seed int64) Option {
+	return func(a *Attacker) { a.cfg.Seed = seed }
+}
+
+// WithPageAssets also fetches the assets of HTML pages, parallelism at a
+// time or DefaultAssetParallelism if 0
+func WithPageAssets(parallelism int) Option {
+	return func(a *Attacker) { a.cfg.PageAssets, a.cfg.AssetParallelism = true, parallelism }
+}
+
+// WithCompareHost also sends every request to host, comparing the responses
+// but for the headers ignore
+func WithCompareHost(host string, ignore ...string) Option {
+	return func(a *Attacker) { a.cfg.CompareHost, a.cfg.CompareIgnore = host, ignore }
+}
+
+// WithResults streams every result on ch as it arrives. The channel must be
+// drained by the caller, and is closed when the attack ends. Only use ch for
+// one attack at a time.
+func WithResults(ch chan<- Result) Option {
+	return func(a *Attacker) { a.cfg.Results = ch }
+}
+
+// Config of the Attacker
+func (a *Attacker) Config() Config {
+	return a.cfg
+}
+
+// Progress returns the requests and errors so far of the attacks running,
+// for polling while they run
+func (a *Attacker) Progress() (requests, errors int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for at := range a.running {
+		r, e := at.progress()
+		requests += r
+		errors += e
+	}
+	return requests, errors
+}
+
+// Track an attack for Progress while it runs
+func (a *Attacker) track(at *attack) func() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.running == nil {
+		a.running = make(map[*attack]bool)
+	}
+	a.running[at] = true
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		delete(a.running, at)
+	}
+}
+
+// Attack runs an attack and returns its results. Cancelling ctx stops the
+// attack early, cancelling requests in flight, and returns the results so far
+// along with the context error. Concurrent is capped at Requests.
+func (a *Attacker) Attack(ctx context.Context) (Results, error) {
+	cfg := a.cfg
+	if cfg.Results != nil {
+		defer close(cfg.Results)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Results{}, err
+	}
+	if cfg.Requests > 0 && cfg.Concurrent > cfg.Requests {
+		cfg.Concurrent = cfg.Requests
+	}
+	if cfg.SaveErrors != "" {
+		if err := os.MkdirAll(cfg.SaveErrors, 0755); err != nil {
+			return Results{}, err
+		}
+	}
+	if len(cfg.Targets) == 0 {
+		cfg.Targets = []Target{{Name: cfg.URL, URL: cfg.URL}}
+	}
+	if cfg.Method == "" && cfg.GRPC {
+		cfg.Method = http.MethodPost
+	}
+	if cfg.Method == "" {
+		cfg.Method = http.MethodGet
+	}
+	if cfg.MaxDecoded <= 0 {
+		cfg.MaxDecoded = DefaultMaxDecoded
+	}
+	if p, ok := cfg.Pattern.(Phaser); ok && cfg.Phases == nil {
+		cfg.Phases = p.Phases()
+	}
+	at := &attack{cfg: cfg, log: cfg.Logger, seed: cfg.Seed}
+	for at.seed == 0 {
+		at.seed = rand.Int63()
+	}
+	at.rng = rand.New(rand.NewSource(at.seed))
+	at.cfg.Body = seeded(cfg.Body, at.seed)
+	if len(cfg.Methods) > 0 {
+		at.cfg.Methods = slices.Clone(cfg.Methods)
+		for i := range at.cfg.Methods {
+			at.cfg.Methods[i].Body = seeded(cfg.Methods[i].Body, at.seed)
+		}
+	}
+	for _, m := range cfg.JSONMetrics {
+		p, _ := parseJSONPath(m.Path)
+		at.jsonPaths = append(at.jsonPaths, p)
+	}
+	for _, m := range cfg.Methods {
+		at.methodTotal += m.Weight
+	}
+	if cfg.CompareHost != "" {
+		at.ignore = compareIgnored(cfg.CompareIgnore)
+	}
+	if at.log == nil {
+		at.log = slog.Default()
+	}
+	defer a.track(at)()
+	return at.run(ctx)
+}
@@ -0,0 +1,38 @@
+package tensile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/wait" {
+			<-release
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	a := NewAttacker(WithTargets(Target{URL: srv.URL + "/"}, Target{URL: srv.URL + "/wait"}), WithRequests(4), WithConcurrency(2))
+	done := make(chan Results)
+	go func() {
+		res, _ := a.Attack(context.Background())
+		done <- res
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for n, _ := a.Progress(); n < 1; n, _ = a.Progress() {
+		if time.Now().After(deadline) {
+			t.Fatal("no progress while a request is held")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-done
+	if n, errs := a.Progress(); n != 0 || errs != 0 {
+		t.Fatalf("progress %d, %d after the attack finished, want none running", n, errs)
+	}
+}
@@ -0,0 +1,74 @@
+package tensile
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultAuditHeaders are the security and caching headers audited by default
+var DefaultAuditHeaders = []string{
+	"Strict-Transport-Security",
+	"Content-Security-Policy",
+	"X-Frame-Options",
+	"X-Content-Type-Options",
+	"Referrer-Policy",
+	"Cache-Control",
+}
+
+const (
+	// Value counted for responses without an audited header
+	AuditAbsent = "(absent)"
+	// Value counted for values beyond the first maxAuditValues of a header
+	AuditOther = "(other)"
+	// Distinct values counted per audited header, bounding memory use
+	maxAuditValues = 20
+)
+
+// HeaderAudit summarises the values of an audited header over all responses
+type HeaderAudit struct {
+	Header string           `json:"header"`
+	Values map[string]int64 `json:"values"` // Responses with each value, or AuditAbsent
+}
+
+// Consistent reports whether every response had the same value, or all lacked
+// the header
+func (h HeaderAudit) Consistent() bool {
+	return len(h.Values) <= 1
+}
+
+// Values of the audited headers of a response
+func auditHeaders(names []string, h http.Header) map[string]string {
+	m := make(map[string]string, len(names))
+	for _, name := range names {
+		v := AuditAbsent
+		if vs := h.Values(name); len(vs) > 0 {
+			v = strings.Join(vs, ", ")
+		}
+		m[name] = v
+	}
+	return m
+}
+
+// Count a value of an audited header in m
+func countAudit(m map[string]map[string]int64, name, v string, n int64) {
+	vs := m[name]
+	if vs == nil {
+		vs = make(map[string]int64)
+		m[name] = vs
+	}
+	if _, ok := vs[v]; !ok && len(vs) >= maxAuditValues {
+		v = AuditOther
+	}
+	vs[v] += n
+}
+
+// Audits of the headers counted in m, in header name order
+func audits(m map[string]map[string]int64) []HeaderAudit {
+	var a []HeaderAudit
+	for name, vs := range m {
+		a = append(a, HeaderAudit{name, vs})
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i].Header < a[j].Header })
+	return a
+}
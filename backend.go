@@ -0,0 +1,72 @@
+package tensile
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Trace of the server address a request was sent to
+type backendTrace struct {
+	addr atomic.Pointer[string]
+}
+
+// Trace the address of the connection req is sent on
+func (b *backendTrace) trace(req *http.Request) *http.Request {
+	ct := &httptrace.ClientTrace{GotConn: func(i httptrace.GotConnInfo) {
+		if host, _, err := net.SplitHostPort(i.Conn.RemoteAddr().String()); err == nil {
+			b.addr.Store(&host)
+		}
+	}}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), ct))
+}
+
+// IP address of the server, if a connection was made
+func (b *backendTrace) backend() string {
+	if p := b.addr.Load(); p != nil {
+		return *p
+	}
+	return ""
+}
+
+// Backend address a failed connection was being made to, if any
+func dialBackend(err error) string {
+	var oe *net.OpError
+	if !errors.As(err, &oe) || oe.Op != "dial" || oe.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(oe.Addr.String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// Flag the backends whose p99 latency or error rate stands out from the
+// others, as a single bad node behind a load balancer would
+func markOutliers(bs []BackendResults) {
+	if len(bs) < 2 {
+		return
+	}
+	p99s := make([]time.Duration, len(bs))
+	errs := make([]float64, len(bs))
+	for i, b := range bs {
+		p99s[i], errs[i] = b.Percentiles["p99"], b.ErrorRate
+	}
+	slices.Sort(p99s)
+	slices.Sort(errs)
+	p99, rate := p99s[len(p99s)/2], errs[len(errs)/2]
+	for i, b := range bs {
+		switch {
+		case b.ErrorRate > 2*rate && b.ErrorRate > rate+0.01:
+			bs[i].Outlier = fmt.Sprintf("%.2f%% errors, against a median of %.2f%%", b.ErrorRate*100, rate*100)
+		case p99 > 0 && b.Percentiles["p99"] > 2*p99:
+			bs[i].Outlier = fmt.Sprintf("p99 %.1fx the median of %s", float64(b.Percentiles["p99"])/float64(p99), p99)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package tensile
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Longest a throttled read sleeps for, so reads are small and steady
+const throttleStep = 50 * time.Millisecond
+
+// Reader of at most rate bytes a second, as a slow client would read
+type throttleReader struct {
+	ctx   context.Context
+	r     io.Reader
+	rate  int64
+	start time.Time
+	n     int64
+}
+
+func newThrottle(ctx context.Context, r io.Reader, rate int64) *throttleReader {
+	return &throttleReader{ctx: ctx, r: r, rate: rate, start: time.Now()}
+}
+
+func (t *throttleReader) Read(p []byte) (int, error) {
+	if step := max(t.rate*int64(throttleStep)/int64(time.Second), 1); int64(len(p)) > step {
+		p = p[:step]
+	}
+	n, err := t.r.Read(p)
+	t.n += int64(n)
+	wait := time.Duration(float64(t.n)/float64(t.rate)*float64(time.Second)) - time.Since(t.start)
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,33 @@
+package tensile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxBandwidth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	defer srv.Close()
+	a := NewAttacker(WithURL(srv.URL), WithRequests(1), WithConcurrency(1), WithMaxBandwidth(4000))
+	if n := a.Config().MaxBandwidth; n != 4000 {
+		t.Fatalf("WithMaxBandwidth set %d", n)
+	}
+	start := time.Now()
+	res, err := a.Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Errors != 0 {
+		t.Fatalf("%d errors, checks %v", res.Errors, res.Checks)
+	}
+	// 1000 bytes at 4000 bytes a second
+	if took := time.Since(start); took < 200*time.Millisecond {
+		t.Errorf("read 1000 bytes in %s, want at least 200ms", took)
+	}
+}
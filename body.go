@@ -0,0 +1,259 @@
+package tensile
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Body is the body sent with every request of an attack
+type Body interface {
+	// Open returns a new reader of the body, and its length, or -1 if
+	// unknown
+	Open() (io.ReadCloser, int64, error)
+	// ContentType of the body
+	ContentType() string
+}
+
+// ErrJSON is returned by JSONBody for invalid JSON
+var ErrJSON = errors.New("tensile: invalid JSON body")
+
+// Bytes is a Body of fixed content
+type Bytes struct {
+	Data []byte
+	Type string // Content-Type
+}
+
+// Open returns a reader of the content
+func (b Bytes) Open() (io.ReadCloser, int64, error) {
+	return io.NopCloser(bytes.NewReader(b.Data)), int64(len(b.Data)), nil
+}
+
+// ContentType of the content
+func (b Bytes) ContentType() string {
+	return b.Type
+}
+
+// FormBody returns a URL encoded form Body of v
+func FormBody(v url.Values) Bytes {
+	return Bytes{[]byte(v.Encode()), "application/x-www-form-urlencoded"}
+}
+
+// JSONBody returns a JSON Body of s, which must be valid JSON
+func JSONBody(s string) (Bytes, error) {
+	if !json.Valid([]byte(s)) {
+		return Bytes{}, ErrJSON
+	}
+	return Bytes{[]byte(s), "application/json"}, nil
+}
+
+// File is a Body streamed from a file for every request
+type File struct {
+	Path string
+	Type string // Content-Type
+}
+
+// Open opens the file
+func (f File) Open() (io.ReadCloser, int64, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil, 0, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, fi.Size(), nil
+}
+
+// ContentType of the file
+func (f File) ContentType() string {
+	return f.Type
+}
+
+// Size of the block of random data repeated by Synthetic bodies
+const randomBlock = 1 << 20
+
+// Synthetic is a generated Body of Size bytes, of zeros, or of random data if
+// Random is set, for testing uploads without creating files. An attack
+// regenerates the random data from its Seed.
+type Synthetic struct {
+	Size   int64
+	Random bool
+	block  []byte
+}
+
+// NewSynthetic returns a Synthetic body of size bytes
+func NewSynthetic(size int64, random bool) *Synthetic {
+	s := &Synthetic{Size: size, Random: random}
+	if random {
+		s.block = make([]byte, min(size, randomBlock))
+		rand.Read(s.block)
+	}
+	return s
+}
+
+// Open returns a reader generating the body
+func (s *Synthetic) Open() (io.ReadCloser, int64, error) {
+	var r io.Reader = zeros{}
+	if s.Random {
+		r = &repeat{b: s.block}
+	}
+	return io.NopCloser(io.LimitReader(r, s.Size)), s.Size, nil
+}
+
+// ContentType of the body
+func (s *Synthetic) ContentType() string {
+	return "application/octet-stream"
+}
+
+// Reader of endless zeros
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// Reader repeating b endlessly
+type repeat struct {
+	b []byte
+	i int
+}
+
+func (r *repeat) Read(p []byte) (int, error) {
+	n := copy(p, r.b[r.i:])
+	r.i = (r.i + n) % len(r.b)
+	return n, nil
+}
+
+// FormField is a field of a multipart form. If File is set, Value is the path
+// of a file to upload.
+type FormField struct {
+	Name, Value string
+	File        bool
+}
+
+// Multipart is a multipart/form-data Body. Files are streamed from disk for
+// every request, rather than held in memory, and the body is sent chunked.
+type Multipart struct {
+	fields   []FormField
+	boundary string
+}
+
+// NewMultipart returns a multipart/form-data Body of the fields
+func NewMultipart(fields []FormField) *Multipart {
+	var b [16]byte
+	rand.Read(b[:])
+	return &Multipart{fields: fields, boundary: fmt.Sprintf("tensile%x", b)}
+}
+
+// Open streams the form through a pipe
+func (m *Multipart) Open() (io.ReadCloser, int64, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(m.write(pw))
+	}()
+	return pr, -1, nil
+}
+
+// Write the form to w
+func (m *Multipart) write(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for _, f := range m.fields {
+		if !f.File {
+			if err := mw.WriteField(f.Name, f.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		part, err := mw.CreateFormFile(f.Name, filepath.Base(f.Value))
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(f.Value)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// ContentType of the form, including its boundary
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// Reader splitting a body into chunks of at most size bytes, if not 0, with
+// a delay between them
+type chunkReader struct {
+	io.ReadCloser
+	ctx     context.Context
+	size    int
+	delay   time.Duration
+	started bool
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if c.started && c.delay > 0 {
+		t := time.NewTimer(c.delay)
+		defer t.Stop()
+		select {
+		case <-c.ctx.Done():
+			return 0, c.ctx.Err()
+		case <-t.C:
+		}
+	}
+	c.started = true
+	if c.size > 0 && len(p) > c.size {
+		p = p[:c.size]
+	}
+	return c.ReadCloser.Read(p)
+}
+
+// Open a new reader of b for req, sent chunked if cfg.Chunked is set
+func (a *attack) openBody(req *http.Request, b Body) (io.ReadCloser, int64, error) {
+	rc, n, err := b.Open()
+	if err != nil || !a.cfg.Chunked {
+		return rc, n, err
+	}
+	return &chunkReader{ReadCloser: rc, ctx: req.Context(), size: a.cfg.ChunkSize, delay: a.cfg.ChunkDelay}, -1, nil
+}
+
+// Set the body of req to a new reader of b
+func (a *attack) setBody(req *http.Request, b Body) error {
+	rc, n, err := a.openBody(req, b)
+	if err != nil {
+		return err
+	}
+	req.Body, req.ContentLength = rc, n
+	if n == 0 {
+		rc.Close()
+		req.Body = http.NoBody
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		rc, _, err := a.openBody(req, b)
+		return rc, err
+	}
+	req.Header.Set("Content-Type", b.ContentType())
+	return nil
+}
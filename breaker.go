@@ -0,0 +1,104 @@
+package tensile
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Buckets the breaker's rolling window is kept in
+const breakerBuckets = 10
+
+// Fewest requests in the window for the breaker to trip
+const breakerMinRequests = 20
+
+// Breaker pauses dispatching requests for Cooldown whenever the error rate
+// over the last Window reaches ErrorRate, to spare a failing target
+type Breaker struct {
+	ErrorRate float64 // Fraction of requests, e.g. 0.5
+	Window    time.Duration
+	Cooldown  time.Duration
+}
+
+// Pause is a time dispatching was paused by the breaker
+type Pause struct {
+	Start     time.Duration `json:"start_ns"` // Offset from the start of the run
+	Duration  time.Duration `json:"duration_ns"`
+	ErrorRate float64       `json:"error_rate"` // Error rate that tripped the breaker
+}
+
+// Counts of one interval of the breaker's window
+type breakerBucket struct {
+	n                int64 // Index of the interval since the start
+	requests, errors int64
+}
+
+// State of the breaker of an attack
+type breaker struct {
+	cfg     Breaker
+	start   time.Time
+	mu      sync.Mutex
+	buckets [breakerBuckets]breakerBucket
+	until   time.Time // Dispatching is paused until then
+	pauses  []Pause
+}
+
+// Count a result, tripping the breaker if the error rate over the window
+// reaches the threshold. Reports whether it tripped.
+func (b *breaker) add(failed bool) (Pause, bool) {
+	now := time.Now()
+	iv := max(b.cfg.Window/breakerBuckets, 1)
+	n := int64(now.Sub(b.start) / iv)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Before(b.until) {
+		return Pause{}, false
+	}
+	bk := &b.buckets[n%breakerBuckets]
+	if bk.n != n {
+		bk.n, bk.requests, bk.errors = n, 0, 0
+	}
+	bk.requests++
+	if failed {
+		bk.errors++
+	}
+	var requests, errors int64
+	for _, bk := range b.buckets {
+		if bk.n > n-breakerBuckets {
+			requests += bk.requests
+			errors += bk.errors
+		}
+	}
+	rate := float64(errors) / float64(requests)
+	if requests < breakerMinRequests || rate < b.cfg.ErrorRate {
+		return Pause{}, false
+	}
+	b.until = now.Add(b.cfg.Cooldown)
+	b.buckets = [breakerBuckets]breakerBucket{}
+	p := Pause{Start: now.Sub(b.start), Duration: b.cfg.Cooldown, ErrorRate: rate}
+	b.pauses = append(b.pauses, p)
+	return p, true
+}
+
+// Wait for the breaker to close, if it is open. Reports whether it waited,
+// and false if ctx is done or the deadline passes first.
+func (b *breaker) wait(ctx context.Context, deadline <-chan time.Time) (bool, bool) {
+	b.mu.Lock()
+	d := time.Until(b.until)
+	b.mu.Unlock()
+	if d <= 0 {
+		return false, true
+	}
+	return true, wait(ctx, deadline, d)
+}
+
+// The pauses, the last cut short by the end of a run that took d
+func (b *breaker) result(d time.Duration) []Pause {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.pauses {
+		p := &b.pauses[i]
+		p.Duration = max(min(p.Duration, d-p.Start), 0)
+	}
+	return b.pauses
+}
@@ -0,0 +1,112 @@
+package tensile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Outcomes of comparing responses with those of Config.CompareHost, counted
+// in Results.Compare
+const (
+	CompareMatch   = "match"
+	CompareStatus  = "status"
+	CompareHeaders = "headers"
+	CompareBody    = "body"
+	CompareError   = "error" // The comparison request failed
+)
+
+// Headers never compared: those of the connection, and Content-Length, as
+// the bodies are
+var uncompared = []string{"Connection", "Keep-Alive", "Transfer-Encoding", "Trailer", "Content-Length"}
+
+// Canonical names of the headers not compared
+func compareIgnored(ignore []string) map[string]bool {
+	m := make(map[string]bool)
+	for _, h := range append(slices.Clone(uncompared), ignore...) {
+		m[http.CanonicalHeaderKey(strings.TrimSpace(h))] = true
+	}
+	return m
+}
+
+// Name of the first header that differs between a and b, if any
+func diffHeaders(a, b http.Header, ignore map[string]bool) string {
+	var names []string
+	for k := range a {
+		names = append(names, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	slices.Sort(names)
+	for _, k := range names {
+		if !ignore[k] && !slices.Equal(a[k], b[k]) {
+			return k
+		}
+	}
+	return ""
+}
+
+// Send the request to cfg.CompareHost too, with body, and compare its
+// response with r, whose body hashed to r.sum
+func (a *attack) compare(t http.RoundTripper, orig *http.Request, body Body, r *response) {
+	req := orig.Clone(orig.Context())
+	req.URL.Host, req.Host = a.cfg.CompareHost, ""
+	if body != nil {
+		if err := a.setBody(req, body); err != nil {
+			a.mismatch(r, CompareError, err.Error())
+			return
+		}
+	}
+	resp, err := t.RoundTrip(req)
+	if err != nil {
+		a.mismatch(r, CompareError, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	var rd io.Reader = resp.Body
+	if dec := encodings[resp.Header.Get("Content-Encoding")]; len(a.cfg.Compress) > 0 && dec != nil {
+		d, err := dec(resp.Body)
+		if err != nil {
+			a.mismatch(r, CompareError, err.Error())
+			return
+		}
+		defer d.Close()
+		rd = io.LimitReader(d, a.cfg.MaxDecoded+1)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, rd); err != nil {
+		a.mismatch(r, CompareError, err.Error())
+		return
+	}
+	switch {
+	case resp.StatusCode != r.StatusCode:
+		a.mismatch(r, CompareStatus, fmt.Sprintf("%d, compared with %d", r.StatusCode, resp.StatusCode))
+	case diffHeaders(r.Header, resp.Header, a.ignore) != "":
+		k := diffHeaders(r.Header, resp.Header, a.ignore)
+		a.mismatch(r, CompareHeaders, fmt.Sprintf("%s: %q, compared with %q", k, r.Header.Values(k), resp.Header.Values(k)))
+	case !bytes.Equal(r.sum.Sum(nil), h.Sum(nil)):
+		a.mismatch(r, CompareBody, "bodies differ")
+	default:
+		r.compare = CompareMatch
+	}
+}
+
+// Record a mismatch, logging it the first time of each kind in a row
+func (a *attack) mismatch(r *response, kind, detail string) {
+	r.compare = kind
+	a.failMu.Lock()
+	defer a.failMu.Unlock()
+	if kind != a.prevMismatch {
+		a.log.Warn("responses differ", "url", r.Request.URL.String(), "compare_host", a.cfg.CompareHost, "mismatch", kind, "detail", detail)
+	} else {
+		a.log.Debug("responses differ", "url", r.Request.URL.String(), "compare_host", a.cfg.CompareHost, "mismatch", kind, "detail", detail)
+	}
+	a.prevMismatch = kind
+}
@@ -0,0 +1,24 @@
+package tensile
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+func TestCompareHost(t *testing.T) {
+	srv := okServer(t)
+	u, _ := url.Parse(srv.URL)
+	// The server is its own canary, so every response matches
+	a := NewAttacker(WithURL(srv.URL), WithRequests(8), WithConcurrency(2), WithCompareHost(u.Host, "Date"))
+	if c := a.Config(); c.CompareHost != u.Host || len(c.CompareIgnore) != 1 {
+		t.Fatalf("WithCompareHost set %q, %v", c.CompareHost, c.CompareIgnore)
+	}
+	res, err := a.Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Compare[CompareMatch] != 8 {
+		t.Errorf("comparisons %v, want 8 matches", res.Compare)
+	}
+}
@@ -0,0 +1,61 @@
+package tensile
+
+import (
+	"crypto/tls"
+	"sync"
+	"time"
+)
+
+// Certificates expiring within this time of a run are warned about
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// Cert is a certificate presented by a server
+type Cert struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+	Expiring bool      `json:"expiring,omitempty"` // Expires within 30 days of the run, or has expired
+}
+
+// CertChain is the certificate chain a server presented, leaf first
+type CertChain struct {
+	Host  string `json:"host"`
+	Chain []Cert `json:"chain"`
+}
+
+// Certificate chains of the first handshake with each host
+type certCapture struct {
+	mu     sync.Mutex
+	chains []CertChain
+	seen   map[string]bool
+}
+
+// Capture the chain of a handshake, if its host hasn't been seen
+func (c *certCapture) add(cs tls.ConnectionState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen[cs.ServerName] || len(cs.PeerCertificates) == 0 {
+		return
+	}
+	if c.seen == nil {
+		c.seen = make(map[string]bool)
+	}
+	c.seen[cs.ServerName] = true
+	ch := CertChain{Host: cs.ServerName}
+	for _, pc := range cs.PeerCertificates {
+		ch.Chain = append(ch.Chain, Cert{
+			Subject:  pc.Subject.String(),
+			Issuer:   pc.Issuer.String(),
+			NotAfter: pc.NotAfter,
+			Expiring: time.Until(pc.NotAfter) < certExpiryWarning,
+		})
+	}
+	c.chains = append(c.chains, ch)
+}
+
+// The chains captured
+func (c *certCapture) result() []CertChain {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.chains
+}
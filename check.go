@@ -0,0 +1,190 @@
+package tensile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+// Names of response checks, counted separately in Results.Checks when they
+// fail
+const (
+	CheckTruncated = "truncated"
+	CheckChecksum  = "checksum"
+	CheckHeader    = "header"
+	CheckDecode    = "decode"
+	CheckRange     = "range"
+	CheckGRPC      = "grpc"
+	CheckGraphQL   = "graphql"
+	CheckAsset     = "asset"
+	CheckValidator = "validator"
+)
+
+// HeaderCheck asserts a header of successful responses. The header must be
+// present and, if Pattern is set, have a value matching it, otherwise have a
+// value equal to Value.
+type HeaderCheck struct {
+	Name    string
+	Value   string
+	Pattern *regexp.Regexp
+}
+
+// Check h, returning an error describing any failure
+func (c HeaderCheck) check(h http.Header) error {
+	vs := h.Values(c.Name)
+	if len(vs) == 0 {
+		return fmt.Errorf("missing header %s", c.Name)
+	}
+	for _, v := range vs {
+		if (c.Pattern != nil && c.Pattern.MatchString(v)) || (c.Pattern == nil && v == c.Value) {
+			return nil
+		}
+	}
+	want := fmt.Sprintf("%q", c.Value)
+	if c.Pattern != nil {
+		want = "match for " + c.Pattern.String()
+	}
+	return fmt.Errorf("header %s: %q, expected %s", c.Name, vs[0], want)
+}
+
+// Read the body of a response fully, counting the bytes read, and check it.
+// A body cut short mid-stream, or of a different length to its declared
+// Content-Length, fails the truncated check. Successful responses must also
+// pass cfg.HeaderChecks and, if cfg.ExpectSHA256 is set, have a body with
+// that hash. If cfg.Compress is set, compressed bodies are decompressed, up to
+// cfg.MaxDecoded bytes, failing the decode check if they can't be. Responses
+// to range requests must be 206 Partial Content of the range requested, and
+// gRPC calls must have an OK gRPC status. If cfg.GraphQL is set, successful
+// responses fail the graphql check if their body has GraphQL errors. If
+// cfg.PageAssets is set, successful HTML bodies are kept to find their assets,
+// and if cfg.CompareHost is set, bodies are hashed to compare. Last,
+// responses must pass cfg.ResponseValidator, if set. The values of
+// cfg.JSONMetrics are taken from successful bodies.
+func (a *attack) check(r *response) {
+	if r.StatusCode < 400 {
+		for _, c := range a.cfg.HeaderChecks {
+			if err := c.check(r.Header); err != nil {
+				r.fail(CheckHeader, err)
+				break
+			}
+		}
+	}
+	var w io.Writer = io.Discard
+	var h hash.Hash
+	if len(a.cfg.ExpectSHA256) > 0 && r.StatusCode < 400 {
+		h = sha256.New()
+		w = h
+	}
+	var gql *bytes.Buffer
+	if a.cfg.GraphQL && r.StatusCode < 400 {
+		gql = &bytes.Buffer{}
+		w = io.MultiWriter(w, &limitWriter{gql, graphQLMaxBody + 1})
+	}
+	if a.cfg.CompareHost != "" {
+		r.sum = sha256.New()
+		w = io.MultiWriter(w, r.sum)
+	}
+	var doc *bytes.Buffer
+	if len(a.cfg.JSONMetrics) > 0 && r.StatusCode < 400 {
+		doc = &bytes.Buffer{}
+		w = io.MultiWriter(w, &limitWriter{doc, jsonMetricMaxBody + 1})
+	}
+	var validated *bytes.Buffer
+	if a.cfg.ResponseValidator != nil {
+		validated = &bytes.Buffer{}
+		w = io.MultiWriter(w, &limitWriter{validated, maxValidatedBody})
+	}
+	if a.cfg.PageAssets && r.StatusCode < 300 && isHTML(r.Header) {
+		r.page = &bytes.Buffer{}
+		w = io.MultiWriter(w, &limitWriter{r.page, maxPageBody})
+	}
+	wire := &r.wire
+	wire.r = r.Body
+	if a.cfg.Stream {
+		stall := a.cfg.StallThreshold
+		if stall == 0 {
+			stall = DefaultStallThreshold
+		}
+		r.chunks = &chunkTimer{r: wire.r, start: r.start, stall: stall}
+		wire.r = r.chunks
+	}
+	if a.cfg.MaxBandwidth > 0 {
+		wire.r = newThrottle(r.Request.Context(), wire.r, a.cfg.MaxBandwidth)
+	}
+	var body io.Reader = wire
+	var derr error
+	dec := encodings[r.Header.Get("Content-Encoding")]
+	decoding := len(a.cfg.Compress) > 0 && dec != nil
+	if decoding {
+		var d io.ReadCloser
+		if d, derr = dec(wire); derr == nil {
+			defer d.Close()
+			body = io.LimitReader(d, a.cfg.MaxDecoded+1)
+		}
+	}
+	n, err := io.Copy(w, body)
+	if derr == nil && err != nil && wire.err == nil {
+		derr = err
+	}
+	// Drain any of the body left after decompression
+	io.Copy(io.Discard, wire)
+	r.size = wire.n
+	if len(a.cfg.Compress) > 0 {
+		r.decoded = n
+	}
+	switch {
+	case wire.err != nil:
+		r.fail(CheckTruncated, fmt.Errorf("truncated body after %d bytes: %w", wire.n, wire.err))
+	case r.ContentLength >= 0 && wire.n != r.ContentLength && hasBody(r.Response):
+		r.fail(CheckTruncated, fmt.Errorf("truncated body: read %d of %d bytes", wire.n, r.ContentLength))
+	case derr != nil:
+		r.fail(CheckDecode, fmt.Errorf("decompressing %s body: %w", r.Header.Get("Content-Encoding"), derr))
+	case decoding && n > a.cfg.MaxDecoded:
+		r.fail(CheckDecode, fmt.Errorf("decompressed body over %d bytes", a.cfg.MaxDecoded))
+	case h != nil:
+		if sum := h.Sum(nil); !bytes.Equal(sum, a.cfg.ExpectSHA256) {
+			r.fail(CheckChecksum, fmt.Errorf("body SHA-256 %x, expected %x", sum, a.cfg.ExpectSHA256))
+		}
+	}
+	if gql != nil && r.check == "" && gql.Len() <= graphQLMaxBody {
+		if err := checkGraphQL(gql.Bytes()); err != nil {
+			r.fail(CheckGraphQL, err)
+		}
+	}
+	if a.cfg.GRPC && r.StatusCode == http.StatusOK && wire.err == nil {
+		grpcStatus(r)
+	}
+	if r.rng != nil && r.StatusCode < 400 {
+		if err := a.checkRange(r); err != nil {
+			r.fail(CheckRange, err)
+		}
+	}
+	if doc != nil && doc.Len() <= jsonMetricMaxBody {
+		r.metrics = a.jsonMetrics(doc.Bytes())
+	}
+	if validated != nil && r.check == "" {
+		if err := a.cfg.ResponseValidator.ValidateResponse(r.Response, validated.Bytes()); err != nil {
+			r.fail(CheckValidator, err)
+		}
+	}
+}
+
+// Whether a response has a body whose length Content-Length declares: not
+// one to a HEAD request, nor a 1xx, 204 or 304 response
+func hasBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	return resp.StatusCode >= 200 && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified
+}
+
+// Record the first failed check of a response
+func (r *response) fail(check string, err error) {
+	if r.check == "" {
+		r.check, r.checkErr = check, err
+	}
+}
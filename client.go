@@ -0,0 +1,149 @@
+package tensile
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interval between samples of the load generator's own resource use
+const clientInterval = 250 * time.Millisecond
+
+// Clock ticks per second of the CPU times in /proc, USER_HZ on Linux
+const clockTicks = 100
+
+// ClientStats is the load generator's own resource use during a run, to tell
+// whether it, rather than the target, limited the results. CPU use and file
+// descriptors are only known where the system has /proc.
+type ClientStats struct {
+	CPU        float64       `json:"cpu,omitempty"`        // Mean CPU use, in cores
+	CPUMax     float64       `json:"cpu_max,omitempty"`    // Highest CPU use over a sample interval
+	Procs      int           `json:"procs"`                // GOMAXPROCS
+	HeapMax    int64         `json:"heap_max_bytes"`       // Highest heap in use
+	GCs        uint32        `json:"gcs"`                  // Garbage collections
+	GCPause    time.Duration `json:"gc_pause_ns"`          // Total stop the world GC pauses
+	GCPauseMax time.Duration `json:"gc_pause_max_ns"`      // Longest GC pause
+	Goroutines int           `json:"goroutines_max"`       // Most goroutines
+	FDs        int           `json:"fds_max,omitempty"`    // Most open file descriptors
+	Bottleneck string        `json:"bottleneck,omitempty"` // Why the client appears to have limited the results, if it does
+
+	// With a rate, pattern or bursts, how well requests kept to schedule
+	Paced   int64         `json:"paced,omitempty"`      // Requests sent to schedule
+	Late    int64         `json:"late,omitempty"`       // Sent over 10ms after they were due
+	LagMax  time.Duration `json:"lag_max_ns,omitempty"` // Furthest behind schedule
+	Blocked int64         `json:"blocked,omitempty"`    // Waited for a free worker, all -concurrent being busy
+	Resets  int64         `json:"resets,omitempty"`     // Times the schedule fell over a second behind, and was abandoned
+}
+
+// Total CPU time used by this process, if known
+func processCPU() (time.Duration, bool) {
+	b, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, false
+	}
+	// Fields after the command, which may contain spaces, starting with state
+	_, rest, ok := strings.Cut(string(b), ") ")
+	f := strings.Fields(rest)
+	if !ok || len(f) < 13 {
+		return 0, false
+	}
+	utime, err1 := strconv.ParseInt(f[11], 10, 64)
+	stime, err2 := strconv.ParseInt(f[12], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, false
+	}
+	return time.Duration(utime+stime) * time.Second / clockTicks, true
+}
+
+// Open file descriptors of this process, if known
+func openFDs() (int, bool) {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	return len(fds), true
+}
+
+// Sampler of the load generator's resource use over a run
+type monitor struct {
+	stop, done chan struct{}
+	start      time.Time
+	last       time.Time
+	cpu0, cpu  time.Duration
+	cpuOK      bool
+	ms0        runtime.MemStats
+	gcs        uint32 // Collections whose pauses have been seen
+	st         ClientStats
+}
+
+// Start sampling resource use every clientInterval
+func startMonitor() *monitor {
+	m := &monitor{stop: make(chan struct{}), done: make(chan struct{}), start: time.Now()}
+	m.last = m.start
+	m.cpu0, m.cpuOK = processCPU()
+	m.cpu = m.cpu0
+	runtime.ReadMemStats(&m.ms0)
+	m.gcs = m.ms0.NumGC
+	m.st.Procs = runtime.GOMAXPROCS(0)
+	go func() {
+		defer close(m.done)
+		t := time.NewTicker(clientInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-t.C:
+				m.sample()
+			}
+		}
+	}()
+	return m
+}
+
+// Take a sample
+func (m *monitor) sample() {
+	now := time.Now()
+	if cpu, ok := processCPU(); ok && m.cpuOK {
+		if d := now.Sub(m.last); d > 0 {
+			m.st.CPUMax = max(m.st.CPUMax, float64(cpu-m.cpu)/float64(d))
+		}
+		m.cpu = cpu
+	}
+	m.last = now
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	m.st.HeapMax = max(m.st.HeapMax, int64(ms.HeapInuse))
+	// Only the last 256 pauses are kept
+	for n := max(m.gcs, ms.NumGC-min(ms.NumGC, 256)); n < ms.NumGC; n++ {
+		m.st.GCPauseMax = max(m.st.GCPauseMax, time.Duration(ms.PauseNs[n%256]))
+	}
+	m.gcs = ms.NumGC
+	m.st.GCs = ms.NumGC - m.ms0.NumGC
+	m.st.GCPause = time.Duration(ms.PauseTotalNs - m.ms0.PauseTotalNs)
+	m.st.Goroutines = max(m.st.Goroutines, runtime.NumGoroutine())
+	if n, ok := openFDs(); ok {
+		m.st.FDs = max(m.st.FDs, n)
+	}
+}
+
+// Stop sampling, returning the resource use of the run
+func (m *monitor) finish() *ClientStats {
+	close(m.stop)
+	<-m.done
+	m.sample()
+	d := m.last.Sub(m.start)
+	if m.cpuOK && d > 0 {
+		m.st.CPU = float64(m.cpu-m.cpu0) / float64(d)
+	}
+	switch {
+	case m.st.CPU >= 0.9*float64(m.st.Procs):
+		m.st.Bottleneck = fmt.Sprintf("CPU use averaged %.2f of %d cores", m.st.CPU, m.st.Procs)
+	case d > 0 && m.st.GCPause > d/20:
+		m.st.Bottleneck = fmt.Sprintf("GC paused for %.1f%% of the run", float64(m.st.GCPause)/float64(d)*100)
+	}
+	return &m.st
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+const defaultAgentPort = "7777"
+
+var (
+	agentsStr, agentToken, listenAddr string
+
+	agentFlags = flag.NewFlagSet("agent", flag.ExitOnError)
+
+	agentListening = "Agent listening on %s\n"
+	agentJobError  = "ERROR: invalid job: %s\n"
+	agentAuthError = "ERROR: unauthorized\n"
+	agentOpenError = "ERROR: -agent-token is required unless -listen is a loopback address\n"
+	agentFlagError = "ERROR: -%s can't be sent to -agents, which run only -url, -requests, -concurrent, -maxerror, -duration, -rate, -pattern and -burst\n"
+)
+
+// Attack flags that go to agents in a job, or that the controller applies
+// to the results they stream back. Any other flag would be dropped.
+var agentSafeFlags = map[string]bool{
+	"url": true, "requests": true, "concurrent": true, "maxerror": true, "duration": true,
+	"rate": true, "pattern": true, "spike-at": true, "spike-for": true, "spike-x": true, "period": true,
+	"min": true, "max": true, "schedule": true, "burst": true,
+	"continue-on-error": true, "agents": true, "agent-token": true, "cpu": true, "profile": true,
+	"record": true, "sqlite": true, "sample": true, "tag": true, "threshold": true, "output": true,
+	"o": true, "history": true, "upload": true, "notify-webhook": true,
+	"quiet": true, "v": true, "log-level": true, "log-format": true, "pprof": true,
+}
+
+func init() {
+	agentFlags.Usage = usageFor(agentFlags, "tensile agent [flags]")
+	agentFlags.StringVar(&listenAddr, "listen", "127.0.0.1:"+defaultAgentPort, "Address to accept attack jobs on")
+	for _, fs := range []*flag.FlagSet{attackFlags, agentFlags} {
+		fs.StringVar(&agentToken, "agent-token", "", "Shared secret between the controller and its agents")
+	}
+	attackFlags.StringVar(&agentsStr, "agents", "", "Comma separated agent addresses to distribute the attack across")
+}
+
+// Share of an attack sent to one agent
+type job struct {
+	URL        string `json:"url"`
+	Requests   int    `json:"requests"`
+	Concurrent int    `json:"concurrent"`
+	MaxErrors  int    `json:"max_errors"`
+
+	Duration time.Duration `json:"duration_ns,omitempty"`
+
+	// Rate and Burst are this agent's share. At most one of Spike, Sine and
+	// Schedule is set, scaled to the share.
+	Rate          float64          `json:"rate,omitempty"`
+	Spike         *tensile.Spike   `json:"spike,omitempty"`
+	Sine          *tensile.Sine    `json:"sine,omitempty"`
+	Schedule      tensile.Schedule `json:"schedule,omitempty"`
+	Burst         int              `json:"burst,omitempty"`
+	BurstInterval time.Duration    `json:"burst_interval_ns,omitempty"`
+}
+
+// Set the job's pattern to p with its rates scaled by f
+func (j *job) setPattern(p tensile.Pattern, f float64) {
+	switch p := p.(type) {
+	case tensile.Spike:
+		p.Base *= f
+		j.Spike = &p
+	case tensile.Sine:
+		p.Min *= f
+		p.Max *= f
+		j.Sine = &p
+	case tensile.Schedule:
+		j.Schedule = make(tensile.Schedule, len(p))
+		for i, pt := range p {
+			j.Schedule[i] = tensile.SchedulePoint{At: pt.At, Rate: pt.Rate * f}
+		}
+	}
+}
+
+// Pattern of the job, or nil for none
+func (j job) pattern() tensile.Pattern {
+	switch {
+	case j.Spike != nil:
+		return *j.Spike
+	case j.Sine != nil:
+		return *j.Sine
+	case j.Schedule != nil:
+		return j.Schedule
+	}
+	return nil
+}
+
+// Check that -agents is only used with flags a job carries
+func checkAgents() error {
+	if agentsStr == "" {
+		return nil
+	}
+	var bad string
+	attackFlags.Visit(func(f *flag.Flag) {
+		if name := longFlag(f.Name); !agentSafeFlags[name] && bad == "" {
+			bad = name
+		}
+	})
+	if bad != "" {
+		return fmt.Errorf(agentFlagError, bad)
+	}
+	return nil
+}
+
+// Whether addr only listens on a loopback interface
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Split a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+// Part i of n split into parts as evenly as possible
+func share(n, parts, i int) int {
+	s := n / parts
+	if i < n%parts {
+		s++
+	}
+	return s
+}
+
+// Base URL of an agent given as host, host:port or URL
+func agentURL(a string) string {
+	if !strings.Contains(a, "://") {
+		if !strings.Contains(a, ":") {
+			a += ":" + defaultAgentPort
+		}
+		a = "http://" + a
+	}
+	return strings.TrimSuffix(a, "/") + "/attack"
+}
+
+// Distribute the attack across agents, streaming their results to rec if it
+// has destinations. Agents stop when ctx is done.
+func distribute(ctx context.Context, agents []string, rec sink) tensile.Results {
+	var (
+		mu   sync.Mutex
+		done sync.WaitGroup
+	)
+	st := tensile.NewStats()
+	start := time.Now()
+	// Agents sent a share of the requests and of any burst
+	n := len(agents)
+	if opts.requests > 0 {
+		n = min(n, opts.requests)
+	}
+	if burstN > 0 {
+		n = min(n, burstN)
+	}
+	f := 1 / float64(n)
+	for i, a := range agents[:n] {
+		j := job{
+			URL:           opts.url,
+			Requests:      share(opts.requests, n, i),
+			Concurrent:    share(opts.concurrent, n, i),
+			MaxErrors:     maxErr,
+			Duration:      duration,
+			Rate:          rate * f,
+			Burst:         share(burstN, n, i),
+			BurstInterval: burstEvery,
+		}
+		j.setPattern(loadPattern, f)
+		if j.Concurrent == 0 {
+			j.Concurrent = 1
+		}
+		done.Add(1)
+		go func(a string, j job) {
+			defer done.Done()
+			err := runAgent(ctx, a, j, func(r tensile.Result) {
+				st.Add(r)
+				if len(rec) > 0 {
+					mu.Lock()
+					defer mu.Unlock()
+					if err := rec.Write(r); err != nil {
+						slog.Error("recording result", "err", err)
+					}
+				}
+			})
+			if err != nil {
+				slog.Error("agent failed", "agent", a, "err", err)
+			}
+		}(a, j)
+	}
+	done.Wait()
+	res := st.Summary(opts.url, time.Since(start))
+	res.Tags = runTags
+	return res
+}
+
+// Send a job to an agent and decode the results it streams back
+func runAgent(ctx context.Context, a string, j job, fn func(tensile.Result)) error {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", agentURL(a), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", tensile.App+tensile.Version)
+	if agentToken != "" {
+		req.Header.Set("Authorization", "Bearer "+agentToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, err = tensile.DecodeRecording(resp.Body, fn)
+	return err
+}
+
+// Flush the response after every write so results stream to the controller
+// as soon as the Recorder is flushed
+type flushWriter struct {
+	w io.Writer
+	f http.Flusher
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	fw.f.Flush()
+	return n, err
+}
+
+// Config of a job
+func (j job) config() tensile.Config {
+	return tensile.Config{URL: j.URL, Requests: j.Requests, Concurrent: j.Concurrent, MaxErrors: j.MaxErrors, Duration: j.Duration,
+		Rate: j.Rate, Pattern: j.pattern(), Burst: j.Burst, BurstInterval: j.BurstInterval}
+}
+
+// Run an attack job from a controller
+func handleJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if agentToken != "" {
+		auth := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(auth, []byte("Bearer "+agentToken)) != 1 {
+			http.Error(w, agentAuthError, http.StatusUnauthorized)
+			return
+		}
+	}
+	var j job
+	if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+		http.Error(w, fmt.Sprintf(agentJobError, err), http.StatusBadRequest)
+		return
+	}
+	cfg := j.config()
+	if err := cfg.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf(agentJobError, err), http.StatusBadRequest)
+		return
+	}
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	l := slog.With("remote", r.RemoteAddr)
+	l.Info("attacking", "url", j.URL, "requests", j.Requests, "concurrent", j.Concurrent)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	info := tensile.RunInfo{Version: tensile.Version, URL: j.URL, Requests: j.Requests, Concurrent: j.Concurrent, Start: time.Now()}
+	rec, err := tensile.NewRecorder(flushWriter{w, f}, info)
+	if err != nil {
+		l.Error("streaming results", "err", err)
+		return
+	}
+	stream := make(chan tensile.Result, j.Concurrent)
+	cfg.Results = stream
+	cfg.Logger = l
+	go tensile.Attack(r.Context(), cfg)
+	for res := range stream {
+		err := rec.Write(res)
+		// Send each batch of results as soon as the attack has no more ready
+		if err == nil && len(stream) == 0 {
+			err = rec.Flush()
+		}
+		if err != nil {
+			l.Error("streaming results", "err", err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		l.Error("streaming results", "err", err)
+	}
+}
+
+// Agent subcommand
+func agentCmd(args []string) {
+	agentFlags.Parse(args)
+	setupLogging()
+	if agentToken == "" && !loopback(listenAddr) {
+		log.Fatal(errors.New(agentOpenError))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/attack", handleJob)
+	infof("\n\t%s\n\n", tensile.App+tensile.Version)
+	infof(agentListening, listenAddr)
+	log.Fatal(http.ListenAndServe(listenAddr, mux))
+}
@@ -0,0 +1,15 @@
+package main
+
+import "github.com/intermernet/tensile"
+
+var (
+	pageAssets       bool
+	assetParallelism int
+
+	assetParallelismError = "ERROR: -asset-parallelism must be greater than 0\n"
+)
+
+func init() {
+	attackFlags.BoolVar(&pageAssets, "page-assets", false, "Also fetch the scripts, images and stylesheets of HTML pages from their origin, reporting the page load time")
+	attackFlags.IntVar(&assetParallelism, "asset-parallelism", tensile.DefaultAssetParallelism, "Assets of a page fetched at once with -page-assets")
+}
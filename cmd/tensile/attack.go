@@ -0,0 +1,634 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+// Target, budget and concurrency of an attack, bound to their flags
+type attackOptions struct {
+	url        string
+	requests   int
+	concurrent int
+}
+
+var (
+	opts attackOptions
+
+	numCPU, maxCPU, maxErr              int
+	debugN, saveErrorsMax, retries      int
+	maxIdle, maxIdlePerHost, maxPerHost int
+	transportShards                     int
+	debugBody, retryAll                 bool
+	rate                                float64
+	retryBackoff, hedge, duration       time.Duration
+	maxDuration, drainTimeout           time.Duration
+	longPoll, prewarm, continueOnError  bool
+	slowLog                             time.Duration
+	saveErrors                          string
+	seed                                int64
+
+	flagErr, recordFile string
+	targetsFile         string
+	targets             []tensile.Target
+	runTags             = make(tensile.Tags)
+	reqsError           = "ERROR: -requests (-r) must be greater than 0, or 0 with -duration\n"
+	maxError            = "ERROR: -concurrent (-c) must be greater than 0\n"
+	maxErrError         = "ERROR: -maxerror (-e) must be greater than 0, or -1 for unlimited\n"
+	continueError       = "ERROR: -continue-on-error can't be used with -maxerror (-e)\n"
+	rateError           = "ERROR: -rate must not be negative\n"
+	maxDurationError    = "ERROR: -max-duration must not be negative\n"
+	drainError          = "ERROR: -drain-timeout must not be negative, and needs -duration or -max-duration\n"
+	poolError           = "ERROR: -max-idle-conns, -max-idle-conns-per-host, -max-conns-per-host and -transport-shards must not be negative\n"
+	longPollError       = "ERROR: -long-poll can't be used with -rate, -pattern or -burst\n"
+	targetsError        = "ERROR: unable to load -targets: %s\n"
+	urlError            = "ERROR: -url (-u) cannot be blank\n"
+	schemeError         = "ERROR: unsupported protocol scheme %s\n"
+	urlPatternError     = "ERROR: invalid -url (-u) pattern: %s\n"
+	urlAgentsError      = "ERROR: -url (-u) patterns can't be used with -agents\n"
+	stdinError          = "ERROR: only one of -body and -targets can be read from stdin\n"
+	cpuWarn             = "NOTICE: -cpu=%d is greater than the number of CPUs on this system\n\tChanging -cpu to %d\n\n"
+	cpuLTE0Warn         = "NOTICE: -cpu=%d is less than 1\n\tChanging -cpu to 1\n\n"
+	maxGTreqsWarn       = "NOTICE: -concurrent=%d is greater than -requests\n\tChanging -concurrent to %d\n\n"
+	fdError             = "ERROR: %d connections need about %d open files, over the limit of %d (hard limit %d)\n\tRaise it with ulimit -n, or lower -concurrent\n"
+	fdRaised            = "NOTICE: open file limit raised from %d to %d\n\n"
+
+	attackFlags = flag.NewFlagSet("attack", flag.ExitOnError)
+)
+
+func init() {
+	maxCPU = runtime.NumCPU()
+	attackFlags.Usage = usageFor(attackFlags, "tensile [attack] [flags]")
+	attackFlags.IntVar(&numCPU, "cpu", 1, "Number of CPUs")
+	attackFlags.IntVar(&opts.requests, "requests", 50, "Total requests")
+	attackFlags.IntVar(&opts.requests, "r", 50, "Total requests (short flag)")
+	attackFlags.DurationVar(&duration, "duration", 0, "Run for this long; -requests then defaults to no limit")
+	attackFlags.DurationVar(&maxDuration, "max-duration", 0, "Hard cap on the run: stop sending after this long, cancel requests still in flight after -drain-timeout and exit with status 1")
+	attackFlags.DurationVar(&drainTimeout, "drain-timeout", 0, "Cancel requests still in flight this long after -duration or -max-duration, 0 for at once (default waits after -duration, 5s after -max-duration)")
+	attackFlags.Float64Var(&rate, "rate", 0, "Requests per second, 0 for as fast as -concurrent allows")
+	attackFlags.IntVar(&opts.concurrent, "concurrent", 5, "Maximum concurrent requests")
+	attackFlags.IntVar(&opts.concurrent, "c", 5, "Maximum concurrent requests (short flag)")
+	attackFlags.IntVar(&maxErr, "maxerror", 1, "Maximum errors before exiting")
+	attackFlags.IntVar(&maxErr, "e", 1, "Maximum errors before exiting (short flag)")
+	attackFlags.BoolVar(&continueOnError, "continue-on-error", false, "Never stop on errors, but exit with status 1 if there were any, or if -threshold is set, if it is exceeded")
+	attackFlags.StringVar(&opts.url, "url", "http://localhost/", "Target URL")
+	attackFlags.StringVar(&opts.url, "u", "http://localhost/", "Target URL (short flag)")
+	attackFlags.StringVar(&targetsFile, "targets", "", "File of target URLs, one per line, optionally preceded by a name, or - to read them from stdin")
+	attackFlags.StringVar(&recordFile, "record", "", "Record raw results to a file for 'tensile report'")
+	attackFlags.IntVar(&debugN, "debug", 0, "Dump request and response headers of the first N exchanges to stderr")
+	attackFlags.BoolVar(&debugBody, "debug-body", false, "Include bodies in -debug dumps")
+	attackFlags.StringVar(&saveErrors, "save-errors", "", "Save failed exchanges to files in this directory")
+	attackFlags.IntVar(&saveErrorsMax, "save-errors-max", 100, "Maximum failed exchanges to save with -save-errors")
+	attackFlags.IntVar(&retries, "retries", 0, "Retry transient failures up to N times")
+	attackFlags.DurationVar(&retryBackoff, "retry-backoff", 100*time.Millisecond, "Initial retry backoff, doubled on each retry, with jitter")
+	attackFlags.BoolVar(&retryAll, "retry-all", false, "Retry non-idempotent methods too")
+	attackFlags.DurationVar(&hedge, "hedge", 0, "Send a duplicate request if the first hasn't answered within this delay")
+	attackFlags.IntVar(&maxIdle, "max-idle-conns", 0, "Idle connections kept over all hosts, 0 for no limit")
+	attackFlags.IntVar(&maxIdlePerHost, "max-idle-conns-per-host", 0, "Idle connections kept per host, 0 for -concurrent")
+	attackFlags.IntVar(&maxPerHost, "max-conns-per-host", 0, "Connections per host, 0 for no limit")
+	attackFlags.IntVar(&transportShards, "transport-shards", 0, "Connection pools the workers are spread over, 0 for one per 256 workers up to -cpu")
+	attackFlags.BoolVar(&prewarm, "prewarm", false, "Open -concurrent keep-alive connections, with TLS, before the clock starts")
+	attackFlags.BoolVar(&longPoll, "long-poll", false, "Long poll: each of -concurrent users polls again as soon as answered, with no timeout")
+	attackFlags.DurationVar(&slowLog, "slow-log", 0, "Log the URL, timings and response headers of requests taking at least this long")
+	attackFlags.Var(runTags, "tag", "Metadata key=value recorded in every output (repeatable)")
+	attackFlags.Int64Var(&seed, "seed", 0, "Seed of the random choices of the run, to repeat one exactly, 0 for a random seed reported in the results")
+}
+
+func checkFlags(args []string) {
+	attackFlags.Parse(args)
+	if profileName != "" {
+		if err := loadProfile(profileName); err != nil {
+			log.Fatal(err)
+		}
+	}
+	setupLogging()
+	if sweepStr != "" || bodySizeSweep != "" {
+		duration = sweepDuration
+	}
+	if duration > 0 && !flagSet(attackFlags, "requests") {
+		opts.requests = 0
+	}
+	// Flag Errors
+	if opts.requests < 0 || (opts.requests == 0 && duration <= 0) {
+		flagErr += reqsError
+	}
+	if opts.concurrent <= 0 {
+		flagErr += maxError
+	}
+	if maxErr == 0 || maxErr < -1 {
+		flagErr += maxErrError
+	}
+	if continueOnError {
+		if flagSet(attackFlags, "maxerror") {
+			flagErr += continueError
+		}
+		maxErr = -1
+	}
+	if rate < 0 {
+		flagErr += rateError
+	}
+	if maxDuration < 0 {
+		flagErr += maxDurationError
+	}
+	if flagSet(attackFlags, "drain-timeout") {
+		if drainTimeout < 0 || (duration <= 0 && maxDuration <= 0) {
+			flagErr += drainError
+		}
+		if drainTimeout == 0 {
+			// Cancel at once
+			drainTimeout = -1
+		}
+	}
+	if maxIdle < 0 || maxIdlePerHost < 0 || maxPerHost < 0 || transportShards < 0 {
+		flagErr += poolError
+	}
+	var perr error
+	if loadPattern, perr = parsePattern(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = parseBurst(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = parseReplay(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = parseSample(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = parseSocket(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = parseBreaker(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = checkNotifyURL(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = checkUpload(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = checkHistory(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = checkAgents(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = checkHosts(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = parseUserAgents(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = checkFuzz(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = parseHeaderSize(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = parseMaxBandwidth(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = checkStream(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = loadPlugin(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = checkJSONMetrics(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if bodySizeSweep != "" {
+		if _, perr = parseBodySweep(bodySizeSweep); perr != nil {
+			flagErr += perr.Error()
+		}
+	}
+	if sqlitePath != "" && sqliteDriver == "" {
+		flagErr += sqliteBuildError
+	}
+	if sqlitePath != "" && (tabulated() || checkpoint > 0 || autoConcurrency || targetP99 > 0) {
+		flagErr += sqliteModeError
+	}
+	if perr = checkCompareHost(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if assetParallelism < 1 {
+		flagErr += assetParallelismError
+	}
+	if longPoll && (rate > 0 || loadPattern != nil || burstN > 0) {
+		flagErr += longPollError
+	}
+	if perr = parseChecks(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = parseBody(); perr != nil {
+		flagErr += perr.Error()
+	} else if perr = parseGRPC(); perr != nil {
+		flagErr += perr.Error()
+	} else if perr = parseMethodMix(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if targetsFile == "-" && bodyFile == "-" {
+		flagErr += stdinError
+	} else if targetsFile != "" {
+		if targets, perr = loadTargets(targetsFile); perr != nil {
+			flagErr += fmt.Sprintf(targetsError, perr)
+		}
+	}
+	if sitemapURL != "" && targetsFile != "" {
+		flagErr += sitemapTargetsError
+	} else if sitemapURL != "" {
+		if targets, perr = loadSitemap(sitemapURL); perr != nil {
+			flagErr += fmt.Sprintf(sitemapError, perr)
+		}
+	}
+	if scenarioFile != "" && (targetsFile != "" || sitemapURL != "") {
+		flagErr += scenarioError
+	} else if scenarioFile != "" {
+		if targets, perr = loadScenario(scenarioFile); perr != nil {
+			flagErr += fmt.Sprintf(scenarioLoadError, perr)
+		}
+	}
+	if targetsFile != "" || sitemapURL != "" || scenarioFile != "" {
+		// Targets replace -url
+		opts.url = ""
+	} else if opts.url == "" {
+		flagErr += urlError
+	} else if perr = expandURL(); perr != nil {
+		flagErr += perr.Error()
+	}
+	if perr = checkSpider(); perr != nil {
+		flagErr += perr.Error()
+	} else if spider && flagErr == "" {
+		if targets, perr = crawl(opts.url); perr != nil {
+			flagErr += fmt.Sprintf(spiderCrawlError, perr)
+		}
+	}
+	if flagErr != "" {
+		log.Fatal(fmt.Errorf("\n%s", flagErr))
+	}
+	// Flag Warnings
+	if numCPU > maxCPU {
+		infof(cpuWarn, numCPU, maxCPU)
+		numCPU = maxCPU
+	}
+	if numCPU < 1 {
+		infof(cpuLTE0Warn, numCPU)
+		numCPU = 1
+	}
+	if opts.requests > 0 && opts.concurrent > opts.requests {
+		infof(maxGTreqsWarn, opts.concurrent, opts.requests)
+		opts.concurrent = opts.requests
+	}
+	if err := checkFileLimit(connections()); err != nil {
+		log.Fatal(fmt.Errorf("\n%s", err))
+	}
+}
+
+// Most connections the attack may have open at once
+func connections() int {
+	n := opts.concurrent
+	if autoConcurrency {
+		n = autoMax
+	}
+	if hedge > 0 {
+		// Each request may have a hedged duplicate in flight
+		n *= 2
+	}
+	return n
+}
+
+// Most URLs a -url pattern may expand to
+const maxExpanded = 1000000
+
+// Check -url, expanding any patterns in it into targets named for it
+func expandURL() error {
+	urls, err := tensile.ExpandURL(opts.url, maxExpanded)
+	if err != nil {
+		return fmt.Errorf(urlPatternError, err)
+	}
+	for _, s := range urls {
+		if u, err := url.Parse(s); err != nil {
+			return fmt.Errorf("%s\n", err)
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf(schemeError, u.Scheme)
+		}
+	}
+	if len(urls) == 1 {
+		opts.url = urls[0]
+		return nil
+	}
+	if agentsStr != "" {
+		return errors.New(urlAgentsError)
+	}
+	targets = make([]tensile.Target, len(urls))
+	for i, u := range urls {
+		targets[i] = tensile.Target{Name: opts.url, URL: u}
+	}
+	return nil
+}
+
+// Load targets from a file, or stdin if path is -
+func loadTargets(path string) ([]tensile.Target, error) {
+	if path == "-" {
+		return tensile.ParseTargets(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tensile.ParseTargets(f)
+}
+
+// Config built from o and the other attack flags
+func config(o attackOptions) tensile.Config {
+	return tensile.Config{
+		URL:                 o.url,
+		Requests:            o.requests,
+		Concurrent:          o.concurrent,
+		MaxErrors:           maxErr,
+		Tags:                runTags,
+		Method:              method,
+		Methods:             methods,
+		Breaker:             breakerCfg,
+		Body:                body,
+		GRPC:                grpcMode,
+		GraphQL:             graphqlFile != "",
+		LongPoll:            longPoll,
+		Prewarm:             prewarm,
+		LocalAddrs:          localAddrs,
+		Network:             network,
+		DNSOnce:             dns == "once",
+		DNSServer:           dnsServer,
+		Hosts:               hosts,
+		SpreadAddrs:         spreadAddrs,
+		ByBackend:           byBackend,
+		TLSResume:           tlsResume,
+		PortMin:             portMin,
+		PortMax:             portMax,
+		Nagle:               !tcpNoDelay,
+		Linger:              linger,
+		SendBuffer:          sendBufBytes,
+		RecvBuffer:          recvBufBytes,
+		MaxIdleConns:        maxIdle,
+		MaxIdleConnsPerHost: maxIdlePerHost,
+		MaxConnsPerHost:     maxPerHost,
+		TransportShards:     transportShards,
+		Chunked:             chunked,
+		ChunkSize:           int(chunkBytes),
+		ChunkDelay:          chunkDelay,
+		ExpectContinue:      expectContinue,
+		Targets:             targets,
+		Duration:            duration,
+		MaxDuration:         maxDuration,
+		DrainTimeout:        drainTimeout,
+		Rate:                rate,
+		Pattern:             loadPattern,
+		Burst:               burstN,
+		BurstInterval:       burstEvery,
+		Debug:               debugN,
+		DebugBodies:         debugBody,
+		SaveErrors:          saveErrors,
+		SaveErrorsMax:       saveErrorsMax,
+		Retries:             retries,
+		RetryBackoff:        retryBackoff,
+		RetryAll:            retryAll,
+		Hedge:               hedge,
+		SlowLog:             slowLog,
+		ExpectSHA256:        expectSHA256,
+		HeaderChecks:        headerChecks,
+		AuditHeaders:        auditHeaders(),
+		Compress:            compress,
+		MaxDecoded:          compressMax,
+		Revalidate:          revalidate,
+		PageAssets:          pageAssets,
+		CompareHost:         compareHost,
+		CompareIgnore:       splitList(compareIgnore),
+		AssetParallelism:    assetParallelism,
+		UserAgents:          userAgents,
+		FuzzHeaders:         splitList(fuzzHeaders),
+		FuzzParams:          splitList(fuzzParams),
+		FuzzMaxLen:          fuzzMaxLen,
+		HeaderSize:          int(headerSize),
+		Seed:                seed,
+		RequestModifier:     requestModifier,
+		ResponseValidator:   responseValidator,
+		JSONMetrics:         jsonMetrics,
+		MaxBandwidth:        maxBandwidth,
+		Stream:              stream,
+		StallThreshold:      stallThreshold,
+		CacheBust:           cacheBust,
+		Range:               byteRange,
+		RandomRange:         randomRange,
+	}
+}
+
+// Writer of raw results, e.g. a tensile.Recorder
+type resultWriter interface {
+	Write(tensile.Result) error
+	Close() error
+}
+
+// Destinations of raw results: a recording, an SQLite database, or both
+type sink []resultWriter
+
+func (s sink) Write(r tensile.Result) error {
+	var err error
+	for _, w := range s {
+		if werr := w.Write(r); err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+func (s sink) Close() error {
+	var err error
+	for _, w := range s {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Run the attack locally, streaming results to rec if it has destinations
+func attack(cfg tensile.Config, rec sink) (tensile.Results, error) {
+	if len(rec) == 0 {
+		return tensile.NewAttacker(tensile.WithConfig(cfg)).Attack(runCtx)
+	}
+	stream := make(chan tensile.Result, opts.concurrent)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for r := range stream {
+			if err := rec.Write(r); err != nil {
+				slog.Error("recording result", "err", err)
+			}
+		}
+	}()
+	res, err := tensile.NewAttacker(tensile.WithConfig(cfg), tensile.WithResults(stream)).Attack(runCtx)
+	<-done
+	return res, err
+}
+
+// Attack subcommand
+func attackCmd(args []string) {
+	checkFlags(args)
+	ts, err := tensile.ParseThresholds(thresholdStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, ok := reportFormats[outputFormat]; !ok {
+		log.Fatal(fmt.Errorf(formatError, outputFormat))
+	}
+	agents := splitList(agentsStr)
+	infof("\n\t%s\n\n", tensile.App+tensile.Version)
+	runtime.GOMAXPROCS(numCPU)
+	if hostsStr != "" {
+		infof("Hosts:\t\t%s\n", hostsStr)
+	}
+	if len(targets) > 0 {
+		infof("Targets:\t%d\n", len(targets))
+	} else {
+		infof("Target URL:\t%s\n", opts.url)
+	}
+	if opts.requests > 0 {
+		infof("Requests:\t%d\n", opts.requests)
+	}
+	if duration > 0 {
+		infof("Duration:\t%s\n", duration)
+	}
+	if maxDuration > 0 {
+		infof("Max duration:\t%s\n", maxDuration)
+	}
+	switch {
+	case autoConcurrency:
+		infof("Concurrent:\tauto, up to %d\n", autoMax)
+	case sweepStr != "":
+		infof("Concurrent:\tsweep %s\n", sweepStr)
+	default:
+		infof("Concurrent:\t%d\n", opts.concurrent)
+	}
+	if rate > 0 {
+		infof("Rate:\t\t%g/s\n", rate)
+	}
+	if patternName != "" {
+		infof("Pattern:\t%s\n", patternName)
+	}
+	if burstN > 0 {
+		infof("Burst:\t\t%d every %s\n", burstN, burstEvery)
+	}
+	if bodySizeSweep != "" {
+		infof("Body sizes:\t%s\n", bodySizeSweep)
+	}
+	if methodMix != "" {
+		infof("Methods:\t%s\n", methodMix)
+	}
+	if seed != 0 {
+		infof("Seed:\t\t%d\n", seed)
+	}
+	infof("Processors:\t%d\n", numCPU)
+	if n := config(opts).Shards(); n > 1 && !autoConcurrency && sweepStr == "" {
+		infof("Transports:\t%d, of up to %d workers each\n", n, (opts.concurrent+n-1)/n)
+	}
+	if len(agents) > 0 {
+		infof("Agents:\t\t%d\n", len(agents))
+	}
+	infof("\n")
+	if sweepStr != "" {
+		sweep()
+		return
+	}
+	if bodySizeSweep != "" {
+		bodySweep()
+		return
+	}
+	if hostsStr != "" {
+		compareHosts()
+		return
+	}
+	var rec sink
+	info := tensile.RunInfo{Version: tensile.Version, URL: opts.url, Requests: opts.requests, Concurrent: opts.concurrent, Start: time.Now(), Tags: runTags, Sample: sample}
+	if recordFile != "" && checkpoint == 0 {
+		r, err := tensile.CreateRecorder(recordFile, info)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rec = append(rec, r)
+	}
+	if sqlitePath != "" {
+		db, err := createSQLite(sqlitePath, info)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rec = append(rec, db)
+	}
+	// Stop on a signal and report what was gathered, notifying if asked
+	ctx, stop := signal.NotifyContext(runCtx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	runCtx = ctx
+	infof("Waiting for replies...\n\n")
+	var res tensile.Results
+	if len(agents) > 0 {
+		res = distribute(runCtx, agents, rec)
+	} else if autoConcurrency {
+		res = findConcurrency()
+	} else if targetP99 > 0 {
+		res = findRate()
+	} else if checkpoint > 0 {
+		res, err = soak(config(opts))
+	} else {
+		res, err = attack(config(opts), rec)
+	}
+	if len(rec) > 0 {
+		if err := rec.Close(); err != nil {
+			slog.Error("closing recording", "err", err)
+		}
+	}
+	// An interrupted attack still reports the results it gathered
+	interrupted := errors.Is(err, context.Canceled)
+	if err != nil && !interrupted {
+		notify(res, nil, false, err)
+		log.Fatal(err)
+	}
+	if interrupted {
+		slog.Warn("interrupted, reporting partial results")
+	}
+	if res.Errors > 0 {
+		slog.Error("total errors", "errors", res.Errors)
+	}
+	if err := writeReport(res); err != nil {
+		log.Fatal(fmt.Errorf(reportWriteError, err))
+	}
+	if historyDir != "" {
+		recordHistory(info.Start, res)
+	}
+	if uploadTo != nil {
+		upload(uploadTo, info.Start, res)
+	}
+	outcomes, ok := thresholdOutcomes(ts, res)
+	notify(res, outcomes, ok, err)
+	if !ok || interrupted {
+		os.Exit(1)
+	}
+	if res.Stopped == tensile.StopMaxDuration {
+		os.Exit(1)
+	}
+	// Without thresholds as an error budget, any error fails the run
+	if continueOnError && len(ts) == 0 && res.Errors > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	autoConcurrency bool
+	autoMax         int
+	limitP99        time.Duration
+	limitErrors     float64
+	targetP99       time.Duration
+
+	autoRecordError = "ERROR: -record is not supported with -auto-concurrency or -target-p99\n"
+	autoMaxError    = "ERROR: -auto-max must be greater than 0\n"
+	autoHeader      = "%-12s%-18s%-16s%-10s%s\n"
+	autoRow         = "%-12d%-18s%-16s%-10s%t\n"
+	autoResult      = "Saturation point:\t%d concurrent, %.2f req/s, p99 %s\n\n"
+	rateRow         = "%-12s%-18s%-16s%-10s%t\n"
+	rateResult      = "Capacity:\t%g req/s, p99 %s\n\n"
+)
+
+func init() {
+	attackFlags.BoolVar(&autoConcurrency, "auto-concurrency", false, "Search for the concurrency giving the highest throughput within -limit-p99 and -limit-errors")
+	attackFlags.IntVar(&autoMax, "auto-max", 1024, "Highest concurrency tried by -auto-concurrency")
+	attackFlags.DurationVar(&limitP99, "limit-p99", time.Second, "Highest acceptable p99 latency for -auto-concurrency, 0 for no limit")
+	attackFlags.Float64Var(&limitErrors, "limit-errors", 1, "Highest acceptable error rate percentage for -auto-concurrency and -target-p99")
+	attackFlags.DurationVar(&targetP99, "target-p99", 0, "Search for the highest rate sustained with p99 latency under this target")
+}
+
+// Search for the saturation point, printing each level tried, and return the
+// results at that point
+func findConcurrency() tensile.Results {
+	if recordFile != "" {
+		log.Fatal(fmt.Errorf("\n%s", autoRecordError))
+	}
+	if autoMax <= 0 {
+		log.Fatal(fmt.Errorf("\n%s", autoMaxError))
+	}
+	lim := tensile.Limits{P99: limitP99, ErrorRate: limitErrors / 100}
+	infof(autoHeader, "Concurrent", "Throughput", "p99", "Errors", "Within limits")
+	best, err := tensile.FindConcurrency(context.Background(), config(opts), lim, autoMax, func(l tensile.Level) {
+		infof(autoRow, l.Concurrent, fmt.Sprintf("%.2f req/s", l.Results.Throughput), l.Results.Percentiles["p99"], fmt.Sprintf("%.2f%%", l.Results.ErrorRate*100), l.Within)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	infof("\n"+autoResult, best.Concurrent, best.Results.Throughput, best.Results.Percentiles["p99"])
+	return best.Results
+}
+
+// Search for the highest rate within -target-p99, printing each rate tried,
+// and return the results at that rate
+func findRate() tensile.Results {
+	if recordFile != "" {
+		log.Fatal(fmt.Errorf("\n%s", autoRecordError))
+	}
+	lim := tensile.Limits{P99: targetP99, ErrorRate: limitErrors / 100}
+	infof(autoHeader, "Rate", "Throughput", "p99", "Errors", "Within limits")
+	best, err := tensile.FindRate(context.Background(), config(opts), lim, func(l tensile.Level) {
+		infof(rateRow, fmt.Sprintf("%g/s", l.Rate), fmt.Sprintf("%.2f req/s", l.Results.Throughput), l.Results.Percentiles["p99"], fmt.Sprintf("%.2f%%", l.Results.ErrorRate*100), l.Within)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	infof("\n"+rateResult, best.Rate, best.Results.Percentiles["p99"])
+	return best.Results
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	maxBandwidthStr string
+	maxBandwidth    int64
+
+	bandwidthError = "ERROR: invalid -max-bandwidth %q, expected a rate such as 1Mbps or 64KB/s\n"
+)
+
+func init() {
+	attackFlags.StringVar(&maxBandwidthStr, "max-bandwidth", "", "Read each response at up to this rate, such as 1Mbps or 64KB/s, as a slow client would")
+}
+
+// Parse a bandwidth, in bits a second such as 1Mbps, or bytes a second such
+// as 64KB/s, into bytes a second
+func parseBandwidth(s string) (int64, error) {
+	if size, ok := strings.CutSuffix(s, "/s"); ok {
+		return parseByteSize(size)
+	}
+	lower := strings.ToLower(strings.TrimSpace(s))
+	for _, u := range []struct {
+		suffix string
+		bits   float64
+	}{{"gbps", 1e9}, {"mbps", 1e6}, {"kbps", 1e3}, {"bps", 1}} {
+		if n, ok := strings.CutSuffix(lower, u.suffix); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+			if err != nil {
+				return 0, err
+			}
+			return int64(f * u.bits / 8), nil
+		}
+	}
+	return 0, fmt.Errorf("no unit")
+}
+
+// Parse -max-bandwidth
+func parseMaxBandwidth() error {
+	if maxBandwidthStr == "" {
+		return nil
+	}
+	n, err := parseBandwidth(maxBandwidthStr)
+	if err != nil || n <= 0 {
+		return fmt.Errorf(bandwidthError, maxBandwidthStr)
+	}
+	maxBandwidth = n
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+const octetStream = "application/octet-stream"
+
+var (
+	method         string
+	formFields     []tensile.FormField
+	formData       = make(url.Values)
+	jsonStr        string
+	bodyFile       string
+	bodySize       string
+	bodyRandom     bool
+	graphqlFile    string
+	variablesFile  string
+	chunked        bool
+	chunkSize      string
+	chunkBytes     int64
+	chunkDelay     time.Duration
+	expectContinue time.Duration
+	body           tensile.Body
+	formError      = "ERROR: unable to read -form file: %s\n"
+	jsonError      = "ERROR: -json is not valid JSON\n"
+	bodyError      = "ERROR: only one of -form, -form-data, -json, -body, -body-size, -body-size-sweep and -graphql can be used\n"
+	graphqlError   = "ERROR: unable to read -graphql query: %s\n"
+	variablesError = "ERROR: -variables must be a file of a JSON object, used with -graphql\n"
+	bodyFileError  = "ERROR: unable to read -body: %s\n"
+	bodySizeError  = "ERROR: invalid -body-size %q, expected a size such as 10MB\n"
+	chunkedError   = "ERROR: -chunked needs a request body\n"
+	chunkSizeError = "ERROR: invalid -chunk-size %q, expected a size such as 4KB\n"
+)
+
+func init() {
+	attackFlags.StringVar(&method, "method", "", "Request method, GET, or POST if there is a body")
+	attackFlags.Var(formFlag{}, "form", "Multipart form field name=value, or name=@file to upload a file (repeatable)")
+	attackFlags.Var(formDataFlag{}, "form-data", "URL encoded form field name=value (repeatable)")
+	attackFlags.StringVar(&jsonStr, "json", "", "JSON request body")
+	attackFlags.StringVar(&bodyFile, "body", "", "File streamed as the request body, or - to read it from stdin")
+	attackFlags.StringVar(&bodySize, "body-size", "", "Generate a request body of this size, e.g. 10MB, of zeros")
+	attackFlags.BoolVar(&bodyRandom, "body-random", false, "Fill the -body-size body with random data rather than zeros")
+	attackFlags.StringVar(&graphqlFile, "graphql", "", "File of a GraphQL query POSTed as the request body; responses with GraphQL errors fail")
+	attackFlags.StringVar(&variablesFile, "variables", "", "File of the JSON variables of the -graphql query")
+	attackFlags.DurationVar(&expectContinue, "expect-continue", 0, "Send request bodies with Expect: 100-continue, waiting up to this long for the server")
+	attackFlags.BoolVar(&chunked, "chunked", false, "Send the request body with chunked transfer encoding")
+	attackFlags.StringVar(&chunkSize, "chunk-size", "", "Maximum size of each chunk with -chunked, e.g. 4KB")
+	attackFlags.DurationVar(&chunkDelay, "chunk-delay", 0, "Delay between chunks with -chunked, to simulate slow uploads")
+}
+
+// Repeatable multipart form field flag
+type formFlag struct{}
+
+// String returns the form fields, one per line
+func (formFlag) String() string {
+	l := make([]string, len(formFields))
+	for i, f := range formFields {
+		v := f.Value
+		if f.File {
+			v = "@" + v
+		}
+		l[i] = f.Name + "=" + v
+	}
+	return strings.Join(l, "\n")
+}
+
+// Set adds form fields, one per line
+func (formFlag) Set(s string) error {
+	for _, kv := range strings.Split(s, "\n") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid form field %q, expected name=value or name=@file", kv)
+		}
+		f := tensile.FormField{Name: k, Value: v}
+		if path, ok := strings.CutPrefix(v, "@"); ok {
+			f.Value, f.File = path, true
+		}
+		formFields = append(formFields, f)
+	}
+	return nil
+}
+
+// Repeatable URL encoded form field flag
+type formDataFlag struct{}
+
+// String returns the form encoded
+func (formDataFlag) String() string {
+	return formData.Encode()
+}
+
+// Set adds form fields, as name=value or encoded
+func (formDataFlag) Set(s string) error {
+	v, err := url.ParseQuery(s)
+	if err != nil {
+		return err
+	}
+	for k, vs := range v {
+		formData[k] = append(formData[k], vs...)
+	}
+	return nil
+}
+
+// Build the request body from the body flags
+func parseBody() error {
+	n := 0
+	for _, set := range []bool{len(formFields) > 0, len(formData) > 0, jsonStr != "", bodyFile != "", bodySize != "", graphqlFile != "", bodySizeSweep != ""} {
+		if set {
+			n++
+		}
+	}
+	switch {
+	case n > 1:
+		return errors.New(bodyError)
+	case len(formFields) > 0:
+		for _, f := range formFields {
+			if _, err := os.Stat(f.Value); f.File && err != nil {
+				return fmt.Errorf(formError, err)
+			}
+		}
+		body = tensile.NewMultipart(formFields)
+	case len(formData) > 0:
+		body = tensile.FormBody(formData)
+	case jsonStr != "":
+		b, err := tensile.JSONBody(jsonStr)
+		if err != nil {
+			return errors.New(jsonError)
+		}
+		body = b
+	case bodyFile == "-":
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf(bodyFileError, err)
+		}
+		body = tensile.Bytes{Data: b, Type: octetStream}
+	case bodyFile != "":
+		if _, err := os.Stat(bodyFile); err != nil {
+			return fmt.Errorf(bodyFileError, err)
+		}
+		body = tensile.File{Path: bodyFile, Type: octetStream}
+	case bodySize != "":
+		n, err := parseByteSize(bodySize)
+		if err != nil {
+			return fmt.Errorf(bodySizeError, bodySize)
+		}
+		body = tensile.NewSynthetic(n, bodyRandom)
+	case graphqlFile != "":
+		q, err := os.ReadFile(graphqlFile)
+		if err != nil {
+			return fmt.Errorf(graphqlError, err)
+		}
+		var vars []byte
+		if variablesFile != "" {
+			if vars, err = os.ReadFile(variablesFile); err != nil {
+				return errors.New(variablesError)
+			}
+		}
+		b, err := tensile.GraphQLBody(string(q), vars)
+		if err != nil {
+			return errors.New(variablesError)
+		}
+		body = b
+	}
+	if variablesFile != "" && graphqlFile == "" {
+		return errors.New(variablesError)
+	}
+	if chunked && body == nil {
+		return errors.New(chunkedError)
+	}
+	if chunkSize != "" {
+		var err error
+		if chunkBytes, err = parseByteSize(chunkSize); err != nil {
+			return fmt.Errorf(chunkSizeError, chunkSize)
+		}
+	}
+	if method == "" && (body != nil || bodySizeSweep != "") {
+		method = "POST"
+	}
+	return nil
+}
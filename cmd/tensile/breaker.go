@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	breakerStr      string
+	breakerWindow   time.Duration
+	breakerCooldown time.Duration
+	breakerCfg      *tensile.Breaker
+	breakerError    = "ERROR: invalid -breaker %q, expected an error rate such as 50%%\n"
+	breakerDurError = "ERROR: -breaker-window and -breaker-cooldown must be greater than 0\n"
+)
+
+func init() {
+	attackFlags.StringVar(&breakerStr, "breaker", "", "Pause dispatching when the error rate over -breaker-window reaches this, e.g. 50%")
+	attackFlags.DurationVar(&breakerWindow, "breaker-window", 10*time.Second, "Rolling window of the -breaker error rate")
+	attackFlags.DurationVar(&breakerCooldown, "breaker-cooldown", 30*time.Second, "How long -breaker pauses dispatching for")
+}
+
+// Parse -breaker as a percentage
+func parseBreaker() error {
+	if breakerStr == "" {
+		return nil
+	}
+	s, pct := strings.CutSuffix(breakerStr, "%")
+	rate, err := strconv.ParseFloat(s, 64)
+	if pct {
+		rate /= 100
+	}
+	if err != nil || rate <= 0 || rate > 1 {
+		return fmt.Errorf(breakerError, breakerStr)
+	}
+	if breakerWindow <= 0 || breakerCooldown <= 0 {
+		return errors.New(breakerDurError)
+	}
+	breakerCfg = &tensile.Breaker{ErrorRate: rate, Window: breakerWindow, Cooldown: breakerCooldown}
+	return nil
+}
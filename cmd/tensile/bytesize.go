@@ -2,7 +2,11 @@
 
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type byteSize float64
 
@@ -39,3 +43,23 @@ func (b byteSize) String() string {
 	}
 	return fmt.Sprintf("%.2fB", b)
 }
+
+// Parse a size such as 512, 64KB or 10MB, with the same units as String
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	unit := byteSize(1)
+	for _, u := range []struct {
+		suffix string
+		size   byteSize
+	}{{"KB", kb}, {"MB", mb}, {"GB", gb}, {"TB", tb}, {"B", 1}} {
+		if n, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, unit = n, u.size
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(f * float64(unit)), nil
+}
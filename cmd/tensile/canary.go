@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	compareHost, compareIgnore string
+
+	compareHostError = "ERROR: -compare-host must be a host or host:port\n"
+)
+
+func init() {
+	attackFlags.StringVar(&compareHost, "compare-host", "", "Also send every request to this host or host:port, and report how often the responses differ")
+	attackFlags.StringVar(&compareIgnore, "compare-ignore", "Date,Age,Expires,Set-Cookie,Server-Timing,X-Request-Id", "Comma separated response headers not compared with -compare-host")
+}
+
+// Check -compare-host is only a host, and port
+func checkCompareHost() error {
+	if strings.ContainsAny(compareHost, "/?#@ ") {
+		return errors.New(compareHostError)
+	}
+	return nil
+}
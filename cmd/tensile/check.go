@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	expectStr     string
+	expectSHA256  []byte
+	headerChecks  []tensile.HeaderCheck
+	audit         bool
+	revalidate    bool
+	cacheBust     bool
+	rangeStr      string
+	byteRange     *tensile.ByteRange
+	randomRange   int64
+	rangeError    = "ERROR: invalid -range %q, expected bytes=first-last\n"
+	randRangeErr  = "ERROR: -random-range must be greater than 0, and can't be used with -range\n"
+	auditList     string
+	compressList  string
+	compressMax   int64
+	compress      []string
+	compressError = "ERROR: unsupported -compress encoding %q, expected gzip, deflate or br\n"
+	brotliError   = "ERROR: -compress=br needs tensile built with -tags brotli\n"
+	sha256Error   = "ERROR: invalid -expect-sha256 %q, expected a hex SHA-256 hash\n"
+)
+
+func init() {
+	attackFlags.StringVar(&expectStr, "expect-sha256", "", "Fail responses whose body doesn't have this hex SHA-256 hash")
+	attackFlags.Var(headerFlag{}, "assert-header", "Fail responses without this \"Name: value\" header (repeatable)")
+	attackFlags.Var(headerFlag{match: true}, "assert-header-match", "Fail responses without a \"Name: regexp\" matching header (repeatable)")
+	attackFlags.StringVar(&compressList, "compress", "", "Comma separated encodings to request and decompress (gzip, deflate, br)")
+	attackFlags.Int64Var(&compressMax, "compress-max", tensile.DefaultMaxDecoded, "Maximum decompressed body size in bytes with -compress")
+	attackFlags.StringVar(&rangeStr, "range", "", "Request this range of bytes, e.g. bytes=0-1023, and check for 206 responses of it")
+	attackFlags.Int64Var(&randomRange, "random-range", 0, "Request random ranges of this many bytes, and check for 206 responses of them")
+	attackFlags.BoolVar(&cacheBust, "cache-bust", false, "Add a random query parameter to every request to bypass caches")
+	attackFlags.BoolVar(&revalidate, "revalidate", false, "Make requests conditional on the ETag and Last-Modified of the latest full response")
+	attackFlags.BoolVar(&audit, "audit", false, "Summarise the values of security and caching headers across all responses")
+	attackFlags.StringVar(&auditList, "audit-headers", strings.Join(tensile.DefaultAuditHeaders, ","), "Comma separated headers summarised by -audit")
+}
+
+// Repeatable header check flag, with a regexp value if match is set
+type headerFlag struct {
+	match bool
+}
+
+// String returns the header checks of the flag, one per line
+func (f headerFlag) String() string {
+	var l []string
+	for _, c := range headerChecks {
+		if (c.Pattern != nil) == f.match {
+			l = append(l, c.Name+": "+c.Value)
+		}
+	}
+	return strings.Join(l, "\n")
+}
+
+// Set adds header checks, one per line
+func (f headerFlag) Set(s string) error {
+	for _, hc := range strings.Split(s, "\n") {
+		name, v, ok := strings.Cut(hc, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return fmt.Errorf("invalid header check %q, expected \"Name: value\"", hc)
+		}
+		c := tensile.HeaderCheck{Name: name, Value: strings.TrimSpace(v)}
+		if f.match {
+			re, err := regexp.Compile(c.Value)
+			if err != nil {
+				return err
+			}
+			c.Pattern = re
+		}
+		headerChecks = append(headerChecks, c)
+	}
+	return nil
+}
+
+// Headers audited, if -audit is set
+func auditHeaders() []string {
+	if !audit {
+		return nil
+	}
+	names := splitList(auditList)
+	for i, name := range names {
+		names[i] = textproto.CanonicalMIMEHeaderKey(name)
+	}
+	return names
+}
+
+// Parse the response check flags
+func parseChecks() error {
+	compress = splitList(compressList)
+	for _, e := range compress {
+		if e == "br" && !tensile.Decodes(e) {
+			return errors.New(brotliError)
+		}
+		if !tensile.Decodes(e) {
+			return fmt.Errorf(compressError, e)
+		}
+	}
+	if randomRange < 0 || (randomRange > 0 && rangeStr != "") {
+		return errors.New(randRangeErr)
+	}
+	if rangeStr != "" {
+		b, err := tensile.ParseByteRange(rangeStr)
+		if err != nil {
+			return fmt.Errorf(rangeError, rangeStr)
+		}
+		byteRange = &b
+	}
+	if expectStr == "" {
+		return nil
+	}
+	var err error
+	if expectSHA256, err = hex.DecodeString(expectStr); err != nil || len(expectSHA256) != 32 {
+		return fmt.Errorf(sha256Error, expectStr)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	tolerance, errTolerance float64
+
+	compareFlags = flag.NewFlagSet("compare", flag.ExitOnError)
+
+	compareArgsError  = "ERROR: compare requires a baseline and a current JSON report or recording\n"
+	regressionsError  = "ERROR: %d regression(s) beyond tolerance\n"
+	toleranceError    = "ERROR: -tolerance and -error-tolerance cannot be negative\n"
+	compareRegression = "REGRESSION"
+)
+
+func init() {
+	compareFlags.Usage = usageFor(compareFlags, "tensile compare [flags] baseline.json|.bin current.json|.bin")
+	compareFlags.Float64Var(&tolerance, "tolerance", 10, "Allowed throughput and latency change, in percent")
+	compareFlags.Float64Var(&errTolerance, "error-tolerance", 0.5, "Allowed error rate increase, in percentage points")
+}
+
+// Relative change from a to b, in percent
+func change(a, b float64) float64 {
+	if a == 0 {
+		if b == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (b - a) / a * 100
+}
+
+// Compare subcommand
+func compareCmd(args []string) {
+	compareFlags.Parse(args)
+	setupLogging()
+	if compareFlags.NArg() != 2 {
+		compareFlags.Usage()
+		log.Fatal(fmt.Errorf("\n%s", compareArgsError))
+	}
+	if tolerance < 0 || errTolerance < 0 {
+		log.Fatal(fmt.Errorf("\n%s", toleranceError))
+	}
+	base, err := readResults(compareFlags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cur, err := readResults(compareFlags.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if regressions := compareTable(os.Stdout, base, cur); regressions > 0 {
+		log.Fatalf(regressionsError, regressions)
+	}
+}
+
+// Write a table of the changes from base to cur, returning how many are
+// regressions beyond -tolerance and -error-tolerance
+func compareTable(out io.Writer, base, cur tensile.Results) int {
+	regressions := 0
+	mark := func(bad bool) string {
+		if bad {
+			regressions++
+			return compareRegression
+		}
+		return ""
+	}
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	if len(base.Tags) > 0 || len(cur.Tags) > 0 {
+		fmt.Fprintf(w, "tags\t%s\t%s\t\t\n", base.Tags.List(), cur.Tags.List())
+	}
+	fmt.Fprintf(w, "Metric\tBaseline\tCurrent\tChange\t\n")
+	c := change(base.Throughput, cur.Throughput)
+	fmt.Fprintf(w, "throughput\t%.2f req/s\t%.2f req/s\t%+.2f%%\t%s\n", base.Throughput, cur.Throughput, c, mark(-c > tolerance))
+	d := (cur.ErrorRate - base.ErrorRate) * 100
+	fmt.Fprintf(w, "error rate\t%.2f%%\t%.2f%%\t%+.2fpp\t%s\n", base.ErrorRate*100, cur.ErrorRate*100, d, mark(d > errTolerance))
+	latency := func(name string, a, b time.Duration) {
+		c := change(float64(a), float64(b))
+		fmt.Fprintf(w, "%s\t%s\t%s\t%+.2f%%\t%s\n", name, a, b, c, mark(c > tolerance))
+	}
+	latency("mean", base.Mean, cur.Mean)
+	for _, p := range tensile.Percentiles {
+		latency(tensile.PercentileName(p), base.Percentiles[tensile.PercentileName(p)], cur.Percentiles[tensile.PercentileName(p)])
+	}
+	latency("max", base.Max, cur.Max)
+	w.Flush()
+	return regressions
+}
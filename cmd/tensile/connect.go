@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	connTarget                   string
+	connRequests, connConcurrent int
+	connRate                     float64
+	connDuration, connTimeout    time.Duration
+	connTLS, connResume          bool
+	connInsecure                 bool
+	connServerName               string
+
+	connectFlags = flag.NewFlagSet("connect", flag.ExitOnError)
+
+	connFormatError = "ERROR: unsupported -output format %q for connect, expected text or json\n"
+	connTLSError    = "ERROR: -resume, -insecure and -server-name need -tls\n"
+)
+
+func init() {
+	connectFlags.Usage = usageFor(connectFlags, "tensile connect [flags]")
+	connectFlags.StringVar(&connTarget, "target", "localhost:80", "Target host:port")
+	connectFlags.IntVar(&connRequests, "requests", 1000, "Total connections")
+	connectFlags.IntVar(&connRequests, "r", 1000, "Total connections (short flag)")
+	connectFlags.IntVar(&connConcurrent, "concurrent", 10, "Maximum concurrent connection attempts")
+	connectFlags.IntVar(&connConcurrent, "c", 10, "Maximum concurrent connection attempts (short flag)")
+	connectFlags.Float64Var(&connRate, "rate", 0, "Connections per second, 0 for as fast as -concurrent allows")
+	connectFlags.DurationVar(&connDuration, "duration", 0, "Run for this long; -requests then defaults to no limit")
+	connectFlags.DurationVar(&connTimeout, "timeout", 10*time.Second, "Connect timeout")
+	connectFlags.BoolVar(&connTLS, "tls", false, "Make a full TLS handshake on each connection before closing it")
+	connectFlags.BoolVar(&connResume, "resume", false, "Resume the TLS session of an earlier handshake")
+	connectFlags.BoolVar(&connInsecure, "insecure", false, "Don't verify the TLS certificate")
+	connectFlags.StringVar(&connServerName, "server-name", "", "TLS server name, the -target host if empty")
+	connectFlags.StringVar(&outputFormat, "output", "text", "Report format (text, json)")
+	connectFlags.StringVar(&outputFile, "o", "", "Write the report to a file instead of stdout")
+}
+
+// TCP connect subcommand
+func connectCmd(args []string) {
+	connectFlags.Parse(args)
+	setupLogging()
+	if connDuration > 0 && !flagSet(connectFlags, "requests") {
+		connRequests = 0
+	}
+	if !connTLS && (connResume || connInsecure || connServerName != "") {
+		log.Fatal(fmt.Errorf("\n%s", connTLSError))
+	}
+	var report func(io.Writer, tensile.ConnectResults) error
+	switch outputFormat {
+	case "text":
+		report = connectTextReport
+	case "json":
+		report = func(w io.Writer, res tensile.ConnectResults) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "\t")
+			return enc.Encode(res)
+		}
+	default:
+		log.Fatal(fmt.Errorf(connFormatError, outputFormat))
+	}
+	infof("\n\t%s\n\n", tensile.App+tensile.Version)
+	infof("Target:\t\t%s\n", connTarget)
+	if connRequests > 0 {
+		infof("Connections:\t%d\n", connRequests)
+	}
+	if connDuration > 0 {
+		infof("Duration:\t%s\n", connDuration)
+	}
+	infof("Concurrent:\t%d\n", connConcurrent)
+	if connRate > 0 {
+		infof("Rate:\t\t%g/s\n", connRate)
+	}
+	if connTLS {
+		infof("TLS:\t\tresume %t\n", connResume)
+	}
+	infof("\n")
+	cfg := tensile.ConnectConfig{
+		Address:    connTarget,
+		Requests:   connRequests,
+		Concurrent: connConcurrent,
+		Rate:       connRate,
+		Duration:   connDuration,
+		Timeout:    connTimeout,
+		Resume:     connResume,
+	}
+	if connTLS {
+		cfg.TLS = &tls.Config{ServerName: connServerName, InsecureSkipVerify: connInsecure}
+	}
+	res, err := tensile.AttackConnect(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := writeOutput(func(w io.Writer) error { return report(w, res) }); err != nil {
+		log.Fatal(fmt.Errorf(reportWriteError, err))
+	}
+}
+
+// Plain text TCP connect report
+func connectTextReport(w io.Writer, res tensile.ConnectResults) error {
+	fmt.Fprintf(w, "Attempts:\t%d\nConnected:\t%d\nFailed:\t\t%d\n", res.Requests, res.Connected, res.Failed)
+	if len(res.Errors) > 0 {
+		fmt.Fprintf(w, "Errors:\t\t%s\n", counts(res.Errors))
+	}
+	if res.Handshake != nil {
+		fmt.Fprintf(w, "Resumed:\t%d\n", res.Resumed)
+	}
+	fmt.Fprintf(w, "Total time:\t%s\nThroughput:\t%.2f conn/s\n", res.Duration, res.Throughput)
+	type metric struct {
+		name string
+		m    tensile.Metric
+	}
+	ms := []metric{{"Connect", res.Connect}}
+	if res.Handshake != nil {
+		ms = append(ms, metric{"Handshake", *res.Handshake})
+	}
+	for _, m := range ms {
+		fmt.Fprintf(w, "\n%s mean:\t%s\n", m.name, m.m.Mean)
+		for _, p := range tensile.Percentiles {
+			fmt.Fprintf(w, "%s %s:\t%s\n", m.name, tensile.PercentileName(p), m.m.Percentiles[tensile.PercentileName(p)])
+		}
+		if _, err := fmt.Fprintf(w, "%s max:\t%s\n", m.name, m.m.Max); err != nil {
+			return err
+		}
+	}
+	return nil
+}
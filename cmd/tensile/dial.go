@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	localAddrs                 []net.IP
+	ipv4, ipv6                 bool
+	network                    string
+	dns                        string
+	dnsServer, hostsFile       string
+	hosts                      map[string][]net.IP
+	spreadAddrs, byBackend     bool
+	tlsResume                  bool
+	portRange                  string
+	portMin, portMax           int
+	tcpNoDelay                 bool
+	linger                     time.Duration
+	sendBuf, recvBuf           string
+	sendBufBytes, recvBufBytes int
+
+	portRangeError = "ERROR: invalid -port-range %q, expected first-last, e.g. 20000-30000\n"
+	bufferError    = "ERROR: invalid -send-buffer or -recv-buffer size\n"
+	familyError    = "ERROR: only one of -4 and -6 may be set\n"
+	dnsError       = "ERROR: invalid -dns %q, expected once or per-request\n"
+	hostsError     = "ERROR: reading -hosts-file: %v\n"
+)
+
+func init() {
+	attackFlags.BoolVar(&ipv4, "4", false, "Only connect over IPv4")
+	attackFlags.BoolVar(&ipv6, "6", false, "Only connect over IPv6")
+	attackFlags.StringVar(&dns, "dns", "per-request", "Resolve hosts once, or per-request for every connection, to exercise DNS load balancing")
+	attackFlags.StringVar(&dnsServer, "dns-server", "", "Resolve hosts with this DNS server, e.g. 10.0.0.2:53, rather than the system's")
+	attackFlags.BoolVar(&spreadAddrs, "spread-addrs", false, "Spread connections evenly over all the addresses of hosts, breaking the results down by backend")
+	attackFlags.BoolVar(&byBackend, "by-backend", false, "Break the results down by backend IP address, flagging any that stand out")
+	attackFlags.BoolVar(&tlsResume, "tls-resume", false, "Resume TLS sessions on new connections, rather than making a full handshake on each")
+	attackFlags.StringVar(&hostsFile, "hosts-file", "", "File of addresses of hosts, in the format of /etc/hosts, overriding DNS")
+	attackFlags.Var(localAddrFlag{}, "local-addr", "Make connections from this local IP address, or the addresses of this interface, in turn (repeatable)")
+	attackFlags.StringVar(&portRange, "port-range", "", "Make connections from these local ports in turn, e.g. 20000-30000, rather than ephemeral ports")
+	attackFlags.BoolVar(&tcpNoDelay, "tcp-nodelay", true, "Set TCP_NODELAY, turning off Nagle's algorithm")
+	attackFlags.DurationVar(&linger, "linger", 0, "SO_LINGER of connections, in whole seconds, or negative to reset them on close rather than leave them in TIME_WAIT")
+	attackFlags.StringVar(&sendBuf, "send-buffer", "", "SO_SNDBUF of connections, e.g. 256KB")
+	attackFlags.StringVar(&recvBuf, "recv-buffer", "", "SO_RCVBUF of connections, e.g. 256KB")
+}
+
+// Repeatable local address flag
+type localAddrFlag struct{}
+
+// String returns the local addresses, comma separated
+func (localAddrFlag) String() string {
+	l := make([]string, len(localAddrs))
+	for i, ip := range localAddrs {
+		l[i] = ip.String()
+	}
+	return strings.Join(l, ",")
+}
+
+// Set adds IP addresses, or the unicast addresses of network interfaces,
+// comma separated as String joins them
+func (localAddrFlag) Set(s string) error {
+	for _, a := range splitList(s) {
+		if err := addLocalAddr(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add an IP address, or the unicast addresses of a network interface
+func addLocalAddr(s string) error {
+	if ip := net.ParseIP(s); ip != nil {
+		localAddrs = append(localAddrs, ip)
+		return nil
+	}
+	iface, err := net.InterfaceByName(s)
+	if err != nil {
+		return fmt.Errorf("invalid local address %q, expected an IP address or interface name", s)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return err
+	}
+	n := len(localAddrs)
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if ok && (ipn.IP.IsGlobalUnicast() || ipn.IP.IsLoopback()) {
+			localAddrs = append(localAddrs, ipn.IP)
+		}
+	}
+	if len(localAddrs) == n {
+		return fmt.Errorf("interface %s has no usable addresses", s)
+	}
+	return nil
+}
+
+// Parse the address family, -dns, -port-range as first-last, and the socket
+// buffer sizes
+func parseSocket() error {
+	if dns != "once" && dns != "per-request" {
+		return fmt.Errorf(dnsError, dns)
+	}
+	if dnsServer != "" {
+		if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+			dnsServer = net.JoinHostPort(dnsServer, "53")
+		}
+	}
+	if hostsFile != "" {
+		var err error
+		if hosts, err = readHosts(hostsFile); err != nil {
+			return fmt.Errorf(hostsError, err)
+		}
+	}
+	switch {
+	case ipv4 && ipv6:
+		return errors.New(familyError)
+	case ipv4:
+		network = "tcp4"
+	case ipv6:
+		network = "tcp6"
+	}
+	if portRange != "" {
+		first, last, ok := strings.Cut(portRange, "-")
+		var err1, err2 error
+		if ok {
+			portMin, err1 = strconv.Atoi(first)
+			portMax, err2 = strconv.Atoi(last)
+		}
+		if !ok || err1 != nil || err2 != nil || portMin < 1 || portMax > 65535 || portMin > portMax {
+			return fmt.Errorf(portRangeError, portRange)
+		}
+	}
+	for _, b := range []struct {
+		size string
+		n    *int
+	}{{sendBuf, &sendBufBytes}, {recvBuf, &recvBufBytes}} {
+		if b.size == "" {
+			continue
+		}
+		n, err := parseByteSize(b.size)
+		if err != nil || n <= 0 || n > 1<<30 {
+			return errors.New(bufferError)
+		}
+		*b.n = int(n)
+	}
+	return nil
+}
+
+// Read a hosts file of lines of an IP address followed by the names it's the
+// address of, with # comments
+func readHosts(path string) (map[string][]net.IP, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	h := make(map[string][]net.IP)
+	for i, line := range strings.Split(string(b), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		ip := net.ParseIP(f[0])
+		if ip == nil || len(f) < 2 {
+			return nil, fmt.Errorf("line %d: expected an IP address followed by host names", i+1)
+		}
+		for _, name := range f[1:] {
+			name = strings.ToLower(name)
+			h[name] = append(h[name], ip)
+		}
+	}
+	return h, nil
+}
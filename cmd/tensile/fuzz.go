@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	fuzzHeaders, fuzzParams string
+	fuzzMaxLen              int
+
+	fuzzMaxLenError = "ERROR: -fuzz-max-len must be greater than 0\n"
+)
+
+func init() {
+	attackFlags.StringVar(&fuzzHeaders, "fuzz-headers", "", "Comma separated headers to send with a random value in every request: long, special characters, encoded or non-ASCII")
+	attackFlags.StringVar(&fuzzParams, "fuzz-params", "", "Comma separated query parameters to set to a random value in every request")
+	attackFlags.IntVar(&fuzzMaxLen, "fuzz-max-len", tensile.DefaultFuzzMaxLen, "Longest fuzzed value, in bytes")
+}
+
+// Check the fuzzing flags
+func checkFuzz() error {
+	if fuzzMaxLen < 1 {
+		return errors.New(fuzzMaxLenError)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	grpcMode            bool
+	protoFile, grpcCall string
+	grpcData            string
+	grpcFlagsError      = "ERROR: -proto and -call are needed by tensile grpc\n"
+	grpcModeError       = "ERROR: -proto, -call and -data can only be used with tensile grpc\n"
+	grpcBodyError       = "ERROR: tensile grpc sends -data as the request, other body flags can't be used\n"
+	grpcTargetsError    = "ERROR: -targets can't be used with tensile grpc\n"
+	protoError          = "ERROR: unable to load -proto: %s\n"
+	grpcCallError       = "ERROR: %s\n"
+)
+
+func init() {
+	attackFlags.StringVar(&protoFile, "proto", "", "The .proto file defining the -call, for tensile grpc")
+	attackFlags.StringVar(&grpcCall, "call", "", "gRPC method to call, as package.Service/Method, for tensile grpc")
+	attackFlags.StringVar(&grpcData, "data", "{}", "JSON request message of the -call, for tensile grpc")
+}
+
+// Build the request of a gRPC call from the gRPC flags
+func parseGRPC() error {
+	if !grpcMode {
+		if protoFile != "" || grpcCall != "" || flagSet(attackFlags, "data") {
+			return errors.New(grpcModeError)
+		}
+		return nil
+	}
+	switch {
+	case protoFile == "" || grpcCall == "":
+		return errors.New(grpcFlagsError)
+	case body != nil:
+		return errors.New(grpcBodyError)
+	case targetsFile != "":
+		return errors.New(grpcTargetsError)
+	}
+	f, err := os.Open(protoFile)
+	if err != nil {
+		return fmt.Errorf(protoError, err)
+	}
+	defer f.Close()
+	p, err := tensile.ParseProto(f)
+	if err != nil {
+		return fmt.Errorf(protoError, err)
+	}
+	path, msg, err := p.Call(grpcCall, []byte(grpcData))
+	if err != nil {
+		return fmt.Errorf(grpcCallError, err)
+	}
+	body = tensile.GRPCMessage(msg)
+	method = "POST"
+	opts.url = strings.TrimSuffix(opts.url, "/") + path
+	return nil
+}
+
+// gRPC subcommand, an attack of unary gRPC calls
+func grpcCmd(args []string) {
+	grpcMode = true
+	attackCmd(args)
+}
@@ -0,0 +1,27 @@
+package main
+
+import "fmt"
+
+var (
+	headerSizeStr string
+	headerSize    int64
+
+	headerSizeError = "ERROR: invalid -header-size %q, expected a size such as 16KB\n"
+)
+
+func init() {
+	attackFlags.StringVar(&headerSizeStr, "header-size", "", "Pad requests with headers of this size in all, such as 16KB, to test header limits")
+}
+
+// Parse -header-size
+func parseHeaderSize() error {
+	if headerSizeStr == "" {
+		return nil
+	}
+	n, err := parseByteSize(headerSizeStr)
+	if err != nil || n <= 0 || n > 1<<30 {
+		return fmt.Errorf(headerSizeError, headerSizeStr)
+	}
+	headerSize = n
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	historyDir string
+
+	historyModeError = "ERROR: -history is not supported with -sweep, -body-size-sweep or -hosts\n"
+	historyNotice    = "NOTICE: %d regression(s) beyond tolerance since the run of %s\n\n"
+)
+
+func init() {
+	attackFlags.StringVar(&historyDir, "history", "", "Directory to append the summary of each run to, comparing it with the previous run with the same -tag values")
+}
+
+// File in -history of one JSON entry per line
+const historyFile = "history.jsonl"
+
+// A run in -history
+type historyEntry struct {
+	Start   time.Time       `json:"start"`
+	Results tensile.Results `json:"results"`
+}
+
+// The last entry in the history file with the same tags, if any
+func lastHistory(path string, tags tensile.Tags) (*historyEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var last *historyEntry
+	dec := json.NewDecoder(f)
+	for {
+		var e historyEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			return last, nil
+		} else if err != nil {
+			return last, err
+		}
+		if e.Results.Tags.String() == tags.String() {
+			last = &e
+		}
+	}
+}
+
+// Append the run to -history, and print how it compares with the previous
+// run with the same tags
+func recordHistory(start time.Time, res tensile.Results) {
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		slog.Error("writing history", "err", err)
+		return
+	}
+	path := filepath.Join(historyDir, historyFile)
+	prev, err := lastHistory(path, res.Tags)
+	if err != nil {
+		slog.Error("reading history", "file", path, "err", err)
+	}
+	e := historyEntry{Start: start, Results: res}
+	e.Results.Histogram, e.Results.Timeline = nil, nil
+	line, err := json.Marshal(e)
+	if err != nil {
+		slog.Error("writing history", "err", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		slog.Error("writing history", "file", path, "err", err)
+	}
+	if prev == nil {
+		return
+	}
+	var b bytes.Buffer
+	n := compareTable(&b, prev.Results, res)
+	infof("Compared with the run of %s:\n\n%s\n", prev.Start.Format(time.RFC3339), b.String())
+	if n > 0 {
+		infof(historyNotice, n, prev.Start.Format(time.RFC3339))
+	}
+}
+
+// Check -history can be used with the other flags
+func checkHistory() error {
+	if historyDir != "" && tabulated() {
+		return errors.New(historyModeError)
+	}
+	return nil
+}
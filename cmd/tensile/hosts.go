@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	hostsStr        string
+	hostsInterleave bool
+
+	hostsListError = "ERROR: -hosts must be a comma separated list of hosts or host:ports\n"
+	hostsModeError = "ERROR: -hosts can't be used with -sweep, -agents or -record\n"
+	hostDoneInfo   = "%s:\t%.2f req/s, p99 %s, %.2f%% errors\n"
+)
+
+func init() {
+	attackFlags.StringVar(&hostsStr, "hosts", "", "Comma separated hosts or host:ports to run the same requests against in turn, and compare side by side")
+	attackFlags.BoolVar(&hostsInterleave, "hosts-interleave", false, "With -hosts, send requests to each host in turn in one run, rather than a run per host")
+}
+
+// Check -hosts
+func checkHosts() error {
+	if hostsStr == "" {
+		return nil
+	}
+	if sweepStr != "" || agentsStr != "" || recordFile != "" {
+		return errors.New(hostsModeError)
+	}
+	hosts := splitList(hostsStr)
+	if len(hosts) == 0 {
+		return errors.New(hostsListError)
+	}
+	for _, h := range hosts {
+		if strings.ContainsAny(h, "/?#@ ") {
+			return errors.New(hostsListError)
+		}
+	}
+	return nil
+}
+
+// Target t with its host replaced by host
+func onHost(t tensile.Target, host string) tensile.Target {
+	if u, err := url.Parse(t.URL); err == nil {
+		u.Host = host
+		t.URL = u.String()
+	}
+	return t
+}
+
+// Targets of cfg, or its URL as a target
+func configTargets(cfg tensile.Config) []tensile.Target {
+	if len(cfg.Targets) > 0 {
+		return cfg.Targets
+	}
+	return []tensile.Target{{Name: cfg.URL, URL: cfg.URL}}
+}
+
+// Print the results of a host as it's done
+func hostDone(h string, res tensile.Results) {
+	infof(hostDoneInfo, h, res.Throughput, res.Percentiles["p99"], res.ErrorRate*100)
+}
+
+// Results of a host of -hosts
+type hostResults struct {
+	Host    string          `json:"host"`
+	Results tensile.Results `json:"results"`
+}
+
+// Run the attack against each of -hosts and write a table of their results
+// side by side, or CSV or JSON with -output
+func compareHosts() {
+	hosts := splitList(hostsStr)
+	cfg := config(opts)
+	var results []hostResults
+	if hostsInterleave {
+		var ts []tensile.Target
+		for _, t := range configTargets(cfg) {
+			for _, h := range hosts {
+				t := onHost(t, h)
+				t.Name = h
+				ts = append(ts, t)
+			}
+		}
+		cfg.URL, cfg.Targets = "", ts
+		res, err := tensile.Attack(context.Background(), cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		byHost := make(map[string]tensile.Results)
+		for _, t := range res.Targets {
+			byHost[t.Target] = t.Results
+		}
+		for _, h := range hosts {
+			results = append(results, hostResults{h, byHost[h]})
+			hostDone(h, byHost[h])
+		}
+	} else {
+		base := configTargets(cfg)
+		for _, h := range hosts {
+			ts := make([]tensile.Target, len(base))
+			for i, t := range base {
+				ts[i] = onHost(t, h)
+			}
+			c := cfg
+			if len(cfg.Targets) > 0 {
+				c.Targets = ts
+			} else {
+				c.URL = ts[0].URL
+			}
+			res, err := tensile.Attack(context.Background(), c)
+			if err != nil {
+				log.Fatal(err)
+			}
+			results = append(results, hostResults{h, res})
+			hostDone(h, res)
+		}
+	}
+	infof("\n")
+	if err := writeOutput(func(w io.Writer) error { return writeHosts(w, results) }); err != nil {
+		log.Fatal(fmt.Errorf(reportWriteError, err))
+	}
+}
+
+// Write the results of -hosts in the -output format
+func writeHosts(w io.Writer, results []hostResults) error {
+	if outputFormat == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(results)
+	}
+	labels := make([]string, len(results))
+	rs := make([]tensile.Results, len(results))
+	for i, r := range results {
+		labels[i], rs[i] = r.Host, r.Results
+	}
+	return writeTable(w, "Host", labels, rs)
+}
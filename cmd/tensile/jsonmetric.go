@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	jsonMetrics    []tensile.JSONMetric
+	jsonMetricUnit time.Duration
+
+	jsonMetricError = "ERROR: invalid -json-metric, expected name=$.path such as took=$.meta.duration_ms, in a positive -json-metric-unit\n"
+)
+
+func init() {
+	attackFlags.Var(jsonMetricFlag{}, "json-metric", "Report the durations at a path of JSON responses, as name=$.path such as took=$.meta.duration_ms (repeatable)")
+	attackFlags.DurationVar(&jsonMetricUnit, "json-metric-unit", time.Millisecond, "Unit of the values of -json-metric")
+}
+
+// Repeatable JSON metric flag
+type jsonMetricFlag struct{}
+
+// String returns the JSON metrics of the flag, one per line
+func (jsonMetricFlag) String() string {
+	var l []string
+	for _, m := range jsonMetrics {
+		l = append(l, m.Name+"="+m.Path)
+	}
+	return strings.Join(l, "\n")
+}
+
+// Set adds JSON metrics, one per line as String joins them
+func (jsonMetricFlag) Set(s string) error {
+	for _, m := range strings.Split(s, "\n") {
+		name, path, ok := strings.Cut(m, "=")
+		if !ok || name == "" || path == "" {
+			return fmt.Errorf("invalid JSON metric %q, expected name=$.path", m)
+		}
+		jsonMetrics = append(jsonMetrics, tensile.JSONMetric{Name: name, Path: path})
+	}
+	return nil
+}
+
+// Set the unit of -json-metric, and check the paths
+func checkJSONMetrics() error {
+	for i := range jsonMetrics {
+		jsonMetrics[i].Unit = jsonMetricUnit
+	}
+	for _, m := range jsonMetrics {
+		if !strings.HasPrefix(m.Path, "$") || m.Unit <= 0 {
+			return errors.New(jsonMetricError)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+)
+
+var (
+	verbose, quiet      bool
+	logFormat, logLevel string
+
+	logFormatError = "ERROR: unsupported -log-format %q\n"
+	logLevelError  = "ERROR: unsupported -log-level %q, expected debug, info, warn or error\n"
+)
+
+func init() {
+	// Every subcommand calling setupLogging, including grpc, which parses
+	// attackFlags
+	for _, fs := range []*flag.FlagSet{attackFlags, agentFlags, serveFlags, reportFlags, compareFlags, mergeFlags, wsFlags, sseFlags, connectFlags, slowFlags, plotFlags, recordFlags} {
+		fs.BoolVar(&verbose, "v", false, "Verbose diagnostics, including every failed request")
+		fs.BoolVar(&quiet, "quiet", false, "Only log errors, and don't print the banner or run info")
+		fs.StringVar(&logFormat, "log-format", "text", "Diagnostic log format on stderr (text, json)")
+		fs.StringVar(&logLevel, "log-level", "", "Lowest level of diagnostics logged (debug, info, warn, error), overriding -v and -quiet")
+	}
+}
+
+// Configure the default logger from the logging flags. Diagnostics go to
+// stderr, leaving stdout to the report.
+func setupLogging() {
+	level := slog.LevelInfo
+	switch {
+	case quiet:
+		level = slog.LevelError
+	case verbose:
+		level = slog.LevelDebug
+	}
+	if logLevel != "" {
+		if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+			log.Fatal(fmt.Errorf(logLevelError, logLevel))
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch logFormat {
+	case "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		log.Fatal(fmt.Errorf(logFormatError, logFormat))
+	}
+	slog.SetDefault(slog.New(h))
+	// Fatal errors are always shown, whatever the level
+	log.SetOutput(os.Stderr)
+	log.SetFlags(log.LstdFlags)
+	startPprof()
+}
+
+// Print human readable run info to stderr, unless -quiet
+func infof(format string, a ...interface{}) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, format, a...)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Tensile web stress test tool
+
+Mike Hughes 2014
+intermernet AT gmail DOT com
+
+LICENSE BSD 3 Clause
+
+	ByteSize function (and bytesize.go) taken from http://golang.org/doc/progs/eff_bytesize.go
+	Copyright the Go Authors.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/intermernet/tensile"
+)
+
+// Subcommand
+type command struct {
+	name, desc string
+	run        func(args []string)
+}
+
+var (
+	commands []command
+
+	unknownCmdError = "ERROR: unknown command %q\n\n"
+)
+
+func init() {
+	commands = []command{
+		{"attack", "Run a load test (default)", attackCmd},
+		{"agent", "Run attacks on behalf of a remote controller", agentCmd},
+		{"grpc", "Load test a gRPC method with unary calls", grpcCmd},
+		{"replay", "Replay the rate over time of a recording", replayCmd},
+		{"connect", "Benchmark TCP connection establishment", connectCmd},
+		{"slow", "Test how long a server tolerates slow clients", slowCmd},
+		{"ws", "Load test a WebSocket endpoint", wsCmd},
+		{"sse", "Load test a Server-Sent Events endpoint", sseCmd},
+		{"serve", "Serve an HTTP API to start, stop and query tests", serveCmd},
+		{"report", "Regenerate a report from a raw results file", reportCmd},
+		{"compare", "Compare two JSON reports or recordings and flag regressions", compareCmd},
+		{"merge", "Merge JSON reports or recordings from several load generators", mergeCmd},
+		{"record", "Record a browser session through a proxy as a scenario to replay", recordCmd},
+		{"plot", "Chart latency and throughput over time, and latency by percentile", plotCmd},
+		{"profile", "Save, show, list or delete named flag profiles", profileCmd},
+		{"help", "Show this help", helpCmd},
+	}
+}
+
+// Usage function for a subcommand flag set
+func usageFor(fs *flag.FlagSet, usage string) func() {
+	return func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s\n", usage)
+		fs.PrintDefaults()
+	}
+}
+
+// Print the list of subcommands
+func usage() {
+	fmt.Fprintf(os.Stderr, "%s\n\nUsage: tensile <command> [flags]\n\nCommands:\n", tensile.App+tensile.Version)
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s%s\n", c.name, c.desc)
+	}
+	fmt.Fprint(os.Stderr, "\nRun 'tensile <command> -help' for the flags of each command.\n")
+}
+
+// Help subcommand
+func helpCmd(args []string) {
+	usage()
+}
+
+func main() {
+	args := os.Args[1:]
+	// No subcommand given, keep the original flat flag behaviour.
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		attackCmd(args)
+		return
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			c.run(args[1:])
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, unknownCmdError, args[0])
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/intermernet/tensile"
+)
+
+// Escape a table cell
+func mdCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// Markdown table of a breakdown, e.g. by target
+func mdBreakdown(w io.Writer, title string, names []string, rs []tensile.Results) {
+	fmt.Fprintf(w, "\n| %s | Requests | Throughput | Errors | p50 | p99 | Max |\n|---|---:|---:|---:|---:|---:|---:|\n", title)
+	for i, r := range rs {
+		fmt.Fprintf(w, "| %s | %d | %.2f req/s | %.2f%% | %s | %s | %s |\n",
+			mdCell(names[i]), r.Requests, r.Throughput, r.ErrorRate*100, r.Percentiles["p50"], r.Percentiles["p99"], r.Max)
+	}
+}
+
+// Markdown report, compact enough for a pull request comment
+func markdownReport(w io.Writer, sum tensile.Results) error {
+	fmt.Fprintf(w, "### Load test of %s\n\n", sum.URL)
+	if len(sum.Tags) > 0 {
+		fmt.Fprintf(w, "Tags: `%s`\n\n", sum.Tags.List())
+	}
+	if sum.Stopped != "" {
+		fmt.Fprintf(w, "**Stopped early: %s**\n\n", sum.Stopped)
+	}
+	fmt.Fprintf(w, "| Requests | Errors | Throughput | Duration | Mean |")
+	align := "|---:|---:|---:|---:|---:|"
+	for _, p := range tensile.Percentiles {
+		fmt.Fprintf(w, " %s |", tensile.PercentileName(p))
+		align += "---:|"
+	}
+	fmt.Fprintf(w, " Max |\n%s---:|\n", align)
+	fmt.Fprintf(w, "| %d | %d (%.2f%%) | %.2f req/s | %s | %s |", sum.Requests, sum.Errors, sum.ErrorRate*100, sum.Throughput, sum.Duration, sum.Mean)
+	for _, p := range tensile.Percentiles {
+		fmt.Fprintf(w, " %s |", sum.Percentiles[tensile.PercentileName(p)])
+	}
+	fmt.Fprintf(w, " %s |\n", sum.Max)
+	if len(sum.Status) > 0 {
+		codes := make([]int, 0, len(sum.Status))
+		for c := range sum.Status {
+			codes = append(codes, c)
+		}
+		sort.Ints(codes)
+		status := make([]string, len(codes))
+		for i, c := range codes {
+			status[i] = fmt.Sprintf("`%d %s` %d", c, http.StatusText(c), sum.Status[c])
+		}
+		fmt.Fprintf(w, "\nStatus: %s\n", strings.Join(status, ", "))
+	}
+	if sum.Aborted > 0 {
+		fmt.Fprintf(w, "\nAborted: %d (cancelled in flight, not counted)\n", sum.Aborted)
+	}
+	if len(sum.Checks) > 0 {
+		fmt.Fprintf(w, "\nFailed checks: %s\n", counts(sum.Checks))
+	}
+	if len(sum.Targets) > 0 {
+		names := make([]string, len(sum.Targets))
+		rs := make([]tensile.Results, len(sum.Targets))
+		for i, t := range sum.Targets {
+			names[i], rs[i] = t.Target, t.Results
+		}
+		mdBreakdown(w, "Target", names, rs)
+	}
+	if len(sum.Methods) > 0 {
+		names := make([]string, len(sum.Methods))
+		rs := make([]tensile.Results, len(sum.Methods))
+		for i, m := range sum.Methods {
+			names[i], rs[i] = m.Method, m.Results
+		}
+		mdBreakdown(w, "Method", names, rs)
+	}
+	if len(sum.Backends) > 0 {
+		names := make([]string, len(sum.Backends))
+		rs := make([]tensile.Results, len(sum.Backends))
+		for i, b := range sum.Backends {
+			names[i], rs[i] = b.Backend, b.Results
+			if b.Outlier != "" {
+				names[i] += " (outlier: " + b.Outlier + ")"
+			}
+		}
+		mdBreakdown(w, "Backend", names, rs)
+	}
+	if len(sum.Phases) > 0 {
+		names := make([]string, len(sum.Phases))
+		rs := make([]tensile.Results, len(sum.Phases))
+		for i, p := range sum.Phases {
+			names[i], rs[i] = p.Phase, p.Results
+		}
+		mdBreakdown(w, "Phase", names, rs)
+	}
+	_, err := fmt.Fprintf(w, "\n<sub>%s</sub>\n", tensile.App+tensile.Version)
+	return err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	mergeFlags = flag.NewFlagSet("merge", flag.ExitOnError)
+
+	mergeArgsError = "ERROR: merge requires at least two JSON reports or recordings\n"
+	mergeHistError = "ERROR: %s has no latency histogram and cannot be merged\n"
+)
+
+func init() {
+	mergeFlags.Usage = usageFor(mergeFlags, "tensile merge [flags] a.json|.bin b.json|.bin ...")
+}
+
+// Merge subcommand
+func mergeCmd(args []string) {
+	mergeFlags.Parse(args)
+	setupLogging()
+	if mergeFlags.NArg() < 2 {
+		mergeFlags.Usage()
+		log.Fatal(fmt.Errorf("\n%s", mergeArgsError))
+	}
+	ts, err := tensile.ParseThresholds(thresholdStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var rs []tensile.Results
+	for _, path := range mergeFlags.Args() {
+		r, err := readResults(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if r.Histogram == nil {
+			log.Fatal(fmt.Errorf(mergeHistError, path))
+		}
+		rs = append(rs, r)
+	}
+	res := tensile.Merge(rs...)
+	if err := writeReport(res); err != nil {
+		log.Fatal(fmt.Errorf(reportWriteError, err))
+	}
+	if !checkThresholds(ts, res) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	methodMix       string
+	methodBodies    = make(map[string]string)
+	methods         []tensile.MethodWeight
+	methodMixError  = "ERROR: invalid -method-mix %q, expected weighted methods, e.g. GET:80,POST:20\n"
+	methodBothError = "ERROR: only one of -method and -method-mix can be used\n"
+	methodBodyError = "ERROR: unable to read -method-body: %s\n"
+	methodOnlyError = "ERROR: -method-body needs -method-mix\n"
+)
+
+func init() {
+	attackFlags.StringVar(&methodMix, "method-mix", "", "Weighted mix of request methods, e.g. GET:80,POST:20, broken down in the report")
+	attackFlags.Var(methodBodyFlag{}, "method-body", "Request body of a -method-mix method, as METHOD=file (repeatable)")
+}
+
+// Repeatable per method body flag
+type methodBodyFlag struct{}
+
+// String returns the bodies, comma separated
+func (methodBodyFlag) String() string {
+	l := make([]string, 0, len(methodBodies))
+	for m, path := range methodBodies {
+		l = append(l, m+"="+path)
+	}
+	sort.Strings(l)
+	return strings.Join(l, ",")
+}
+
+// Set adds the body files of methods, comma separated as String joins them
+func (methodBodyFlag) Set(s string) error {
+	for _, mb := range splitList(s) {
+		m, path, ok := strings.Cut(mb, "=")
+		if !ok || m == "" || path == "" {
+			return fmt.Errorf("invalid method body %q, expected METHOD=file", mb)
+		}
+		methodBodies[strings.ToUpper(m)] = path
+	}
+	return nil
+}
+
+// Parse -method-mix, after the body flags. Methods without a -method-body
+// are sent with the body of the body flags, if any, except GET and HEAD.
+func parseMethodMix() error {
+	if methodMix == "" {
+		if len(methodBodies) > 0 {
+			return errors.New(methodOnlyError)
+		}
+		return nil
+	}
+	if flagSet(attackFlags, "method") {
+		return errors.New(methodBothError)
+	}
+	for _, mw := range strings.Split(methodMix, ",") {
+		m, w, _ := strings.Cut(strings.TrimSpace(mw), ":")
+		n, err := strconv.Atoi(w)
+		if m == "" || err != nil || n <= 0 {
+			return fmt.Errorf(methodMixError, methodMix)
+		}
+		m = strings.ToUpper(m)
+		b := body
+		if m == http.MethodGet || m == http.MethodHead {
+			b = nil
+		}
+		if path, ok := methodBodies[m]; ok {
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf(methodBodyError, err)
+			}
+			typ := mime.TypeByExtension(filepath.Ext(path))
+			if typ == "" {
+				typ = octetStream
+			}
+			b = tensile.File{Path: path, Type: typ}
+		}
+		methods = append(methods, tensile.MethodWeight{Method: m, Weight: n, Body: b})
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	notifyURL string
+
+	// Context of the run. With -notify-webhook it's cancelled by an
+	// interrupt, so an aborted run is still reported.
+	runCtx = context.Background()
+
+	notifyURLError = "ERROR: -notify-webhook must be an http or https URL\n"
+)
+
+func init() {
+	attackFlags.StringVar(&notifyURL, "notify-webhook", "", "POST a JSON summary and the -threshold outcomes here when the run finishes or is aborted, e.g. a Slack incoming webhook")
+}
+
+// Time allowed to deliver a notification
+const notifyTimeout = 10 * time.Second
+
+// Outcomes of a run in notifications
+const (
+	runPassed  = "passed"
+	runFailed  = "failed"
+	runAborted = "aborted"
+)
+
+// Body of a notification. Text is the one line summary shown by Slack and
+// the like, which ignore the rest.
+type notification struct {
+	Text       string             `json:"text"`
+	Status     string             `json:"status"`
+	Error      string             `json:"error,omitempty"`
+	Thresholds []thresholdOutcome `json:"thresholds,omitempty"`
+	Results    tensile.Results    `json:"results"`
+}
+
+// Check -notify-webhook is a URL notifications can be sent to
+func checkNotifyURL() error {
+	if notifyURL == "" {
+		return nil
+	}
+	u, err := url.Parse(notifyURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New(notifyURLError)
+	}
+	return nil
+}
+
+// POST the outcome of the run to -notify-webhook, if set. runErr is the error
+// that aborted the run, if any.
+func notify(res tensile.Results, outcomes []thresholdOutcome, passed bool, runErr error) {
+	if notifyURL == "" {
+		return
+	}
+	n := notification{Status: runPassed, Thresholds: outcomes, Results: res}
+	n.Results.Histogram, n.Results.Timeline = nil, nil
+	switch {
+	case runErr != nil && runCtx.Err() != nil:
+		n.Status, n.Error = runAborted, "interrupted"
+	case runErr != nil:
+		n.Status, n.Error = runAborted, runErr.Error()
+	case res.Stopped != "":
+		n.Status = runAborted
+	case !passed:
+		n.Status = runFailed
+	}
+	n.Text = fmt.Sprintf("Load test of %s %s: %d requests, %.2f%% errors, %.2f req/s, p99 %s",
+		res.URL, n.Status, res.Requests, res.ErrorRate*100, res.Throughput, res.Percentiles["p99"])
+	switch {
+	case runErr != nil:
+		n.Text += ", " + n.Error
+	case res.Stopped != "":
+		n.Text += ", stopped early at " + res.Stopped
+	}
+	for _, o := range outcomes {
+		if !o.Pass {
+			n.Text += fmt.Sprintf(", %s failed (%s)", o.Expr, o.Actual)
+		}
+	}
+	b, err := json.Marshal(n)
+	if err != nil {
+		slog.Error("notifying webhook", "err", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", notifyURL, bytes.NewReader(b))
+	if err != nil {
+		slog.Error("notifying webhook", "err", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", tensile.App+tensile.Version)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		slog.Error("notifying webhook", "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Error("notifying webhook", "status", resp.Status)
+	}
+}
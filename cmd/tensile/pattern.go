@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	patternName       string
+	spikeAt           time.Duration
+	spikeFor          time.Duration
+	spikeX            float64
+	period            time.Duration
+	minRate, maxRate  float64
+	scheduleFile      string
+	burstStr          string
+	burstN            int
+	burstEvery        time.Duration
+	loadPattern       tensile.Pattern
+	patternError      = "ERROR: unsupported -pattern %q\n"
+	patternRateError  = "ERROR: -pattern %s needs a baseline -rate\n"
+	spikeError        = "ERROR: -spike-for and -spike-x must be greater than 0\n"
+	sineError         = "ERROR: -pattern sine needs -period greater than 0 and 0 <= -min <= -max\n"
+	scheduleError     = "ERROR: -pattern schedule needs a -schedule file\n"
+	scheduleFileError = "ERROR: unable to load -schedule: %s\n"
+	burstError        = "ERROR: invalid -burst %q, expected requests/interval, e.g. 100/1s\n"
+)
+
+func init() {
+	attackFlags.StringVar(&patternName, "pattern", "", "Load pattern varying the rate over the test (spike, sine, schedule)")
+	attackFlags.DurationVar(&spikeAt, "spike-at", 30*time.Second, "Start of the spike for -pattern spike")
+	attackFlags.DurationVar(&spikeFor, "spike-for", 30*time.Second, "Length of the spike for -pattern spike")
+	attackFlags.Float64Var(&spikeX, "spike-x", 10, "Multiplier of -rate during the spike for -pattern spike")
+	attackFlags.DurationVar(&period, "period", 5*time.Minute, "Period of -pattern sine")
+	attackFlags.Float64Var(&minRate, "min", 1, "Lowest rate of -pattern sine")
+	attackFlags.Float64Var(&maxRate, "max", 100, "Highest rate of -pattern sine")
+	attackFlags.StringVar(&burstStr, "burst", "", "Send requests in bursts of N every interval, e.g. 100/1s")
+	attackFlags.StringVar(&scheduleFile, "schedule", "", "File of offset,rate lines for -pattern schedule, e.g. 5m,200")
+}
+
+// Load pattern from the -pattern flags, or nil for none
+func parsePattern() (tensile.Pattern, error) {
+	if patternName == "" && scheduleFile != "" {
+		patternName = "schedule"
+	}
+	switch patternName {
+	case "":
+		return nil, nil
+	case "spike":
+		if rate <= 0 {
+			return nil, fmt.Errorf(patternRateError, patternName)
+		}
+		if spikeFor <= 0 || spikeX <= 0 {
+			return nil, errors.New(spikeError)
+		}
+		return tensile.Spike{Base: rate, Multiplier: spikeX, At: spikeAt, Length: spikeFor}, nil
+	case "sine":
+		if period <= 0 || minRate < 0 || maxRate < minRate {
+			return nil, errors.New(sineError)
+		}
+		return tensile.Sine{Min: minRate, Max: maxRate, Period: period}, nil
+	case "schedule":
+		if scheduleFile == "" {
+			return nil, errors.New(scheduleError)
+		}
+		f, err := os.Open(scheduleFile)
+		if err != nil {
+			return nil, fmt.Errorf(scheduleFileError, err)
+		}
+		defer f.Close()
+		s, err := tensile.ParseSchedule(f)
+		if err != nil {
+			return nil, fmt.Errorf(scheduleFileError, err)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf(patternError, patternName)
+}
+
+// Parse -burst as requests/interval
+func parseBurst() error {
+	if burstStr == "" {
+		return nil
+	}
+	n, d, ok := strings.Cut(burstStr, "/")
+	var err error
+	if ok {
+		if burstN, err = strconv.Atoi(n); err == nil {
+			burstEvery, err = time.ParseDuration(d)
+		}
+	}
+	if !ok || err != nil || burstN <= 0 || burstEvery <= 0 {
+		return fmt.Errorf(burstError, burstStr)
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	plotChart string
+
+	plotFlags = flag.NewFlagSet("plot", flag.ExitOnError)
+
+	plotArgsError  = "ERROR: plot requires exactly one recording or JSON report\n"
+	plotChartError = "ERROR: unsupported -chart %q, expected all, latency, throughput or percentiles\n"
+	plotEmptyError = "ERROR: %s has no timeline or latency histogram to plot\n"
+)
+
+func init() {
+	plotFlags.Usage = usageFor(plotFlags, "tensile plot [flags] results.bin|.json")
+	plotFlags.StringVar(&outputFile, "o", "", "Write the charts to a file, PNG if it ends in .png, or else SVG; stdout if not set")
+	plotFlags.StringVar(&plotChart, "chart", "all", "Charts to plot: all, latency, throughput or percentiles")
+}
+
+// Size of a chart, and the margins around its plot area
+const (
+	chartW, chartH        = 800, 240
+	chartLeft, chartRight = 90, 40
+	chartTop, chartBottom = 30, 40
+	plotW                 = chartW - chartLeft - chartRight
+	plotH                 = chartH - chartTop - chartBottom
+	lineWidth             = 2
+	timeTicks             = 5    // Intervals between labels of timelines
+	maxNines              = 5    // Highest percentile plotted, 99.999%
+	ninesStep             = 0.05 // Between points of the percentile chart
+)
+
+// Glyphs of PNG charts are 3x5 dots, a dot apart, each 2x2 pixels
+const (
+	glyphW, glyphH = 3, 5
+	glyphGap       = 1
+	glyphScale     = 2
+)
+
+var (
+	blue = color.RGBA{70, 130, 180, 255} // steelblue, as in the HTML report
+	red  = color.RGBA{178, 34, 34, 255}  // firebrick
+)
+
+// A line of a chart, in data units
+type series struct {
+	name  string
+	color color.RGBA
+	pts   [][2]float64
+}
+
+// A label on the x axis, at a data value
+type tick struct {
+	at    float64
+	label string
+}
+
+// A line chart of one or more series, from 0 on the y axis
+type chart struct {
+	title  string
+	unit   string
+	series []series
+	xMax   float64
+	xTicks []tick
+	yMax   float64
+}
+
+// Round v up to 1, 2 or 5 times a power of 10
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	e := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, f := range []float64{1, 2, 5} {
+		if v <= f*e {
+			return f * e
+		}
+	}
+	return 10 * e
+}
+
+// Fit the y axis to the series
+func (c *chart) fit() {
+	for _, s := range c.series {
+		for _, p := range s.pts {
+			c.yMax = math.Max(c.yMax, p[1])
+		}
+	}
+	c.yMax = niceCeil(c.yMax)
+	if c.xMax <= 0 {
+		c.xMax = 1
+	}
+}
+
+// Position in the chart of a point in data units
+func (c chart) pos(p [2]float64) (float64, float64) {
+	return chartLeft + p[0]/c.xMax*plotW, chartTop + plotH - p[1]/c.yMax*plotH
+}
+
+// Ticks evenly spaced over end seconds
+func secondTicks(end float64) []tick {
+	unit := 100 * time.Millisecond
+	if end >= 10 {
+		unit = time.Second
+	}
+	ts := make([]tick, 0, timeTicks+1)
+	for i := 0; i <= timeTicks; i++ {
+		at := end * float64(i) / timeTicks
+		ts = append(ts, tick{at, time.Duration(at * float64(time.Second)).Round(unit).String()})
+	}
+	return ts
+}
+
+// Charts of the results chosen by -chart
+func plotCharts(sum tensile.Results, which string) []chart {
+	var cs []chart
+	tl := sum.Timeline
+	if len(tl) > 0 && (which == "all" || which == "latency" || which == "throughput") {
+		end := (tl[len(tl)-1].Offset + tensile.TimelineInterval).Seconds()
+		lat := chart{title: "Latency over time", unit: "ms", xMax: end, xTicks: secondTicks(end)}
+		rps := chart{title: "Throughput over time", unit: "req/s", xMax: end, xTicks: secondTicks(end)}
+		p50, p99 := series{name: "p50", color: blue}, series{name: "p99", color: red}
+		tput := series{name: "throughput", color: blue}
+		for _, p := range tl {
+			x := (p.Offset + tensile.TimelineInterval/2).Seconds()
+			p50.pts = append(p50.pts, [2]float64{x, float64(p.P50) / float64(time.Millisecond)})
+			p99.pts = append(p99.pts, [2]float64{x, float64(p.P99) / float64(time.Millisecond)})
+			tput.pts = append(tput.pts, [2]float64{x, p.Throughput})
+		}
+		lat.series, rps.series = []series{p50, p99}, []series{tput}
+		if which != "throughput" {
+			cs = append(cs, lat)
+		}
+		if which != "latency" {
+			cs = append(cs, rps)
+		}
+	}
+	if h := sum.Histogram; h != nil && h.Total() > 0 && (which == "all" || which == "percentiles") {
+		// As HdrHistogram plots it, x is the number of nines, up to as many
+		// as there were requests to resolve
+		nines := math.Min(maxNines, math.Log10(float64(h.Total())))
+		c := chart{title: "Latency by percentile", unit: "ms", xMax: math.Max(nines, 1)}
+		for i := 0; i <= int(c.xMax); i++ {
+			p, digits := 100-100/math.Pow(10, float64(i)), i-2
+			if digits < 0 {
+				digits = 0
+			}
+			c.xTicks = append(c.xTicks, tick{float64(i), fmt.Sprintf("%.*f%%", digits, p)})
+		}
+		s := series{name: "latency", color: blue}
+		for x := 0.0; x <= nines+1e-9; x += ninesStep {
+			d := h.Percentile(100 - 100/math.Pow(10, x))
+			s.pts = append(s.pts, [2]float64{x, float64(d) / float64(time.Millisecond)})
+		}
+		c.series = []series{s}
+		cs = append(cs, c)
+	}
+	for i := range cs {
+		cs[i].fit()
+	}
+	return cs
+}
+
+// Label of a y axis value
+func yLabel(v float64, unit string) string {
+	return fmt.Sprintf("%g %s", math.Round(v*1000)/1000, unit)
+}
+
+// Write the charts as one SVG image, stacked
+func svgCharts(w io.Writer, cs []chart) error {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n",
+		chartW, chartH*len(cs))
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	for i, c := range cs {
+		fmt.Fprintf(w, `<g transform="translate(0,%d)">`+"\n", i*chartH)
+		fmt.Fprintf(w, `<text x="%d" y="%d" font-size="14" font-weight="bold">%s</text>`+"\n", chartLeft, chartTop-12, html.EscapeString(c.title))
+		for j := 0; j <= 2; j++ {
+			v := c.yMax * float64(j) / 2
+			_, y := c.pos([2]float64{0, v})
+			fmt.Fprintf(w, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e6e6e6"/>`+"\n", chartLeft, y, chartLeft+plotW, y)
+			fmt.Fprintf(w, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n", chartLeft-6, y+4, html.EscapeString(yLabel(v, c.unit)))
+		}
+		for _, t := range c.xTicks {
+			x, _ := c.pos([2]float64{t.at, 0})
+			fmt.Fprintf(w, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n", x, chartTop+plotH+16, html.EscapeString(t.label))
+		}
+		fmt.Fprintf(w, `<polyline fill="none" stroke="#787878" points="%d,%d %d,%d %d,%d"/>`+"\n",
+			chartLeft, chartTop, chartLeft, chartTop+plotH, chartLeft+plotW, chartTop+plotH)
+		lx := chartLeft + plotW
+		for j := len(c.series) - 1; j >= 0; j-- {
+			s := c.series[j]
+			var b strings.Builder
+			for _, p := range s.pts {
+				x, y := c.pos(p)
+				fmt.Fprintf(&b, "%.1f,%.1f ", x, y)
+			}
+			rgb := fmt.Sprintf("rgb(%d,%d,%d)", s.color.R, s.color.G, s.color.B)
+			fmt.Fprintf(w, `<polyline fill="none" stroke="%s" stroke-width="%d" points="%s"/>`+"\n", rgb, lineWidth, b.String())
+			fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end" fill="%s">%s</text>`+"\n", lx, chartTop-12, rgb, html.EscapeString(s.name))
+			lx -= 8 * (len(s.name) + 2)
+		}
+		fmt.Fprintf(w, "</g>\n")
+	}
+	_, err := fmt.Fprintf(w, "</svg>\n")
+	return err
+}
+
+// 3x5 dot glyphs for the text of PNG charts, row by row, which is upper cased
+var glyphs = map[rune]string{
+	'0': "111101101101111", '1': "010110010010111", '2': "111001111100111", '3': "111001111001111",
+	'4': "101101111001001", '5': "111100111001111", '6': "111100111101111", '7': "111001001010010",
+	'8': "111101111101111", '9': "111101111001111", 'A': "010101111101101", 'B': "110101110101110",
+	'C': "011100100100011", 'D': "110101101101110", 'E': "111100110100111", 'F': "111100110100100",
+	'G': "011100101101011", 'H': "101101111101101", 'I': "111010010010111", 'J': "001001001101010",
+	'K': "101101110101101", 'L': "100100100100111", 'M': "101111111101101", 'N': "110101101101101",
+	'O': "010101101101010", 'P': "110101110100100", 'Q': "010101101110011", 'R': "110101110101101",
+	'S': "011100010001110", 'T': "111010010010010", 'U': "101101101101111", 'V': "101101101101010",
+	'W': "101101111111101", 'X': "101101010101101", 'Y': "101101010010010", 'Z': "111001010100111",
+	'.': "000000000000010", '%': "101001010100101", '/': "001001010100100", '(': "010100100100010",
+	')': "010001001001010", '-': "000000111000000", ',': "000000000010100", ' ': "000000000000000",
+}
+
+// Width of text drawn by drawText
+func textWidth(s string) int {
+	return len([]rune(s)) * (glyphW + glyphGap) * glyphScale
+}
+
+// Draw text with its top left at x, y
+func drawText(img *image.RGBA, x, y int, s string, c color.Color) {
+	for _, r := range strings.ToUpper(s) {
+		g, ok := glyphs[r]
+		if !ok {
+			g = glyphs[' ']
+		}
+		for i, dot := range g {
+			if dot != '1' {
+				continue
+			}
+			gx, gy := x+i%glyphW*glyphScale, y+i/glyphW*glyphScale
+			for dx := 0; dx < glyphScale; dx++ {
+				for dy := 0; dy < glyphScale; dy++ {
+					img.Set(gx+dx, gy+dy, c)
+				}
+			}
+		}
+		x += (glyphW + glyphGap) * glyphScale
+	}
+}
+
+// Draw a line of the given width
+func drawLine(img *image.RGBA, x0, y0, x1, y1 float64, width int, c color.Color) {
+	steps := int(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))) + 1
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		x, y := int(math.Round(x0+(x1-x0)*t)), int(math.Round(y0+(y1-y0)*t))
+		for dx := 0; dx < width; dx++ {
+			for dy := 0; dy < width; dy++ {
+				img.Set(x+dx, y+dy, c)
+			}
+		}
+	}
+}
+
+// Write the charts as one PNG image, stacked
+func pngCharts(w io.Writer, cs []chart) error {
+	img := image.NewRGBA(image.Rect(0, 0, chartW, chartH*len(cs)))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	grid, ax, black := color.Gray{230}, color.Gray{120}, color.Black
+	for i, c := range cs {
+		top := float64(i * chartH)
+		drawText(img, chartLeft, int(top)+chartTop-20, c.title, black)
+		for j := 0; j <= 2; j++ {
+			v := c.yMax * float64(j) / 2
+			_, y := c.pos([2]float64{0, v})
+			drawLine(img, chartLeft, top+y, chartLeft+plotW, top+y, 1, grid)
+			l := yLabel(v, c.unit)
+			drawText(img, chartLeft-6-textWidth(l), int(top+y)-glyphH*glyphScale/2, l, black)
+		}
+		for _, t := range c.xTicks {
+			x, _ := c.pos([2]float64{t.at, 0})
+			drawText(img, int(x)-textWidth(t.label)/2, int(top)+chartTop+plotH+8, t.label, black)
+		}
+		drawLine(img, chartLeft, top+chartTop, chartLeft, top+chartTop+plotH, 1, ax)
+		drawLine(img, chartLeft, top+chartTop+plotH, chartLeft+plotW, top+chartTop+plotH, 1, ax)
+		lx := chartLeft + plotW
+		for j := len(c.series) - 1; j >= 0; j-- {
+			s := c.series[j]
+			for k := 1; k < len(s.pts); k++ {
+				x0, y0 := c.pos(s.pts[k-1])
+				x1, y1 := c.pos(s.pts[k])
+				drawLine(img, x0, top+y0, x1, top+y1, lineWidth, s.color)
+			}
+			lx -= textWidth(s.name)
+			drawText(img, lx, int(top)+chartTop-20, s.name, s.color)
+			lx -= 2 * (glyphW + glyphGap) * glyphScale
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// Plot subcommand
+func plotCmd(args []string) {
+	plotFlags.Parse(args)
+	setupLogging()
+	if plotFlags.NArg() != 1 {
+		plotFlags.Usage()
+		log.Fatal(fmt.Errorf("\n%s", plotArgsError))
+	}
+	switch plotChart {
+	case "all", "latency", "throughput", "percentiles":
+	default:
+		log.Fatal(fmt.Errorf("\n"+plotChartError, plotChart))
+	}
+	path := plotFlags.Arg(0)
+	sum, err := readResults(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cs := plotCharts(sum, plotChart)
+	if len(cs) == 0 {
+		log.Fatal(fmt.Errorf("\n"+plotEmptyError, path))
+	}
+	draw := svgCharts
+	if strings.EqualFold(filepath.Ext(outputFile), ".png") {
+		draw = pngCharts
+	}
+	if err := writeOutput(func(w io.Writer) error { return draw(w, cs) }); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"plugin"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	pluginFile        string
+	requestModifier   tensile.RequestModifier
+	responseValidator tensile.ResponseValidator
+
+	pluginError     = "ERROR: unable to load -plugin: %s\n"
+	pluginHookError = "ERROR: -plugin %s must export a Hook implementing tensile.RequestModifier or tensile.ResponseValidator\n"
+)
+
+func init() {
+	attackFlags.StringVar(&pluginFile, "plugin", "", "Go plugin (.so) exporting a Hook that modifies each request or validates each response")
+}
+
+// Load the Hook of -plugin
+func loadPlugin() error {
+	if pluginFile == "" {
+		return nil
+	}
+	p, err := plugin.Open(pluginFile)
+	if err != nil {
+		return fmt.Errorf(pluginError, err)
+	}
+	hook, err := p.Lookup("Hook")
+	if err != nil {
+		return fmt.Errorf(pluginHookError, pluginFile)
+	}
+	requestModifier, _ = hook.(tensile.RequestModifier)
+	responseValidator, _ = hook.(tensile.ResponseValidator)
+	if requestModifier == nil && responseValidator == nil {
+		return fmt.Errorf(pluginHookError, pluginFile)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+var pprofAddr string
+
+func init() {
+	for _, fs := range []*flag.FlagSet{attackFlags, agentFlags, serveFlags, wsFlags, sseFlags, connectFlags, slowFlags} {
+		fs.StringVar(&pprofAddr, "pprof", "", "Serve net/http/pprof on this address while running, e.g. :6060, to profile tensile itself")
+	}
+}
+
+// Serve the pprof endpoints on -pprof, if set, with mutex and block
+// profiling enabled
+func startPprof() {
+	if pprofAddr == "" {
+		return
+	}
+	l, err := net.Listen("tcp", pprofAddr)
+	if err != nil {
+		slog.Error("starting pprof", "err", err)
+		return
+	}
+	runtime.SetMutexProfileFraction(100)
+	runtime.SetBlockProfileRate(int(1e6)) // One sample per millisecond blocked
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	slog.Info("serving pprof", "url", "http://"+l.Addr().String()+"/debug/pprof/")
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			slog.Error("serving pprof", "err", err)
+		}
+	}()
+}
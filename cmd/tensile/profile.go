@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	profileName string
+
+	profileUsage     = "Usage: tensile profile save|show|list|delete [name] [flags]\n"
+	profileNameError = "ERROR: profile name %q is invalid\n"
+	profileLoadError = "ERROR: unable to load profile %q: %s\n"
+	profileSaved     = "Saved profile %q to %s\n"
+	profileSecret    = "NOTICE: -%s isn't saved in profiles, as it holds a secret\n"
+
+	// Short flags are stored under their long name so a profile can be
+	// overridden with either form on the command line.
+	flagAliases = map[string]string{
+		"r": "requests",
+		"c": "concurrent",
+		"e": "maxerror",
+		"u": "url",
+	}
+
+	// Flags holding secrets, never saved in profiles
+	secretFlags = map[string]bool{
+		"agent-token":    true,
+		"notify-webhook": true,
+	}
+)
+
+func init() {
+	attackFlags.StringVar(&profileName, "profile", "", "Load flags from a saved profile")
+}
+
+// Whether a flag was set, by either its long or short name
+func flagSet(fs *flag.FlagSet, long string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if longFlag(f.Name) == long {
+			set = true
+		}
+	})
+	return set
+}
+
+// Canonical (long) name of a flag
+func longFlag(name string) string {
+	if long, ok := flagAliases[name]; ok {
+		return long
+	}
+	return name
+}
+
+// Directory holding saved profiles
+func profileDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tensile", "profiles"), nil
+}
+
+// Path of a named profile
+func profilePath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf(profileNameError, name)
+	}
+	dir, err := profileDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// Read a named profile
+func readProfile(name string) (map[string]string, error) {
+	path, err := profilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := make(map[string]string)
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Apply a saved profile. Flags given on the command line take precedence.
+func loadProfile(name string) error {
+	p, err := readProfile(name)
+	if err != nil {
+		return fmt.Errorf(profileLoadError, name, err)
+	}
+	set := make(map[string]bool)
+	attackFlags.Visit(func(f *flag.Flag) {
+		set[longFlag(f.Name)] = true
+	})
+	for k, v := range p {
+		if set[k] || k == "profile" {
+			continue
+		}
+		if err := attackFlags.Set(k, v); err != nil {
+			return fmt.Errorf(profileLoadError, name, err)
+		}
+	}
+	return nil
+}
+
+// Profile subcommand
+func profileCmd(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, profileUsage)
+		os.Exit(2)
+	}
+	var err error
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "list":
+		err = listProfiles()
+	case len(args) == 0:
+		fmt.Fprint(os.Stderr, profileUsage)
+		os.Exit(2)
+	case cmd == "save":
+		err = saveProfile(args[0], args[1:])
+	case cmd == "show":
+		err = showProfile(args[0])
+	case cmd == "delete":
+		var path string
+		if path, err = profilePath(args[0]); err == nil {
+			err = os.Remove(path)
+		}
+	default:
+		fmt.Fprint(os.Stderr, profileUsage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Save the flags following the profile name
+func saveProfile(name string, args []string) error {
+	path, err := profilePath(name)
+	if err != nil {
+		return err
+	}
+	if err := attackFlags.Parse(args); err != nil {
+		return err
+	}
+	p := make(map[string]string)
+	attackFlags.Visit(func(f *flag.Flag) {
+		switch name := longFlag(f.Name); {
+		case name == "profile":
+		case secretFlags[name]:
+			fmt.Fprintf(os.Stderr, profileSecret, name)
+		default:
+			p[name] = f.Value.String()
+		}
+	})
+	b, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := writePrivate(path, append(b, '\n')); err != nil {
+		return err
+	}
+	fmt.Printf(profileSaved, name, path)
+	return nil
+}
+
+// Replace the file at path with one only its owner can read, whatever the
+// permissions of any file it replaces
+func writePrivate(path string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Print the flags stored in a profile
+func showProfile(name string) error {
+	p, err := readProfile(name)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("-%s=%s\n", k, p[k])
+	}
+	return nil
+}
+
+// List saved profiles
+func listProfiles() error {
+	dir, err := profileDir()
+	if err != nil {
+		return err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		fmt.Println(strings.TrimSuffix(filepath.Base(f), ".json"))
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	recordListen, recordOut, recordUpstream string
+	scenarioFile                            string
+
+	recordFlags = flag.NewFlagSet("record", flag.ExitOnError)
+
+	recordUpstreamError = "ERROR: -upstream must be an absolute http or https URL\n"
+	recordListening     = "Recording on %s, as %s. Stop with Ctrl-C to write %s\n"
+	recordWritten       = "Wrote %d steps to %s\n"
+	recordTunnelled     = "NOTICE: HTTPS to %s is passed through but not recorded, use -upstream to record it\n"
+	scenarioError       = "ERROR: -scenario can't be used with -targets or -sitemap\n"
+	scenarioLoadError   = "ERROR: unable to load -scenario: %s\n"
+)
+
+func init() {
+	recordFlags.Usage = usageFor(recordFlags, "tensile record [flags]")
+	recordFlags.StringVar(&recordListen, "listen", ":8888", "Address to listen on")
+	recordFlags.StringVar(&recordOut, "out", "scenario.yaml", "Scenario file to write, for -scenario")
+	recordFlags.StringVar(&recordUpstream, "upstream", "", "Record as a reverse proxy to this site, rather than as a proxy the browser is set to use")
+	attackFlags.StringVar(&scenarioFile, "scenario", "", "Scenario file written by tensile record, whose steps are requested in turn as the targets")
+}
+
+// Load the steps of a scenario file as targets
+func loadScenario(path string) ([]tensile.Target, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tensile.ParseScenario(f)
+}
+
+// Request bodies recorded, at most
+const maxRecordedBody = 10 << 20
+
+// Headers not recorded, as they're set when the scenario is replayed
+var unrecordedHeaders = []string{"Content-Length", "User-Agent", "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto"}
+
+// The steps recorded so far
+type recording struct {
+	mu    sync.Mutex
+	steps []tensile.Target
+}
+
+// Record a request to proxy, with its body
+func (rc *recording) add(req *http.Request, body []byte) {
+	h := req.Header.Clone()
+	for _, k := range unrecordedHeaders {
+		h.Del(k)
+	}
+	s := tensile.Target{Name: req.Method + " " + req.URL.Path, URL: req.URL.String(), Header: h}
+	if req.Method != http.MethodGet {
+		s.Method = req.Method
+	}
+	if len(body) > 0 {
+		s.Body = body
+	}
+	rc.mu.Lock()
+	rc.steps = append(rc.steps, s)
+	rc.mu.Unlock()
+	slog.Debug("recorded", "method", req.Method, "url", s.URL)
+}
+
+// Key of the body of a request in its context
+type bodyKey struct{}
+
+// Proxy recording every request, to upstream if set, or else to the target
+// of each request made to it as a proxy. CONNECT tunnels are passed through.
+func (rc *recording) proxy(upstream *url.URL) http.Handler {
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if upstream != nil {
+				pr.SetURL(upstream)
+				// As the browser would send them to the site itself
+				proxied := "http://" + pr.In.Host
+				for _, k := range []string{"Origin", "Referer"} {
+					if v := pr.Out.Header.Get(k); strings.HasPrefix(v, proxied) {
+						pr.Out.Header.Set(k, upstream.Scheme+"://"+upstream.Host+strings.TrimPrefix(v, proxied))
+					}
+				}
+			}
+			body, _ := pr.In.Context().Value(bodyKey{}).([]byte)
+			rc.add(pr.Out, body)
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			tunnel(w, r)
+			return
+		}
+		if upstream == nil && !r.URL.IsAbs() {
+			http.Error(w, "tensile record is a proxy: set it as the browser's HTTP proxy, or use -upstream", http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRecordedBody+1))
+		if err != nil || len(body) > maxRecordedBody {
+			http.Error(w, "request body unreadable or too large to record", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		rp.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), bodyKey{}, body)))
+	})
+}
+
+// Pass a CONNECT tunnel through, unrecorded
+func tunnel(w http.ResponseWriter, r *http.Request) {
+	up, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		up.Close()
+		http.Error(w, "tunnelling unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		up.Close()
+		return
+	}
+	infof(recordTunnelled, r.Host)
+	go func() {
+		if n := buf.Reader.Buffered(); n > 0 {
+			b, _ := buf.Reader.Peek(n)
+			up.Write(b)
+		}
+		io.Copy(up, conn)
+		up.Close()
+	}()
+	io.Copy(conn, up)
+	conn.Close()
+}
+
+// Record subcommand
+func recordCmd(args []string) {
+	recordFlags.Parse(args)
+	setupLogging()
+	var upstream *url.URL
+	if recordUpstream != "" {
+		u, err := url.Parse(recordUpstream)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatal(fmt.Errorf("\n%s", recordUpstreamError))
+		}
+		upstream = u
+	}
+	rc := &recording{}
+	srv := &http.Server{Addr: recordListen, Handler: rc.proxy(upstream)}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(sctx)
+	}()
+	mode := "a proxy"
+	if upstream != nil {
+		mode = "a reverse proxy to " + upstream.String()
+	}
+	infof(recordListening, recordListen, mode, recordOut)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	f, err := os.Create(recordOut)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(f, "# Recorded by tensile record on %s\n", time.Now().Format(time.RFC3339))
+	rc.mu.Lock()
+	err = tensile.WriteScenario(f, rc.steps)
+	n := len(rc.steps)
+	rc.mu.Unlock()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	infof(recordWritten, n, recordOut)
+}
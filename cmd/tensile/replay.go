@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+// Interval over which the rate of a recording is measured for replay
+const replayInterval = time.Second
+
+var (
+	replayMode bool
+
+	replayArgsError = "ERROR: tensile replay needs one recording, after its flags\n"
+	replayFlagError = "ERROR: tensile replay takes its rate from the recording, so -rate, -pattern, -schedule, -burst, -agents and -long-poll can't be used\n"
+	replayFileError = "ERROR: unable to replay %s: %s\n"
+	replayEmpty     = "ERROR: the recording %s has no results to replay\n"
+)
+
+// Set the rate of the attack over time from the recording given to tensile
+// replay, measured every replayInterval, for the run's length. -url and
+// -concurrent default to those of the recording.
+func parseReplay() error {
+	if !replayMode {
+		return nil
+	}
+	if attackFlags.NArg() != 1 {
+		return errors.New(replayArgsError)
+	}
+	if rate > 0 || loadPattern != nil || burstN > 0 || agentsStr != "" || longPoll {
+		return errors.New(replayFlagError)
+	}
+	path := attackFlags.Arg(0)
+	var counts []int
+	info, err := tensile.ReadRecording(path, func(r tensile.Result) {
+		i := int(r.Start / replayInterval)
+		for len(counts) <= i {
+			counts = append(counts, 0)
+		}
+		counts[i]++
+	})
+	if err != nil {
+		return fmt.Errorf(replayFileError, path, err)
+	}
+	if len(counts) == 0 {
+		return fmt.Errorf(replayEmpty, path)
+	}
+	// A sampled recording holds one in info.Sample of the requests sent
+	scale := float64(max(info.Sample, 1)) / replayInterval.Seconds()
+	s := make(tensile.Schedule, len(counts))
+	for i, n := range counts {
+		s[i] = tensile.SchedulePoint{At: time.Duration(i) * replayInterval, Rate: float64(n) * scale}
+	}
+	loadPattern = s
+	duration = time.Duration(len(counts)) * replayInterval
+	if !flagSet(attackFlags, "requests") {
+		opts.requests = 0
+	}
+	if !flagSet(attackFlags, "url") {
+		if info.URL == "" {
+			return fmt.Errorf(replayFileError, path, "no URL was recorded, so -url is needed")
+		}
+		opts.url = info.URL
+	}
+	if !flagSet(attackFlags, "concurrent") && info.Concurrent > 0 {
+		opts.concurrent = info.Concurrent
+	}
+	return nil
+}
+
+// Replay subcommand, an attack at the rate over time of a recording
+func replayCmd(args []string) {
+	replayMode = true
+	attackCmd(args)
+}
@@ -0,0 +1,869 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	outputFormat, outputFile, thresholdStr string
+
+	reportFlags = flag.NewFlagSet("report", flag.ExitOnError)
+
+	// Report writers by -output format
+	reportFormats = map[string]func(io.Writer, tensile.Results) error{
+		"text":     textReport,
+		"json":     jsonReport,
+		"html":     htmlReport,
+		"csv":      csvReport,
+		"hgrm":     hgrmReport,
+		"markdown": markdownReport,
+	}
+
+	formatError      = "ERROR: unsupported -output format %q\n"
+	thresholdPass    = "Threshold %s:\tPASS (%s)\n"
+	thresholdFail    = "Threshold %s:\tFAIL (%s)\n"
+	reportFileError  = "ERROR: report requires exactly one results file\n"
+	reportWriteError = "ERROR: unable to write report: %s\n"
+	hgrmError        = "ERROR: no latency histogram for the hgrm report\n"
+)
+
+func init() {
+	reportFlags.Usage = usageFor(reportFlags, "tensile report [flags] results.bin")
+	for _, fs := range []*flag.FlagSet{attackFlags, reportFlags, mergeFlags} {
+		fs.StringVar(&outputFormat, "output", "text", "Report format (text, json, html, csv, hgrm, markdown)")
+		fs.StringVar(&outputFile, "o", "", "Write the report to a file instead of stdout")
+		fs.StringVar(&thresholdStr, "threshold", "", "Comma separated pass/fail thresholds, e.g. p99<200ms,errors<1%")
+	}
+}
+
+// Write the summary in the chosen -output format to -o, or stdout
+func writeReport(sum tensile.Results) error {
+	f, ok := reportFormats[outputFormat]
+	if !ok {
+		return fmt.Errorf(formatError, outputFormat)
+	}
+	return writeOutput(func(w io.Writer) error { return f(w, sum) })
+}
+
+// Write output to -o, or stdout
+func writeOutput(write func(io.Writer) error) error {
+	if outputFile == "" {
+		return write(os.Stdout)
+	}
+	out, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	if err := write(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Plain text report
+func textReport(w io.Writer, sum tensile.Results) error {
+	if len(sum.Tags) > 0 {
+		fmt.Fprintf(w, "Tags:\t\t%s\n\n", sum.Tags.List())
+	}
+	if sum.Seed != 0 {
+		fmt.Fprintf(w, "Seed:\t\t%d\n", sum.Seed)
+	}
+	if sum.Retries > 0 {
+		fmt.Fprintf(w, "Retries:\t%d\n", sum.Retries)
+	}
+	if sum.Hedges > 0 {
+		fmt.Fprintf(w, "Hedges:\t\t%d\n", sum.Hedges)
+	}
+	if sum.Aborted > 0 {
+		fmt.Fprintf(w, "Aborted:\t%d (cancelled in flight, not counted)\n", sum.Aborted)
+	}
+	if len(sum.Checks) > 0 {
+		fmt.Fprintf(w, "Failed checks:\t%s\n", counts(sum.Checks))
+	}
+	if len(sum.Expect) > 0 {
+		fmt.Fprintf(w, "Expect 100:\t%s\n", counts(sum.Expect))
+	}
+	if len(sum.GRPC) > 0 {
+		fmt.Fprintf(w, "gRPC status:\t%s\n", counts(sum.GRPC))
+	}
+	if len(sum.Compare) > 0 {
+		fmt.Fprintf(w, "Mismatches:\t%.2f%% (%s)\n", sum.MismatchRate()*100, counts(sum.Compare))
+	}
+	fmt.Fprintf(w, "Replies:\t%d\nTotal size:\t%s\n", sum.Replies, byteSize(float64(sum.Bytes)))
+	if sum.Decoded > 0 {
+		fmt.Fprintf(w, "Decoded size:\t%s (%.2fx)\n", byteSize(float64(sum.Decoded)), sum.CompressionRatio())
+	}
+	if n := sum.Status[http.StatusNotModified]; n > 0 {
+		fmt.Fprintf(w, "Not modified:\t%d (%.2f%%), %s saved\n", n, sum.NotModifiedRatio()*100, byteSize(float64(sum.Saved)))
+	}
+	if sum.Stopped != "" {
+		fmt.Fprintf(w, "Stopped early:\t%s\n", sum.Stopped)
+	}
+	fmt.Fprintf(w, "Total time:\t%s\nAverage time:\t%s\n\n", sum.Duration, sum.Average)
+	fmt.Fprintf(w, "Throughput:\t%.2f req/s\nLatency min:\t%s\nLatency mean:\t%s\n", sum.Throughput, sum.Min, sum.Mean)
+	for _, p := range tensile.Percentiles {
+		fmt.Fprintf(w, "Latency %s:\t%s\n", tensile.PercentileName(p), sum.Percentiles[tensile.PercentileName(p)])
+	}
+	_, err := fmt.Fprintf(w, "Latency max:\t%s\n\n", sum.Max)
+	if err != nil {
+		return err
+	}
+	if len(sum.Connections) > 0 || sum.Conns != nil {
+		if err := connUse(w, sum); err != nil {
+			return err
+		}
+	}
+	if len(sum.Certs) > 0 {
+		if err := certs(w, sum.Certs); err != nil {
+			return err
+		}
+	}
+	if sum.DNS != nil {
+		if err := dnsTimes(w, sum.DNS); err != nil {
+			return err
+		}
+	}
+	if sum.PageLoad != nil {
+		if err := pageLoadTimes(w, sum.PageLoad); err != nil {
+			return err
+		}
+	}
+	if sum.FirstByte != nil {
+		if err := chunkTimes(w, sum); err != nil {
+			return err
+		}
+	}
+	if len(sum.Pauses) > 0 {
+		if err := pauses(w, sum.Pauses); err != nil {
+			return err
+		}
+	}
+	if sum.Client != nil {
+		if err := clientUse(w, sum.Client); err != nil {
+			return err
+		}
+	}
+	if sum.Transfer != nil {
+		if err := transferTimes(w, sum); err != nil {
+			return err
+		}
+	}
+	if len(sum.Audit) > 0 {
+		if err := headerAudit(w, sum.Audit); err != nil {
+			return err
+		}
+	}
+	if len(sum.StatusLatency) > 1 {
+		if err := statusLatency(w, sum.StatusLatency); err != nil {
+			return err
+		}
+	}
+	if len(sum.ServerTiming) > 0 {
+		if err := metricTable(w, "Server-Timing", sum.ServerTiming); err != nil {
+			return err
+		}
+	}
+	if len(sum.JSONMetrics) > 0 {
+		if err := metricTable(w, "JSON metric", sum.JSONMetrics); err != nil {
+			return err
+		}
+	}
+	if len(sum.Targets) > 0 {
+		names := make([]string, len(sum.Targets))
+		rs := make([]tensile.Results, len(sum.Targets))
+		for i, t := range sum.Targets {
+			names[i], rs[i] = t.Target, t.Results
+		}
+		if err := breakdown(w, "Target", names, rs); err != nil {
+			return err
+		}
+	}
+	if len(sum.Methods) > 0 {
+		names := make([]string, len(sum.Methods))
+		rs := make([]tensile.Results, len(sum.Methods))
+		for i, m := range sum.Methods {
+			names[i], rs[i] = m.Method, m.Results
+		}
+		if err := breakdown(w, "Method", names, rs); err != nil {
+			return err
+		}
+	}
+	if len(sum.Backends) > 0 {
+		names := make([]string, len(sum.Backends))
+		rs := make([]tensile.Results, len(sum.Backends))
+		for i, b := range sum.Backends {
+			names[i], rs[i] = b.Backend, b.Results
+			if b.Outlier != "" {
+				names[i] += " (outlier)"
+			}
+		}
+		if err := breakdown(w, "Backend", names, rs); err != nil {
+			return err
+		}
+		if err := outliers(w, sum.Backends); err != nil {
+			return err
+		}
+	}
+	if len(sum.Phases) > 0 {
+		names := make([]string, len(sum.Phases))
+		rs := make([]tensile.Results, len(sum.Phases))
+		for i, p := range sum.Phases {
+			names[i], rs[i] = p.Phase, p.Results
+		}
+		return breakdown(w, "Phase", names, rs)
+	}
+	return nil
+}
+
+// Counts, in name order
+func counts(m map[string]int64) string {
+	names := make([]string, 0, len(m))
+	for c := range m {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	for i, c := range names {
+		names[i] = fmt.Sprintf("%s %d", c, m[c])
+	}
+	return strings.Join(names, ", ")
+}
+
+// Values of each audited header, most common first, flagging headers
+// inconsistent between responses
+func headerAudit(w io.Writer, audit []tensile.HeaderAudit) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Header\tResponses\tValue\n")
+	for _, h := range audit {
+		name := h.Header
+		if !h.Consistent() {
+			name += " (inconsistent)"
+		}
+		for _, v := range auditValues(h) {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", name, h.Values[v], v)
+			name = ""
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// Values of an audited header, most common first
+func auditValues(h tensile.HeaderAudit) []string {
+	vs := make([]string, 0, len(h.Values))
+	for v := range h.Values {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		if h.Values[vs[i]] != h.Values[vs[j]] {
+			return h.Values[vs[i]] > h.Values[vs[j]]
+		}
+		return vs[i] < vs[j]
+	})
+	return vs
+}
+
+// Long poll transfer times, and the polls held over the timeline. Latency is
+// the time polls were held.
+func connUse(w io.Writer, sum tensile.Results) error {
+	if len(sum.Connections) > 0 {
+		fmt.Fprintf(w, "Connections:\t%d IPv4, %d IPv6\n", sum.Connections["tcp4"], sum.Connections["tcp6"])
+	}
+	if c := sum.Conns; c != nil {
+		fmt.Fprintf(w, "Conn reuse:\t%d new, %d reused (%.2f%%)\n", c.New, c.Reused, c.ReuseRatio()*100)
+		if c.TLSFull+c.TLSResumed > 0 {
+			fmt.Fprintf(w, "TLS handshakes:\t%d full, %d resumed\n", c.TLSFull, c.TLSResumed)
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// Times the breaker paused dispatching
+func pauses(w io.Writer, ps []tensile.Pause) error {
+	var total time.Duration
+	for _, p := range ps {
+		total += p.Duration
+	}
+	fmt.Fprintf(w, "Breaker pauses:\t%d, %s in all\n", len(ps), total.Round(time.Millisecond))
+	for _, p := range ps {
+		fmt.Fprintf(w, "\tat %s for %s, at %.2f%% errors\n", p.Start.Round(time.Millisecond), p.Duration.Round(time.Millisecond), p.ErrorRate*100)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// Certificate chains, leaf first, flagging those expiring soon
+func certs(w io.Writer, chains []tensile.CertChain) error {
+	for _, ch := range chains {
+		fmt.Fprintf(w, "Certificates:\t%s\n", ch.Host)
+		for _, c := range ch.Chain {
+			expiry := fmt.Sprintf("expires %s", c.NotAfter.Format(time.DateOnly))
+			if c.Expiring {
+				expiry = fmt.Sprintf("EXPIRES %s, in %d days", c.NotAfter.Format(time.DateOnly), int(time.Until(c.NotAfter).Hours()/24))
+			}
+			fmt.Fprintf(w, "\t%s, issued by %s, %s\n", c.Subject, c.Issuer, expiry)
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// Times to load pages and their assets
+func pageLoadTimes(w io.Writer, t *tensile.Metric) error {
+	fmt.Fprintf(w, "Page loads:\t%d\nPage load mean:\t%s\n", t.Count, t.Mean)
+	for _, p := range tensile.Percentiles {
+		fmt.Fprintf(w, "Page load %s:\t%s\n", tensile.PercentileName(p), t.Percentiles[tensile.PercentileName(p)])
+	}
+	_, err := fmt.Fprintf(w, "Page load max:\t%s\n\n", t.Max)
+	return err
+}
+
+// Times to the first byte of streamed bodies, and between their chunks
+func chunkTimes(w io.Writer, sum tensile.Results) error {
+	t := sum.FirstByte
+	fmt.Fprintf(w, "First byte mean:\t%s\n", t.Mean)
+	for _, p := range tensile.Percentiles {
+		fmt.Fprintf(w, "First byte %s:\t%s\n", tensile.PercentileName(p), t.Percentiles[tensile.PercentileName(p)])
+	}
+	fmt.Fprintf(w, "First byte max:\t%s\n", t.Max)
+	if g := sum.ChunkGap; g != nil {
+		fmt.Fprintf(w, "Chunks:\t\t%d\nChunk gap mean:\t%s\n", g.Count+t.Count, g.Mean)
+		for _, p := range tensile.Percentiles {
+			fmt.Fprintf(w, "Chunk gap %s:\t%s\n", tensile.PercentileName(p), g.Percentiles[tensile.PercentileName(p)])
+		}
+		fmt.Fprintf(w, "Chunk gap max:\t%s\n", g.Max)
+	}
+	_, err := fmt.Fprintf(w, "Stalls:\t\t%d\n\n", sum.Stalls)
+	return err
+}
+
+func dnsTimes(w io.Writer, t *tensile.Metric) error {
+	fmt.Fprintf(w, "DNS lookups:\t%d\nDNS mean:\t%s\n", t.Count, t.Mean)
+	for _, p := range tensile.Percentiles {
+		fmt.Fprintf(w, "DNS %s:\t%s\n", tensile.PercentileName(p), t.Percentiles[tensile.PercentileName(p)])
+	}
+	_, err := fmt.Fprintf(w, "DNS max:\t%s\n\n", t.Max)
+	return err
+}
+
+// Times to read bodies and, for long polls, the polls held over the timeline
+func transferTimes(w io.Writer, sum tensile.Results) error {
+	t := sum.Transfer
+	fmt.Fprintf(w, "Transfer mean:\t%s\n", t.Mean)
+	for _, p := range tensile.Percentiles {
+		fmt.Fprintf(w, "Transfer %s:\t%s\n", tensile.PercentileName(p), t.Percentiles[tensile.PercentileName(p)])
+	}
+	fmt.Fprintf(w, "Transfer max:\t%s\n", t.Max)
+	if len(sum.Timeline) > 0 {
+		var held, maxHeld int64
+		for _, p := range sum.Timeline {
+			held += p.Held
+			if p.Held > maxHeld {
+				maxHeld = p.Held
+			}
+		}
+		if maxHeld > 0 {
+			fmt.Fprintf(w, "Polls held:\t%.1f mean, %d max\n", float64(held)/float64(len(sum.Timeline)), maxHeld)
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// The load generator's own resource use
+func clientUse(w io.Writer, c *tensile.ClientStats) error {
+	if c.CPU > 0 {
+		fmt.Fprintf(w, "Client CPU:\t%.2f cores mean, %.2f max, of %d\n", c.CPU, c.CPUMax, c.Procs)
+	}
+	fmt.Fprintf(w, "Client memory:\t%s heap max, %d goroutines max", byteSize(float64(c.HeapMax)), c.Goroutines)
+	if c.FDs > 0 {
+		fmt.Fprintf(w, ", %d open files max", c.FDs)
+	}
+	fmt.Fprintf(w, "\nClient GC:\t%d collections, %s paused, %s max\n", c.GCs, c.GCPause, c.GCPauseMax)
+	if c.Paced > 0 {
+		fmt.Fprintf(w, "Schedule:\t%d of %d requests late, %s max lag, %d waited for a worker, %d resets\n", c.Late, c.Paced, c.LagMax, c.Blocked, c.Resets)
+	}
+	if c.Bottleneck != "" {
+		fmt.Fprintf(w, "Bottleneck:\tthe client, not the target, may have limited the results: %s\n", c.Bottleneck)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// Table of metrics, such as those of Server-Timing, in name order
+func metricTable(w io.Writer, title string, ms map[string]tensile.Metric) error {
+	names := make([]string, 0, len(ms))
+	for name := range ms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tCount\tMean\tp50\tp99\tMax\n", title)
+	for _, name := range names {
+		m := ms[name]
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", name, m.Count, m.Mean, m.Percentiles["p50"], m.Percentiles["p99"], m.Max)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// Latency percentiles of each status class
+func statusLatency(w io.Writer, ms map[string]tensile.Metric) error {
+	classes := make([]string, 0, len(ms))
+	for c := range ms {
+		classes = append(classes, c)
+	}
+	sort.Strings(classes)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Status\tCount\tMean")
+	for _, p := range tensile.Percentiles {
+		fmt.Fprintf(tw, "\t%s", tensile.PercentileName(p))
+	}
+	fmt.Fprintf(tw, "\tMax\n")
+	for _, c := range classes {
+		m := ms[c]
+		fmt.Fprintf(tw, "%s\t%d\t%s", c, m.Count, m.Mean)
+		for _, p := range tensile.Percentiles {
+			fmt.Fprintf(tw, "\t%s", m.Percentiles[tensile.PercentileName(p)])
+		}
+		fmt.Fprintf(tw, "\t%s\n", m.Max)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// Table of results broken down by target or phase
+func breakdown(w io.Writer, title string, names []string, rs []tensile.Results) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tRequests\tThroughput\tErrors\tSize\tp50\tp99\tMax\n", title)
+	for i, r := range rs {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f req/s\t%.2f%%\t%s\t%s\t%s\t%s\n", names[i], r.Requests, r.Throughput, r.ErrorRate*100, byteSize(float64(r.Bytes)), r.Percentiles["p50"], r.Percentiles["p99"], r.Max)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// How any backends stand out from the others
+func outliers(w io.Writer, bs []tensile.BackendResults) error {
+	n := 0
+	for _, b := range bs {
+		if b.Outlier != "" {
+			fmt.Fprintf(w, "Outlier:\t%s, %s\n", b.Backend, b.Outlier)
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// Latency distribution in HdrHistogram's .hgrm format, in milliseconds
+func hgrmReport(w io.Writer, sum tensile.Results) error {
+	if sum.Histogram == nil {
+		return errors.New(hgrmError)
+	}
+	return sum.Histogram.WriteHgrm(w, time.Millisecond)
+}
+
+// JSON report
+func jsonReport(w io.Writer, sum tensile.Results) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(sum)
+}
+
+// CSV columns of a summary
+func csvHeader() []string {
+	h := []string{"requests", "replies", "errors", "error_rate", "bytes", "duration_ns", "throughput", "min_ns", "mean_ns"}
+	for _, p := range tensile.Percentiles {
+		h = append(h, tensile.PercentileName(p)+"_ns")
+	}
+	return append(h, "max_ns")
+}
+
+// CSV row of a summary
+func csvRow(sum tensile.Results) []string {
+	r := []string{
+		strconv.FormatInt(sum.Requests, 10),
+		strconv.FormatInt(sum.Replies, 10),
+		strconv.FormatInt(sum.Errors, 10),
+		strconv.FormatFloat(sum.ErrorRate, 'f', -1, 64),
+		strconv.FormatInt(sum.Bytes, 10),
+		strconv.FormatInt(int64(sum.Duration), 10),
+		strconv.FormatFloat(sum.Throughput, 'f', 2, 64),
+		strconv.FormatInt(int64(sum.Min), 10),
+		strconv.FormatInt(int64(sum.Mean), 10),
+	}
+	for _, p := range tensile.Percentiles {
+		r = append(r, strconv.FormatInt(int64(sum.Percentiles[tensile.PercentileName(p)]), 10))
+	}
+	return append(r, strconv.FormatInt(int64(sum.Max), 10))
+}
+
+// CSV report, the summary followed by the timeline
+func csvReport(w io.Writer, sum tensile.Results) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader())
+	cw.Write(csvRow(sum))
+	if len(sum.Timeline) > 0 {
+		cw.Write(nil)
+		cw.Write([]string{"offset_ns", "requests", "errors", "throughput", "p50_ns", "p99_ns", "held"})
+		for _, p := range sum.Timeline {
+			cw.Write([]string{
+				strconv.FormatInt(int64(p.Offset), 10),
+				strconv.FormatInt(p.Requests, 10),
+				strconv.FormatInt(p.Errors, 10),
+				strconv.FormatFloat(p.Throughput, 'f', 2, 64),
+				strconv.FormatInt(int64(p.P50), 10),
+				strconv.FormatInt(int64(p.P99), 10),
+				strconv.FormatInt(p.Held, 10),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var htmlTmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"size":        func(b int64) string { return byteSize(float64(b)).String() },
+	"pct":         func(f float64) string { return fmt.Sprintf("%.2f%%", f*100) },
+	"chart":       timelineChart,
+	"auditValues": auditValues,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Tensile report: {{.URL}}</title>
+<style>
+body { font-family: sans-serif; }
+td, th { padding: 0.2em 1em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Tensile report</h1>
+<table>
+<tr><th>Target URL</th><td>{{.URL}}</td></tr>
+{{range $k, $v := .Tags}}<tr><th>{{$k}}</th><td>{{$v}}</td></tr>
+{{end}}<tr><th>Requests</th><td>{{.Requests}}</td></tr>
+<tr><th>Replies</th><td>{{.Replies}}</td></tr>
+<tr><th>Errors</th><td>{{.Errors}} ({{pct .ErrorRate}})</td></tr>
+{{if .Aborted}}<tr><th>Aborted</th><td>{{.Aborted}} (cancelled in flight, not counted)</td></tr>
+{{end}}<tr><th>Total size</th><td>{{size .Bytes}}</td></tr>
+{{if index .Status 304}}<tr><th>Not modified</th><td>{{index .Status 304}} ({{pct .NotModifiedRatio}}), {{size .Saved}} saved</td></tr>
+{{end}}{{if .Decoded}}<tr><th>Decoded size</th><td>{{size .Decoded}} ({{printf "%.2f" .CompressionRatio}}x)</td></tr>
+{{end}}{{if .Stopped}}<tr><th>Stopped early</th><td>{{.Stopped}}</td></tr>
+{{end}}<tr><th>Total time</th><td>{{.Duration}}</td></tr>
+<tr><th>Throughput</th><td>{{printf "%.2f" .Throughput}} req/s</td></tr>
+</table>
+<h2>Latency</h2>
+<table>
+<tr><th>min</th><td>{{.Min}}</td></tr>
+<tr><th>mean</th><td>{{.Mean}}</td></tr>
+{{range $p, $d := .Percentiles}}<tr><th>{{$p}}</th><td>{{$d}}</td></tr>
+{{end}}<tr><th>max</th><td>{{.Max}}</td></tr>
+</table>
+{{with .Timeline}}<h2>Timeline</h2>
+{{chart .}}
+<table>
+<tr><th>Offset</th><th>Requests</th><th>Errors</th><th>Throughput</th><th>p50</th><th>p99</th><th>Held</th></tr>
+{{range .}}<tr><td>{{.Offset}}</td><td>{{.Requests}}</td><td>{{.Errors}}</td><td>{{printf "%.2f" .Throughput}} req/s</td><td>{{.P50}}</td><td>{{.P99}}</td><td>{{.Held}}</td></tr>
+{{end}}</table>
+{{end}}{{if or .Connections .Conns}}<h2>Connections</h2>
+<table>
+{{with .Connections}}<tr><th>IPv4</th><td>{{index . "tcp4"}}</td></tr>
+<tr><th>IPv6</th><td>{{index . "tcp6"}}</td></tr>
+{{end}}{{with .Conns}}<tr><th>Reuse</th><td>{{.New}} new, {{.Reused}} reused ({{pct .ReuseRatio}})</td></tr>
+{{if or .TLSFull .TLSResumed}}<tr><th>TLS handshakes</th><td>{{.TLSFull}} full, {{.TLSResumed}} resumed</td></tr>
+{{end}}{{end}}</table>
+{{end}}{{with .Certs}}<h2>Certificates</h2>
+<table>
+<tr><th>Host</th><th>Subject</th><th>Issuer</th><th>Expires</th></tr>
+{{range .}}{{$h := .Host}}{{range .Chain}}<tr><td>{{$h}}</td><td>{{.Subject}}</td><td>{{.Issuer}}</td><td>{{.NotAfter.Format "2006-01-02"}}{{if .Expiring}} (soon){{end}}</td></tr>
+{{end}}{{end}}</table>
+{{end}}{{with .DNS}}<h2>DNS lookup time</h2>
+<table>
+<tr><th>lookups</th><td>{{.Count}}</td></tr>
+<tr><th>mean</th><td>{{.Mean}}</td></tr>
+{{range $p, $d := .Percentiles}}<tr><th>{{$p}}</th><td>{{$d}}</td></tr>
+{{end}}<tr><th>max</th><td>{{.Max}}</td></tr>
+</table>
+{{end}}{{with .PageLoad}}<h2>Page load time</h2>
+<table>
+<tr><th>page loads</th><td>{{.Count}}</td></tr>
+<tr><th>mean</th><td>{{.Mean}}</td></tr>
+{{range $p, $d := .Percentiles}}<tr><th>{{$p}}</th><td>{{$d}}</td></tr>
+{{end}}<tr><th>max</th><td>{{.Max}}</td></tr>
+</table>
+{{end}}{{with .FirstByte}}<h2>Streaming</h2>
+<table>
+<tr><th>first byte mean</th><td>{{.Mean}}</td></tr>
+{{range $p, $d := .Percentiles}}<tr><th>first byte {{$p}}</th><td>{{$d}}</td></tr>
+{{end}}<tr><th>first byte max</th><td>{{.Max}}</td></tr>
+{{with $.ChunkGap}}<tr><th>chunk gap mean</th><td>{{.Mean}}</td></tr>
+{{range $p, $d := .Percentiles}}<tr><th>chunk gap {{$p}}</th><td>{{$d}}</td></tr>
+{{end}}<tr><th>chunk gap max</th><td>{{.Max}}</td></tr>
+{{end}}<tr><th>stalls</th><td>{{$.Stalls}}</td></tr>
+</table>
+{{end}}{{with .Pauses}}<h2>Breaker pauses</h2>
+<table>
+<tr><th>Start</th><th>Duration</th><th>Error rate</th></tr>
+{{range .}}<tr><td>{{.Start}}</td><td>{{.Duration}}</td><td>{{pct .ErrorRate}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Client}}<h2>Load generator</h2>
+<table>
+{{if .CPU}}<tr><th>CPU</th><td>{{printf "%.2f" .CPU}} cores mean, {{printf "%.2f" .CPUMax}} max, of {{.Procs}}</td></tr>
+{{end}}<tr><th>Heap max</th><td>{{size .HeapMax}}</td></tr>
+<tr><th>Goroutines max</th><td>{{.Goroutines}}</td></tr>
+{{if .FDs}}<tr><th>Open files max</th><td>{{.FDs}}</td></tr>
+{{end}}<tr><th>GC</th><td>{{.GCs}} collections, {{.GCPause}} paused, {{.GCPauseMax}} max</td></tr>
+{{if .Paced}}<tr><th>Schedule</th><td>{{.Late}} of {{.Paced}} requests late, {{.LagMax}} max lag, {{.Blocked}} waited for a worker, {{.Resets}} resets</td></tr>
+{{end}}{{if .Bottleneck}}<tr><th>Bottleneck</th><td>The client, not the target, may have limited the results: {{.Bottleneck}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Transfer}}<h2>Transfer time</h2>
+<table>
+<tr><th>mean</th><td>{{.Mean}}</td></tr>
+{{range $p, $d := .Percentiles}}<tr><th>{{$p}}</th><td>{{$d}}</td></tr>
+{{end}}<tr><th>max</th><td>{{.Max}}</td></tr>
+</table>
+{{end}}{{with .Audit}}<h2>Header audit</h2>
+<table>
+<tr><th>Header</th><th>Responses</th><th>Value</th></tr>
+{{range .}}{{$h := .}}{{range auditValues .}}<tr><td>{{$h.Header}}{{if not $h.Consistent}} (inconsistent){{end}}</td><td>{{index $h.Values .}}</td><td>{{.}}</td></tr>
+{{end}}{{end}}</table>
+{{end}}{{if gt (len .StatusLatency) 1}}<h2>Latency by status</h2>
+<table>
+<tr><th>Status</th><th>Count</th><th>Mean</th><th>p50</th><th>p99</th><th>max</th></tr>
+{{range $c, $m := .StatusLatency}}<tr><td>{{$c}}</td><td>{{$m.Count}}</td><td>{{$m.Mean}}</td><td>{{index $m.Percentiles "p50"}}</td><td>{{index $m.Percentiles "p99"}}</td><td>{{$m.Max}}</td></tr>
+{{end}}</table>
+{{end}}{{with .ServerTiming}}<h2>Server-Timing</h2>
+<table>
+<tr><th>Metric</th><th>Count</th><th>Mean</th><th>p50</th><th>p99</th><th>max</th></tr>
+{{range $name, $m := .}}<tr><td>{{$name}}</td><td>{{$m.Count}}</td><td>{{$m.Mean}}</td><td>{{index $m.Percentiles "p50"}}</td><td>{{index $m.Percentiles "p99"}}</td><td>{{$m.Max}}</td></tr>
+{{end}}</table>
+{{end}}{{with .JSONMetrics}}<h2>JSON metrics</h2>
+<table>
+<tr><th>Metric</th><th>Count</th><th>Mean</th><th>p50</th><th>p99</th><th>max</th></tr>
+{{range $name, $m := .}}<tr><td>{{$name}}</td><td>{{$m.Count}}</td><td>{{$m.Mean}}</td><td>{{index $m.Percentiles "p50"}}</td><td>{{index $m.Percentiles "p99"}}</td><td>{{$m.Max}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Targets}}<h2>Targets</h2>
+<table>
+<tr><th>Target</th><th>Requests</th><th>Throughput</th><th>Errors</th><th>Size</th><th>p50</th><th>p99</th><th>max</th></tr>
+{{range .}}<tr><td>{{.Target}}</td><td>{{.Requests}}</td><td>{{printf "%.2f" .Throughput}} req/s</td><td>{{pct .ErrorRate}}</td><td>{{size .Bytes}}</td><td>{{index .Percentiles "p50"}}</td><td>{{index .Percentiles "p99"}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Methods}}<h2>Methods</h2>
+<table>
+<tr><th>Method</th><th>Requests</th><th>Throughput</th><th>Errors</th><th>Size</th><th>p50</th><th>p99</th><th>max</th></tr>
+{{range .}}<tr><td>{{.Method}}</td><td>{{.Requests}}</td><td>{{printf "%.2f" .Throughput}} req/s</td><td>{{pct .ErrorRate}}</td><td>{{size .Bytes}}</td><td>{{index .Percentiles "p50"}}</td><td>{{index .Percentiles "p99"}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Backends}}<h2>Backends</h2>
+<table>
+<tr><th>Backend</th><th>Requests</th><th>Throughput</th><th>Errors</th><th>Size</th><th>p50</th><th>p99</th><th>max</th></tr>
+{{range .}}<tr><td>{{.Backend}}{{with .Outlier}} (outlier: {{.}}){{end}}</td><td>{{.Requests}}</td><td>{{printf "%.2f" .Throughput}} req/s</td><td>{{pct .ErrorRate}}</td><td>{{size .Bytes}}</td><td>{{index .Percentiles "p50"}}</td><td>{{index .Percentiles "p99"}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Phases}}<h2>Phases</h2>
+<table>
+<tr><th>Phase</th><th>Requests</th><th>Throughput</th><th>Errors</th><th>p50</th><th>p99</th><th>max</th></tr>
+{{range .}}<tr><td>{{.Phase}}</td><td>{{.Requests}}</td><td>{{printf "%.2f" .Throughput}} req/s</td><td>{{pct .ErrorRate}}</td><td>{{index .Percentiles "p50"}}</td><td>{{index .Percentiles "p99"}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Status codes</h2>
+<table>
+{{range $c, $n := .Status}}<tr><th>{{$c}}</th><td>{{$n}}</td></tr>
+{{end}}</table>
+{{with .Checks}}<h2>Failed checks</h2>
+<table>
+{{range $c, $n := .}}<tr><th>{{$c}}</th><td>{{$n}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Expect}}<h2>Expect: 100-continue</h2>
+<table>
+{{range $o, $n := .}}<tr><th>{{$o}}</th><td>{{$n}}</td></tr>
+{{end}}</table>
+{{end}}{{with .GRPC}}<h2>gRPC status</h2>
+<table>
+{{range $c, $n := .}}<tr><th>{{$c}}</th><td>{{$n}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Compare}}<h2>Comparison, {{pct .MismatchRate}} mismatched</h2>
+<table>
+{{range $c, $n := .Compare}}<tr><th>{{$c}}</th><td>{{$n}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// SVG chart of throughput and p99 latency over the timeline, each scaled to
+// its own maximum
+func timelineChart(tl []tensile.Point) template.HTML {
+	const w, h = 800, 200
+	var maxRPS float64
+	var maxP99 time.Duration
+	for _, p := range tl {
+		if p.Throughput > maxRPS {
+			maxRPS = p.Throughput
+		}
+		if p.P99 > maxP99 {
+			maxP99 = p.P99
+		}
+	}
+	line := func(y func(tensile.Point) float64) string {
+		var b strings.Builder
+		for i, p := range tl {
+			x := 0.0
+			if len(tl) > 1 {
+				x = float64(i) / float64(len(tl)-1) * w
+			}
+			fmt.Fprintf(&b, "%.1f,%.1f ", x, h-y(p)*h)
+		}
+		return b.String()
+	}
+	rps := line(func(p tensile.Point) float64 {
+		if maxRPS == 0 {
+			return 0
+		}
+		return p.Throughput / maxRPS
+	})
+	p99 := line(func(p tensile.Point) float64 {
+		if maxP99 == 0 {
+			return 0
+		}
+		return float64(p.P99) / float64(maxP99)
+	})
+	return template.HTML(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 -5 %d %d">
+<polyline fill="none" stroke="steelblue" stroke-width="2" points="%s"/>
+<polyline fill="none" stroke="firebrick" stroke-width="2" points="%s"/>
+</svg>
+<p><span style="color: steelblue">Throughput</span> (max %.2f req/s), <span style="color: firebrick">p99</span> (max %s)</p>`,
+		w, h+10, w, h+10, rps, p99, maxRPS, maxP99))
+}
+
+// HTML report
+func htmlReport(w io.Writer, sum tensile.Results) error {
+	return htmlTmpl.Execute(w, sum)
+}
+
+// Report subcommand
+func reportCmd(args []string) {
+	reportFlags.Parse(args)
+	setupLogging()
+	if reportFlags.NArg() != 1 {
+		reportFlags.Usage()
+		log.Fatal(fmt.Errorf("\n%s", reportFileError))
+	}
+	ts, err := tensile.ParseThresholds(thresholdStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sum, info, err := readRecording(reportFlags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outputFormat == "text" && outputFile == "" {
+		infof("\n\t%s\n\n", tensile.App+tensile.Version)
+		infof("Results file:\t%s\nTarget URL:\t%s\nRecorded:\t%s\nRequests:\t%d\nConcurrent:\t%d\n\n",
+			reportFlags.Arg(0), info.URL, info.Start.Format(time.RFC1123), info.Requests, info.Concurrent)
+	}
+	if info.Sample > 1 {
+		infof(sampledNotice, info.Sample)
+	}
+	if err := writeReport(sum); err != nil {
+		log.Fatal(fmt.Errorf(reportWriteError, err))
+	}
+	if !checkThresholds(ts, sum) {
+		os.Exit(1)
+	}
+}
+
+// Outcome of a threshold
+type thresholdOutcome struct {
+	Expr   string `json:"expr"`
+	Pass   bool   `json:"pass"`
+	Actual string `json:"actual"`
+}
+
+// Check thresholds against results, printing each outcome
+func checkThresholds(ts []tensile.Threshold, res tensile.Results) bool {
+	_, ok := thresholdOutcomes(ts, res)
+	return ok
+}
+
+// Check thresholds against results, printing and returning each outcome, and
+// whether all passed
+func thresholdOutcomes(ts []tensile.Threshold, res tensile.Results) ([]thresholdOutcome, bool) {
+	ok := true
+	var outcomes []thresholdOutcome
+	for _, t := range ts {
+		pass, actual := t.Check(res)
+		outcomes = append(outcomes, thresholdOutcome{t.Expr, pass, actual})
+		if pass {
+			infof(thresholdPass, t.Expr, actual)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, thresholdFail, t.Expr, actual)
+		ok = false
+	}
+	return outcomes, ok
+}
+
+// Summarise a recording made with -record
+func readRecording(path string) (tensile.Results, tensile.RunInfo, error) {
+	st := tensile.NewStats()
+	info, err := tensile.ReadRecording(path, st.Add)
+	if err != nil {
+		return tensile.Results{}, info, fmt.Errorf("%s: %w", path, err)
+	}
+	sum := st.Summary(info.URL, st.Elapsed())
+	sum.Tags = info.Tags
+	return sum, info, nil
+}
+
+// Read a JSON report written with -output json, or summarise a recording
+func readResults(path string) (tensile.Results, error) {
+	var res tensile.Results
+	f, err := os.Open(path)
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(512)
+	if !bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n"), []byte("{")) {
+		res, info, err := readRecording(path)
+		if info.Sample > 1 {
+			infof(sampledNotice, info.Sample)
+		}
+		return res, err
+	}
+	err = json.NewDecoder(br).Decode(&res)
+	return res, err
+}
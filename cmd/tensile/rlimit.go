@@ -0,0 +1,30 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Open files kept in reserve for recordings, logs and the like
+const fdReserve = 64
+
+// Check the open file limit allows n connections, raising the soft limit to
+// the hard limit if it doesn't
+func checkFileLimit(n int) error {
+	need := uint64(n + fdReserve)
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil || uint64(lim.Cur) >= need {
+		return nil
+	}
+	soft := uint64(lim.Cur)
+	if uint64(lim.Max) >= need {
+		lim.Cur = lim.Max
+		if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lim); err == nil {
+			infof(fdRaised, soft, uint64(lim.Cur))
+			return nil
+		}
+	}
+	return fmt.Errorf(fdError, n, need, soft, uint64(lim.Max))
+}
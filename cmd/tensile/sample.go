@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	sampleStr string
+	sample    int // Record one in sample results, with -record or -sqlite
+
+	sampleError       = "ERROR: invalid -sample %q, expected 1/N, e.g. 1/100\n"
+	sampleRecordError = "ERROR: -sample requires -record or -sqlite\n"
+	sampledNotice     = "NOTICE: only 1 in %d results was recorded, so counts and throughput are of the sample\n\n"
+)
+
+func init() {
+	attackFlags.StringVar(&sampleStr, "sample", "", "Record only one in N results with -record or -sqlite, e.g. 1/100; all are still counted")
+}
+
+// Parse -sample as 1/N
+func parseSample() error {
+	if sampleStr == "" {
+		return nil
+	}
+	if recordFile == "" && sqlitePath == "" {
+		return errors.New(sampleRecordError)
+	}
+	one, n, ok := strings.Cut(sampleStr, "/")
+	var err error
+	if ok && one == "1" {
+		sample, err = strconv.Atoi(n)
+	}
+	if !ok || one != "1" || err != nil || sample < 1 {
+		return fmt.Errorf(sampleError, sampleStr)
+	}
+	return nil
+}
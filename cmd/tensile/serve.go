@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+// Finished tests kept for the API, beyond which the oldest are forgotten
+const keptTests = 100
+
+var (
+	apiToken string
+
+	serveFlags = flag.NewFlagSet("serve", flag.ExitOnError)
+
+	serveListening = "API listening on %s\n"
+	testIDError    = "ERROR: no such test %q\n"
+	testDoneError  = "ERROR: test %d is not running\n"
+	serveOpenError = "ERROR: -token is required unless -listen is a loopback address\n"
+)
+
+const (
+	testRunning = "running"
+	testStopped = "stopped"
+	testDone    = "done"
+)
+
+func init() {
+	serveFlags.Usage = usageFor(serveFlags, "tensile serve [flags]")
+	serveFlags.StringVar(&listenAddr, "listen", "127.0.0.1:8080", "Address to serve the control API on; -token is required unless it's a loopback address")
+	serveFlags.StringVar(&apiToken, "token", "", "Bearer token required by the control API")
+}
+
+// Test managed by the control API
+type test struct {
+	ID       int              `json:"id"`
+	Job      job              `json:"job"`
+	Tags     tensile.Tags     `json:"tags,omitempty"`
+	Status   string           `json:"status"`
+	Started  time.Time        `json:"started"`
+	Finished *time.Time       `json:"finished,omitempty"`
+	Requests int64            `json:"requests"`
+	Errors   int64            `json:"errors"`
+	Summary  *tensile.Results `json:"summary,omitempty"`
+
+	cancel   context.CancelFunc
+	stopped  bool
+	attacker *tensile.Attacker
+}
+
+// Update the progress of a running test. Called with s.mu held.
+func (t *test) poll() {
+	if t.Status == testRunning {
+		t.Requests, t.Errors = t.attacker.Progress()
+	}
+}
+
+// Control API server
+type server struct {
+	mu     sync.Mutex
+	tests  []*test // Oldest first
+	lastID int
+}
+
+// Write v as JSON
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("writing response", "err", err)
+	}
+}
+
+// Write an error as JSON
+func writeJSONError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// Require the bearer token, if set
+func (s *server) auth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if apiToken != "" {
+			auth := []byte(r.Header.Get("Authorization"))
+			if subtle.ConstantTimeCompare(auth, []byte("Bearer "+apiToken)) != 1 {
+				writeJSONError(w, http.StatusUnauthorized, errors.New(agentAuthError))
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// Find a test by id. Called with s.mu held.
+func (s *server) find(idStr string) (*test, error) {
+	id, err := strconv.Atoi(idStr)
+	if err == nil {
+		for _, t := range s.tests {
+			if t.ID == id {
+				return t, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf(testIDError, idStr)
+}
+
+// Forget the oldest finished tests beyond keptTests. Called with s.mu held.
+func (s *server) prune() {
+	finished := 0
+	for _, t := range s.tests {
+		if t.Status != testRunning {
+			finished++
+		}
+	}
+	kept := s.tests[:0]
+	for _, t := range s.tests {
+		if t.Status != testRunning && finished > keptTests {
+			finished--
+			continue
+		}
+		kept = append(kept, t)
+	}
+	clear(s.tests[len(kept):])
+	s.tests = kept
+}
+
+// Route /tests and /tests/{id}[/stop]
+func (s *server) route(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tests"), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "" && r.Method == "POST":
+		s.start(w, r)
+	case path == "" && r.Method == "GET":
+		s.list(w, r)
+	case len(parts) == 1 && r.Method == "GET":
+		s.show(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "stop" && r.Method == "POST":
+		s.halt(w, r, parts[0])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Run a test to completion, or until stopped. Its progress is polled from
+// its Attacker when it's shown.
+func (s *server) run(ctx context.Context, t *test) {
+	cfg := t.attacker.Config()
+	l := slog.With("test", t.ID)
+	l.Info("attacking", "url", cfg.URL, "requests", cfg.Requests, "concurrent", cfg.Concurrent)
+	res, _ := t.attacker.Attack(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	t.Finished = &now
+	t.Summary = &res
+	t.Requests, t.Errors = res.Requests, res.Errors
+	t.Status = testDone
+	if t.stopped {
+		t.Status = testStopped
+	}
+	l.Info("test "+t.Status, "requests", t.Requests, "errors", t.Errors)
+}
+
+// POST /tests starts a test
+func (s *server) start(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		job
+		Tags tensile.Tags `json:"tags"`
+	}{job: job{Requests: 50, Concurrent: 5, MaxErrors: 1}}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf(agentJobError, err))
+		return
+	}
+	cfg := req.job.config()
+	cfg.Tags = req.Tags
+	if err := cfg.Validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf(agentJobError, err))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	s.lastID++
+	t := &test{
+		ID:      s.lastID,
+		Job:     req.job,
+		Tags:    req.Tags,
+		Status:  testRunning,
+		Started: time.Now(),
+	}
+	cfg.Logger = slog.With("test", t.ID)
+	t.attacker = tensile.NewAttacker(tensile.WithConfig(cfg))
+	var ctx context.Context
+	ctx, t.cancel = context.WithCancel(context.Background())
+	s.tests = append(s.tests, t)
+	go s.run(ctx, t)
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// GET /tests lists all tests
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tests {
+		t.poll()
+	}
+	writeJSON(w, http.StatusOK, s.tests)
+}
+
+// GET /tests/{id} shows the status and results of a test
+func (s *server) show(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.find(id)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	t.poll()
+	writeJSON(w, http.StatusOK, t)
+}
+
+// POST /tests/{id}/stop aborts a running test
+func (s *server) halt(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.find(id)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	if t.Status != testRunning {
+		writeJSONError(w, http.StatusConflict, fmt.Errorf(testDoneError, t.ID))
+		return
+	}
+	t.stopped = true
+	t.cancel()
+	writeJSON(w, http.StatusAccepted, t)
+}
+
+// Serve subcommand
+func serveCmd(args []string) {
+	serveFlags.Parse(args)
+	setupLogging()
+	if apiToken == "" && !loopback(listenAddr) {
+		log.Fatal(errors.New(serveOpenError))
+	}
+	s := &server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tests", s.auth(s.route))
+	mux.HandleFunc("/tests/", s.auth(s.route))
+	infof("\n\t%s\n\n", tensile.App+tensile.Version)
+	infof(serveListening, listenAddr)
+	log.Fatal(http.ListenAndServe(listenAddr, mux))
+}
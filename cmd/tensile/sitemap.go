@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	sitemapURL      string
+	sitemapPriority bool
+
+	sitemapError        = "ERROR: unable to load -sitemap: %s\n"
+	sitemapTargetsError = "ERROR: -sitemap can't be used with -targets\n"
+)
+
+func init() {
+	attackFlags.StringVar(&sitemapURL, "sitemap", "", "URL of a sitemap.xml, or sitemap index, whose pages are the targets, named for the sitemap listing them")
+	attackFlags.BoolVar(&sitemapPriority, "sitemap-priority", false, "Request the pages of -sitemap in proportion to their priority")
+}
+
+const (
+	maxSitemaps    = 1000     // Sitemaps fetched, at most, following indexes
+	maxSitemapSize = 50 << 20 // Bytes in a sitemap, uncompressed, as sitemaps.org allows
+	sitemapTimeout = 30 * time.Second
+)
+
+// Fetch a sitemap, which may be gzipped
+func fetchSitemap(u string) (tensile.Sitemap, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sitemapTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return tensile.Sitemap{}, err
+	}
+	req.Header.Set("User-Agent", tensile.App+tensile.Version)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return tensile.Sitemap{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return tensile.Sitemap{}, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	br := bufio.NewReader(resp.Body)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return tensile.Sitemap{}, fmt.Errorf("%s: %s", u, err)
+		}
+		r = zr
+	}
+	s, err := tensile.ParseSitemap(io.LimitReader(r, maxSitemapSize))
+	if err != nil {
+		return s, fmt.Errorf("%s: %s", u, err)
+	}
+	return s, nil
+}
+
+// Targets from the pages of a sitemap, following sitemap indexes. With
+// -sitemap-priority, pages are repeated up to 10 times by priority,
+// interleaved so they're spread over the run.
+func loadSitemap(root string) ([]tensile.Target, error) {
+	type page struct {
+		target tensile.Target
+		weight int
+	}
+	var pages []page
+	seen := map[string]bool{root: true}
+	queue := []string{root}
+	for fetched := 0; len(queue) > 0 && fetched < maxSitemaps; fetched++ {
+		sm := queue[0]
+		queue = queue[1:]
+		s, err := fetchSitemap(sm)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range s.Sitemaps {
+			if !seen[m] {
+				seen[m] = true
+				queue = append(queue, m)
+			}
+		}
+		for _, p := range s.URLs {
+			if u, err := url.Parse(p.Loc); err != nil || (u.Scheme != "http" && u.Scheme != "https") || seen[p.Loc] {
+				continue
+			}
+			seen[p.Loc] = true
+			w := 1
+			if sitemapPriority {
+				w = int(math.Max(1, math.Round(p.Priority*10)))
+			}
+			pages = append(pages, page{tensile.Target{Name: sm, URL: p.Loc}, w})
+		}
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("no pages in sitemap")
+	}
+	var ts []tensile.Target
+	for round := 0; ; round++ {
+		n := len(ts)
+		for _, p := range pages {
+			if p.weight > round {
+				ts = append(ts, p.target)
+			}
+		}
+		if len(ts) == n {
+			return ts, nil
+		}
+	}
+}
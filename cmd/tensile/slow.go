@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	slowURL           string
+	slowConnections   int
+	slowMode          string
+	slowRate          float64
+	slowBodySize      string
+	slowDuration      time.Duration
+	slowTimeout       time.Duration
+	slowFlags         = flag.NewFlagSet("slow", flag.ExitOnError)
+	slowFormatError   = "ERROR: unsupported -output format %q for slow, expected text or json\n"
+	slowBodySizeError = "ERROR: invalid -body-size %q, expected a size such as 1MB\n"
+)
+
+func init() {
+	slowFlags.Usage = usageFor(slowFlags, "tensile slow [flags]")
+	slowFlags.StringVar(&slowURL, "url", "http://localhost/", "Target URL")
+	slowFlags.StringVar(&slowURL, "u", "http://localhost/", "Target URL (short flag)")
+	slowFlags.IntVar(&slowConnections, "connections", 100, "Connections opened at once")
+	slowFlags.StringVar(&slowMode, "mode", tensile.SlowHeaders, "Send the headers (headers) or the body (body) slowly")
+	slowFlags.Float64Var(&slowRate, "byte-rate", 1, "Bytes per second sent on each connection")
+	slowFlags.StringVar(&slowBodySize, "body-size", "1MB", "Content-Length declared with -mode=body")
+	slowFlags.DurationVar(&slowDuration, "duration", 5*time.Minute, "Longest time to hold the connections open")
+	slowFlags.DurationVar(&slowTimeout, "timeout", 10*time.Second, "Connect timeout")
+	slowFlags.StringVar(&outputFormat, "output", "text", "Report format (text, json)")
+	slowFlags.StringVar(&outputFile, "o", "", "Write the report to a file instead of stdout")
+}
+
+// Slow client subcommand
+func slowCmd(args []string) {
+	slowFlags.Parse(args)
+	setupLogging()
+	size, err := parseByteSize(slowBodySize)
+	if err != nil {
+		log.Fatal(fmt.Errorf("\n"+slowBodySizeError, slowBodySize))
+	}
+	var report func(io.Writer, tensile.SlowResults) error
+	switch outputFormat {
+	case "text":
+		report = slowTextReport
+	case "json":
+		report = func(w io.Writer, res tensile.SlowResults) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "\t")
+			return enc.Encode(res)
+		}
+	default:
+		log.Fatal(fmt.Errorf(slowFormatError, outputFormat))
+	}
+	infof("\n\t%s\n\n", tensile.App+tensile.Version)
+	infof("Target URL:\t%s\nConnections:\t%d\nMode:\t\t%s\nByte rate:\t%g/s\nDuration:\t%s\n\n", slowURL, slowConnections, slowMode, slowRate, slowDuration)
+	cfg := tensile.SlowConfig{
+		URL:         slowURL,
+		Connections: slowConnections,
+		Mode:        slowMode,
+		ByteRate:    slowRate,
+		BodySize:    size,
+		Duration:    slowDuration,
+		Timeout:     slowTimeout,
+	}
+	res, err := tensile.AttackSlow(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := writeOutput(func(w io.Writer) error { return report(w, res) }); err != nil {
+		log.Fatal(fmt.Errorf(reportWriteError, err))
+	}
+}
+
+// Plain text slow client report
+func slowTextReport(w io.Writer, res tensile.SlowResults) error {
+	fmt.Fprintf(w, "Connected:\t%d of %d\nFailed:\t\t%d\n", res.Connected, res.Connections, res.Failed)
+	fmt.Fprintf(w, "Closed:\t\t%d\nSurvived:\t%d\n", res.Closed, res.Survived)
+	if len(res.Status) > 0 {
+		status := make(map[string]int64, len(res.Status))
+		for c, n := range res.Status {
+			status[strconv.Itoa(c)] = n
+		}
+		fmt.Fprintf(w, "Answered:\t%s\n", counts(status))
+	}
+	fmt.Fprintf(w, "Sent:\t\t%s\nTotal time:\t%s\n\n", byteSize(float64(res.Sent)), res.Duration)
+	fmt.Fprintf(w, "Tolerated mean:\t%s\n", res.Tolerated.Mean)
+	for _, p := range tensile.Percentiles {
+		fmt.Fprintf(w, "Tolerated %s:\t%s\n", tensile.PercentileName(p), res.Tolerated.Percentiles[tensile.PercentileName(p)])
+	}
+	_, err := fmt.Fprintf(w, "Tolerated max:\t%s\n", res.Tolerated.Max)
+	return err
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	checkpoint     time.Duration
+	checkpointFile string
+
+	checkpointDone = "Checkpoint %d:\t%d requests, %.2f req/s, p99 %s, %.2f%% errors\n"
+)
+
+func init() {
+	attackFlags.DurationVar(&checkpoint, "checkpoint", 0, "Soak mode: summarise every interval, rotate -record files, and bound memory use")
+	attackFlags.StringVar(&checkpointFile, "checkpoint-file", "", "Append each -checkpoint summary to this file as a JSON line")
+}
+
+// Summary of one checkpoint period
+type checkpointSummary struct {
+	Period int           `json:"period"`
+	Start  time.Duration `json:"start_ns"`
+	tensile.Results
+}
+
+// Name of recording n, e.g. run-0003.bin for run.bin
+func rotatedName(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%04d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// Longest results of a period are waited for after it ends, as workers
+// send them on in batches
+const checkpointGrace = 5 * time.Second
+
+// Soak state, fed from the result stream
+type soaker struct {
+	cfg   tensile.Config
+	start time.Time
+	open  []*soakPeriod // Periods results may still arrive for, oldest first
+	log   *os.File
+}
+
+// Checkpoint period
+type soakPeriod struct {
+	n   int
+	st  *tensile.Stats
+	rec *tensile.Recorder
+}
+
+// Start period n
+func (s *soaker) begin(n int) {
+	p := &soakPeriod{n: n, st: tensile.NewBoundedStats()}
+	s.open = append(s.open, p)
+	if recordFile == "" {
+		return
+	}
+	info := tensile.RunInfo{Version: tensile.Version, URL: s.cfg.URL, Requests: s.cfg.Requests, Concurrent: s.cfg.Concurrent, Start: s.start.Add(p.offset()), Tags: s.cfg.Tags, Sample: sample}
+	var err error
+	if p.rec, err = tensile.CreateRecorder(rotatedName(recordFile, n), info); err != nil {
+		slog.Error("rotating recording", "err", err)
+	}
+}
+
+// Offset of the start of the period
+func (p *soakPeriod) offset() time.Duration {
+	return time.Duration(p.n-1) * checkpoint
+}
+
+// End the oldest open period, which lasted d
+func (s *soaker) end(d time.Duration) {
+	p := s.open[0]
+	s.open = s.open[1:]
+	sum := p.st.Summary(s.cfg.URL, d)
+	sum.Histogram = nil
+	infof(checkpointDone, p.n, sum.Requests, sum.Throughput, sum.Percentiles["p99"], sum.ErrorRate*100)
+	if s.log != nil {
+		if err := json.NewEncoder(s.log).Encode(checkpointSummary{p.n, p.offset(), sum}); err != nil {
+			slog.Error("writing checkpoint", "err", err)
+		}
+	}
+	if p.rec != nil {
+		if err := p.rec.Close(); err != nil {
+			slog.Error("closing recording", "err", err)
+		}
+	}
+}
+
+// Add a result to the period it finished in, ending the periods it shows
+// are over. A result arriving after its period ended is added to the oldest
+// one still open.
+func (s *soaker) add(r tensile.Result) {
+	end := r.Start + r.Latency
+	n := int(end/checkpoint) + 1
+	for last := s.open[len(s.open)-1].n; last < n; last++ {
+		s.begin(last + 1)
+	}
+	for len(s.open) > 1 && end >= time.Duration(s.open[0].n)*checkpoint+min(checkpoint, checkpointGrace) {
+		s.end(checkpoint)
+	}
+	p := s.open[0]
+	if n > p.n {
+		p = s.open[n-p.n]
+	} else if n < p.n {
+		slog.Debug("result arrived after its checkpoint ended", "checkpoint", n)
+	}
+	p.st.Add(r)
+	if p.rec != nil {
+		// Offsets in each recording are from the start of its period
+		r.Start -= p.offset()
+		if err := p.rec.Write(r); err != nil {
+			slog.Error("recording result", "err", err)
+		}
+	}
+}
+
+// Run the attack in soak mode, summarising and rotating recordings every
+// -checkpoint with memory use bounded
+func soak(cfg tensile.Config) (tensile.Results, error) {
+	s := &soaker{cfg: cfg, start: time.Now()}
+	if checkpointFile != "" {
+		f, err := os.OpenFile(checkpointFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return tensile.Results{}, err
+		}
+		defer f.Close()
+		s.log = f
+	}
+	cfg.Bounded = true
+	stream := make(chan tensile.Result, opts.concurrent)
+	cfg.Results = stream
+	done := make(chan struct{})
+	s.begin(1)
+	go func() {
+		defer close(done)
+		for r := range stream {
+			s.add(r)
+		}
+	}()
+	res, err := tensile.NewAttacker(tensile.WithConfig(cfg)).Attack(runCtx)
+	<-done
+	for len(s.open) > 1 {
+		s.end(checkpoint)
+	}
+	s.end(time.Since(s.start) - s.open[0].offset())
+	return res, err
+}
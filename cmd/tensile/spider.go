@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	spider       bool
+	spiderDepth  int
+	spiderMax    int
+	spiderRobots bool
+
+	spiderError      = "ERROR: -spider can't be used with -targets, -sitemap or -url (-u) patterns\n"
+	spiderLimitError = "ERROR: -depth must not be negative, and -max-urls must be greater than 0\n"
+	spiderCrawlError = "ERROR: unable to crawl -url (-u): %s\n"
+)
+
+func init() {
+	attackFlags.BoolVar(&spider, "spider", false, "Crawl -url for links to pages of the same origin, and use them as the targets")
+	attackFlags.IntVar(&spiderDepth, "depth", 2, "Links to follow from -url with -spider, at most")
+	attackFlags.IntVar(&spiderMax, "max-urls", 500, "Pages to find with -spider, at most")
+	attackFlags.BoolVar(&spiderRobots, "robots", false, "Only crawl the pages robots.txt allows with -spider")
+}
+
+const (
+	spiderWorkers  = 8 // Pages fetched at once while crawling
+	spiderTimeout  = 30 * time.Second
+	maxSpiderPage  = 10 << 20 // Bytes of a page searched for links, at most
+	maxRobotsBytes = 500 << 10
+)
+
+// Check the -spider flags
+func checkSpider() error {
+	if !spider {
+		return nil
+	}
+	if targetsFile != "" || sitemapURL != "" || len(targets) > 0 {
+		return errors.New(spiderError)
+	}
+	if spiderDepth < 0 || spiderMax < 1 {
+		return errors.New(spiderLimitError)
+	}
+	return nil
+}
+
+// Fetch a page, returning whether it was found and its links, if HTML
+func fetchPage(u string) (bool, []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), spiderTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return false, nil
+	}
+	req.Header.Set("User-Agent", tensile.App+tensile.Version)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		slog.Debug("crawling", "url", u, "err", err)
+		return false, nil
+	}
+	defer resp.Body.Close()
+	slog.Debug("crawled", "url", u, "status", resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		return false, nil
+	}
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt != "text/html" && mt != "application/xhtml+xml" {
+		return true, nil
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxSpiderPage))
+	if err != nil {
+		return true, nil
+	}
+	return true, tensile.Links(page, resp.Request.URL)
+}
+
+// Crawl breadth first from start, up to -depth links away, for pages of the
+// same origin, returning them as targets named for their URLs
+func crawl(start string) ([]tensile.Target, error) {
+	su, err := url.Parse(start)
+	if err != nil {
+		return nil, err
+	}
+	origin := su.Scheme + "://" + su.Host
+	allowed := func(string) bool { return true }
+	if spiderRobots {
+		allowed = loadRobots(origin)
+	}
+	var ts []tensile.Target
+	seen := map[string]bool{start: true}
+	level := []string{start}
+	for depth := 0; len(level) > 0 && len(ts) < spiderMax; depth++ {
+		found := make([]bool, len(level))
+		links := make([][]string, len(level))
+		sem := make(chan struct{}, spiderWorkers)
+		var wg sync.WaitGroup
+		for i, u := range level {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, u string) {
+				defer wg.Done()
+				found[i], links[i] = fetchPage(u)
+				<-sem
+			}(i, u)
+		}
+		wg.Wait()
+		var next []string
+		for i, u := range level {
+			if !found[i] || len(ts) == spiderMax {
+				continue
+			}
+			ts = append(ts, tensile.Target{Name: u, URL: u})
+			if depth == spiderDepth {
+				continue
+			}
+			for _, l := range links[i] {
+				lu, err := url.Parse(l)
+				if err != nil || lu.Scheme+"://"+lu.Host != origin || seen[l] || !allowed(lu.RequestURI()) {
+					continue
+				}
+				seen[l] = true
+				next = append(next, l)
+			}
+		}
+		// Pages beyond those wanted aren't fetched
+		if n := spiderMax - len(ts); len(next) > n {
+			next = next[:n]
+		}
+		level = next
+	}
+	if len(ts) == 0 {
+		return nil, errors.New("no pages found")
+	}
+	return ts, nil
+}
+
+// A robots.txt Allow or Disallow rule
+type robotsRule struct {
+	allow   bool
+	pattern *regexp.Regexp
+	length  int // Of the path, the longest matching rule applying
+}
+
+// Fetch the robots.txt of origin, returning whether it allows a path. Any
+// failure to fetch it allows everything.
+func loadRobots(origin string) func(string) bool {
+	all := func(string) bool { return true }
+	ctx, cancel := context.WithTimeout(context.Background(), spiderTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", origin+"/robots.txt", nil)
+	if err != nil {
+		return all
+	}
+	req.Header.Set("User-Agent", tensile.App+tensile.Version)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		slog.Debug("fetching robots.txt", "err", err)
+		return all
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return all
+	}
+	rules := parseRobots(io.LimitReader(resp.Body, maxRobotsBytes), "tensile")
+	return func(path string) bool {
+		allow, longest := true, -1
+		for _, r := range rules {
+			if r.pattern.MatchString(path) && (r.length > longest || (r.length == longest && r.allow)) {
+				allow, longest = r.allow, r.length
+			}
+		}
+		return allow
+	}
+}
+
+// Rules of the robots.txt group for agent, or for * if there isn't one
+func parseRobots(r io.Reader, agent string) []robotsRule {
+	var mine, star []robotsRule
+	var forMe, forAny, inRules, matched bool
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		k, v = strings.ToLower(strings.TrimSpace(k)), strings.TrimSpace(v)
+		switch k {
+		case "user-agent":
+			if inRules {
+				forMe, forAny, inRules = false, false, false
+			}
+			if v == "*" {
+				forAny = true
+			} else if strings.Contains(strings.ToLower(agent), strings.ToLower(v)) {
+				forMe, matched = true, true
+			}
+		case "allow", "disallow":
+			inRules = true
+			if v == "" {
+				continue
+			}
+			p := regexp.QuoteMeta(v)
+			p = strings.ReplaceAll(p, `\*`, ".*")
+			if strings.HasSuffix(p, `\$`) {
+				p = strings.TrimSuffix(p, `\$`) + "$"
+			}
+			rule := robotsRule{k == "allow", regexp.MustCompile("^" + p), len(v)}
+			if forMe {
+				mine = append(mine, rule)
+			}
+			if forAny {
+				star = append(star, rule)
+			}
+		}
+	}
+	if matched {
+		return mine
+	}
+	return star
+}
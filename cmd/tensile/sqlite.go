@@ -0,0 +1,183 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	sqlitePath string
+
+	// Name of the database/sql SQLite driver, set by sqlite_driver.go when
+	// built with -tags sqlite
+	sqliteDriver string
+
+	sqliteBuildError = "ERROR: -sqlite needs tensile built with -tags sqlite\n"
+	sqliteModeError  = "ERROR: -sqlite is not supported with -sweep, -body-size-sweep, -hosts, -checkpoint, -auto-concurrency or -target-p99\n"
+)
+
+func init() {
+	attackFlags.StringVar(&sqlitePath, "sqlite", "", "Write every result and the run's metadata to an SQLite database, to query with SQL")
+}
+
+// Rows inserted per transaction
+const sqliteBatch = 10000
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id         INTEGER PRIMARY KEY,
+	version    TEXT,
+	url        TEXT,
+	requests   INTEGER,
+	concurrent INTEGER,
+	start      TEXT,
+	tags       TEXT,
+	sample     INTEGER
+);
+CREATE TABLE IF NOT EXISTS results (
+	run           INTEGER REFERENCES runs(id),
+	start_ns      INTEGER,
+	latency_ns    INTEGER,
+	transfer_ns   INTEGER,
+	status        INTEGER,
+	size          INTEGER,
+	decoded       INTEGER,
+	saved         INTEGER,
+	error         TEXT,
+	retries       INTEGER,
+	hedges        INTEGER,
+	target        TEXT,
+	backend       TEXT,
+	method        TEXT,
+	failed_check  TEXT,
+	expect        TEXT,
+	grpc          TEXT,
+	server_timing TEXT,
+	headers       TEXT
+);
+CREATE INDEX IF NOT EXISTS results_run ON results(run);
+`
+
+const sqliteInsert = `INSERT INTO results VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// Writes results to an SQLite database, as rows of a run. Every run written
+// to the same database is kept, as a new row of runs.
+type sqliteWriter struct {
+	db     *sql.DB
+	tx     *sql.Tx
+	ins    *sql.Stmt
+	run    int64
+	n      int // Rows in the current transaction
+	sample int // Insert one in sample results, if above 1
+	seen   int // Results written, inserted or not
+}
+
+// Open or create an SQLite database, and add a run to it
+func createSQLite(path string, info tensile.RunInfo) (*sqliteWriter, error) {
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, err
+	}
+	w := &sqliteWriter{db: db, sample: info.Sample}
+	if err := w.init(info); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *sqliteWriter) init(info tensile.RunInfo) error {
+	if _, err := w.db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	tags, err := jsonText(info.Tags, len(info.Tags))
+	if err != nil {
+		return err
+	}
+	res, err := w.db.Exec(`INSERT INTO runs (version, url, requests, concurrent, start, tags, sample) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		info.Version, info.URL, info.Requests, info.Concurrent, info.Start.Format(time.RFC3339Nano), tags, max(info.Sample, 1))
+	if err != nil {
+		return err
+	}
+	w.run, err = res.LastInsertId()
+	return err
+}
+
+// NULL for an empty string
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// JSON text of m, a map of n entries, or NULL if it's empty
+func jsonText(m any, n int) (any, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+// Write inserts a result, unless it is left out of the sample, committing
+// every sqliteBatch rows
+func (w *sqliteWriter) Write(r tensile.Result) error {
+	w.seen++
+	if w.sample > 1 && (w.seen-1)%w.sample != 0 {
+		return nil
+	}
+	if w.tx == nil {
+		tx, err := w.db.Begin()
+		if err != nil {
+			return err
+		}
+		ins, err := tx.Prepare(sqliteInsert)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		w.tx, w.ins = tx, ins
+	}
+	st, err := jsonText(r.ServerTiming, len(r.ServerTiming))
+	if err != nil {
+		return err
+	}
+	hs, err := jsonText(r.Headers, len(r.Headers))
+	if err != nil {
+		return err
+	}
+	_, err = w.ins.Exec(w.run, int64(r.Start), int64(r.Latency), int64(r.Transfer), r.Status, r.Size, r.Decoded, r.Saved,
+		nullString(r.Err), r.Retries, r.Hedges, nullString(r.Target), nullString(r.Backend), nullString(r.Method),
+		nullString(r.Check), nullString(r.Expect), nullString(r.GRPC), st, hs)
+	if err != nil {
+		return err
+	}
+	if w.n++; w.n == sqliteBatch {
+		return w.commit()
+	}
+	return nil
+}
+
+// Commit the rows inserted so far
+func (w *sqliteWriter) commit() error {
+	if w.tx == nil {
+		return nil
+	}
+	w.ins.Close()
+	err := w.tx.Commit()
+	w.tx, w.ins, w.n = nil, nil, 0
+	return err
+}
+
+// Close commits the last rows and closes the database
+func (w *sqliteWriter) Close() error {
+	err := w.commit()
+	if cerr := w.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	sseURL         string
+	sseConnections int
+	sseDuration    time.Duration
+	sseTimeout     time.Duration
+
+	sseFlags = flag.NewFlagSet("sse", flag.ExitOnError)
+
+	sseFormatError = "ERROR: unsupported -output format %q for sse, expected text or json\n"
+)
+
+func init() {
+	sseFlags.Usage = usageFor(sseFlags, "tensile sse [flags]")
+	sseFlags.StringVar(&sseURL, "url", "http://localhost/", "Target event stream URL")
+	sseFlags.StringVar(&sseURL, "u", "http://localhost/", "Target event stream URL (short flag)")
+	sseFlags.IntVar(&sseConnections, "connections", 10, "Concurrent connections")
+	sseFlags.DurationVar(&sseDuration, "duration", 30*time.Second, "Time to hold the streams open")
+	sseFlags.DurationVar(&sseTimeout, "timeout", 10*time.Second, "Connect timeout")
+	sseFlags.StringVar(&outputFormat, "output", "text", "Report format (text, json)")
+	sseFlags.StringVar(&outputFile, "o", "", "Write the report to a file instead of stdout")
+}
+
+// Server-Sent Events subcommand
+func sseCmd(args []string) {
+	sseFlags.Parse(args)
+	setupLogging()
+	var report func(io.Writer, tensile.SSEResults) error
+	switch outputFormat {
+	case "text":
+		report = sseTextReport
+	case "json":
+		report = func(w io.Writer, res tensile.SSEResults) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "\t")
+			return enc.Encode(res)
+		}
+	default:
+		log.Fatal(fmt.Errorf(sseFormatError, outputFormat))
+	}
+	infof("\n\t%s\n\n", tensile.App+tensile.Version)
+	infof("Target URL:\t%s\nConnections:\t%d\nDuration:\t%s\n", sseURL, sseConnections, sseDuration)
+	infof("\n")
+	cfg := tensile.SSEConfig{
+		URL:         sseURL,
+		Connections: sseConnections,
+		Duration:    sseDuration,
+		Timeout:     sseTimeout,
+	}
+	res, err := tensile.AttackSSE(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := writeOutput(func(w io.Writer) error { return report(w, res) }); err != nil {
+		log.Fatal(fmt.Errorf(reportWriteError, err))
+	}
+}
+
+// Plain text Server-Sent Events report
+func sseTextReport(w io.Writer, res tensile.SSEResults) error {
+	fmt.Fprintf(w, "Connections:\t%d\nConnected:\t%d\nFailed:\t\t%d\nReconnects:\t%d\n", res.Connections, res.Connected, res.Failed, res.Reconnects)
+	fmt.Fprintf(w, "Events:\t\t%d\nTotal time:\t%s\n\n", res.Events, res.Duration)
+	for _, m := range []struct {
+		name string
+		m    tensile.Metric
+	}{{"Connect", res.Connect}, {"Interval", res.Interval}} {
+		fmt.Fprintf(w, "%s mean:\t%s\n", m.name, m.m.Mean)
+		for _, p := range tensile.Percentiles {
+			fmt.Fprintf(w, "%s %s:\t%s\n", m.name, tensile.PercentileName(p), m.m.Percentiles[tensile.PercentileName(p)])
+		}
+		if _, err := fmt.Fprintf(w, "%s max:\t%s\n\n", m.name, m.m.Max); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	stream         bool
+	stallThreshold time.Duration
+
+	stallError = "ERROR: -stall-threshold must be greater than 0\n"
+)
+
+func init() {
+	attackFlags.BoolVar(&stream, "stream", false, "Time the chunks of streaming responses, reporting the time to the first byte of the body, the gaps between chunks and stalls")
+	attackFlags.DurationVar(&stallThreshold, "stall-threshold", tensile.DefaultStallThreshold, "Gap between chunks counted as a stall, with -stream")
+}
+
+// Check -stall-threshold
+func checkStream() error {
+	if stallThreshold <= 0 {
+		return errors.New(stallError)
+	}
+	return nil
+}
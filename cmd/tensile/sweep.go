@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	sweepStr, bodySizeSweep string
+	sweepDuration           time.Duration
+
+	sweepError         = "ERROR: invalid -sweep %q, expected a list like 1,2,4,...,256\n"
+	sweepRecordError   = "ERROR: -record is not supported with -sweep or -body-size-sweep\n"
+	sweepLevelDone     = "Concurrent %d:\t%.2f req/s, p99 %s, %.2f%% errors\n"
+	bodySweepError     = "ERROR: invalid -body-size-sweep %q, expected a list of sizes like 1KB,10KB,100KB,1MB\n"
+	bodySweepModeError = "ERROR: -body-size-sweep can't be used with -sweep, -hosts or -agents\n"
+	bodySweepDone      = "Body size %s:\t%.2f req/s, p99 %s, %.2f%% errors\n"
+)
+
+func init() {
+	attackFlags.StringVar(&sweepStr, "sweep", "", "Run at each of a list of concurrency levels, e.g. 1,2,4,...,256")
+	attackFlags.StringVar(&bodySizeSweep, "body-size-sweep", "", "Run with request bodies of each of a list of sizes, e.g. 1KB,10KB,100KB,1MB")
+	attackFlags.DurationVar(&sweepDuration, "sweep-duration", 30*time.Second, "Duration of each -sweep or -body-size-sweep level, overriding -duration")
+}
+
+// Parse a list of levels. "..." continues the sequence up to the next
+// value, doubling if the two values before it do, otherwise by their
+// difference.
+func parseSweep(s string) ([]int, error) {
+	var levels []int
+	parts := splitList(s)
+	for i, p := range parts {
+		if p != "..." {
+			n, err := strconv.Atoi(p)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf(sweepError, s)
+			}
+			levels = append(levels, n)
+			continue
+		}
+		if len(levels) < 2 || i == len(parts)-1 {
+			return nil, fmt.Errorf(sweepError, s)
+		}
+		end, err := strconv.Atoi(parts[i+1])
+		if err != nil {
+			return nil, fmt.Errorf(sweepError, s)
+		}
+		a, b := levels[len(levels)-2], levels[len(levels)-1]
+		if b <= a {
+			return nil, fmt.Errorf(sweepError, s)
+		}
+		next := func(n int) int { return n + b - a }
+		if b == a*2 {
+			next = func(n int) int { return n * 2 }
+		}
+		for n := next(b); n < end; n = next(n) {
+			levels = append(levels, n)
+		}
+	}
+	return levels, nil
+}
+
+// Whether the attack is a series of runs, written as a table rather than a
+// report
+func tabulated() bool {
+	return sweepStr != "" || bodySizeSweep != "" || hostsStr != ""
+}
+
+// Parse a list of body sizes
+func parseBodySweep(s string) ([]int64, error) {
+	if sweepStr != "" || hostsStr != "" || agentsStr != "" {
+		return nil, errors.New(bodySweepModeError)
+	}
+	var sizes []int64
+	for _, p := range splitList(s) {
+		n, err := parseByteSize(p)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf(bodySweepError, s)
+		}
+		sizes = append(sizes, n)
+	}
+	if len(sizes) == 0 {
+		return nil, fmt.Errorf(bodySweepError, s)
+	}
+	return sizes, nil
+}
+
+// Level of a sweep
+type sweepLevel struct {
+	Concurrent int             `json:"concurrent"`
+	Results    tensile.Results `json:"results"`
+}
+
+// Run the attack at each -sweep level and write a table, or CSV or JSON with
+// -output
+func sweep() {
+	if recordFile != "" {
+		log.Fatal(fmt.Errorf("\n%s", sweepRecordError))
+	}
+	levels, err := parseSweep(sweepStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg := config(opts)
+	var results []sweepLevel
+	for _, c := range levels {
+		cfg.Concurrent = c
+		res, err := tensile.Attack(context.Background(), cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		infof(sweepLevelDone, c, res.Throughput, res.Percentiles["p99"], res.ErrorRate*100)
+		results = append(results, sweepLevel{c, res})
+	}
+	infof("\n")
+	if err := writeOutput(func(w io.Writer) error { return writeSweep(w, results) }); err != nil {
+		log.Fatal(fmt.Errorf(reportWriteError, err))
+	}
+}
+
+// Write sweep results in the -output format
+func writeSweep(w io.Writer, results []sweepLevel) error {
+	switch outputFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(results)
+	}
+	labels := make([]string, len(results))
+	rs := make([]tensile.Results, len(results))
+	for i, l := range results {
+		labels[i], rs[i] = strconv.Itoa(l.Concurrent), l.Results
+	}
+	return writeTable(w, "Concurrent", labels, rs)
+}
+
+// Write a row of results for each label, as a table or, with -output=csv, CSV
+func writeTable(w io.Writer, column string, labels []string, rs []tensile.Results) error {
+	if outputFormat == "csv" {
+		cw := csv.NewWriter(w)
+		cw.Write(append([]string{strings.ReplaceAll(strings.ToLower(column), " ", "_")}, csvHeader()...))
+		for i, r := range rs {
+			cw.Write(append([]string{labels[i]}, csvRow(r)...))
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	head := []string{column, "Throughput", "Errors", "Mean"}
+	for _, p := range tensile.Percentiles {
+		head = append(head, tensile.PercentileName(p))
+	}
+	fmt.Fprintln(tw, strings.Join(append(head, "Max"), "\t"))
+	for i, r := range rs {
+		row := []string{labels[i], fmt.Sprintf("%.2f req/s", r.Throughput), fmt.Sprintf("%.2f%%", r.ErrorRate*100), r.Mean.String()}
+		for _, p := range tensile.Percentiles {
+			row = append(row, r.Percentiles[tensile.PercentileName(p)].String())
+		}
+		fmt.Fprintln(tw, strings.Join(append(row, r.Max.String()), "\t"))
+	}
+	return tw.Flush()
+}
+
+// Body size of a -body-size-sweep
+type bodySizeLevel struct {
+	BodySize int64           `json:"body_size"`
+	Results  tensile.Results `json:"results"`
+}
+
+// Run the attack with each -body-size-sweep size of body and write a table,
+// or CSV or JSON with -output
+func bodySweep() {
+	if recordFile != "" {
+		log.Fatal(fmt.Errorf("\n%s", sweepRecordError))
+	}
+	sizes, err := parseBodySweep(bodySizeSweep)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg := config(opts)
+	var results []bodySizeLevel
+	for _, n := range sizes {
+		cfg.Body = tensile.NewSynthetic(n, bodyRandom)
+		res, err := tensile.Attack(context.Background(), cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		infof(bodySweepDone, byteSize(float64(n)), res.Throughput, res.Percentiles["p99"], res.ErrorRate*100)
+		results = append(results, bodySizeLevel{n, res})
+	}
+	infof("\n")
+	if err := writeOutput(func(w io.Writer) error { return writeBodySweep(w, results) }); err != nil {
+		log.Fatal(fmt.Errorf(reportWriteError, err))
+	}
+}
+
+// Write body size sweep results in the -output format
+func writeBodySweep(w io.Writer, results []bodySizeLevel) error {
+	if outputFormat == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(results)
+	}
+	labels := make([]string, len(results))
+	rs := make([]tensile.Results, len(results))
+	for i, l := range results {
+		labels[i], rs[i] = byteSize(float64(l.BodySize)).String(), l.Results
+		if outputFormat == "csv" {
+			labels[i] = strconv.FormatInt(l.BodySize, 10)
+		}
+	}
+	return writeTable(w, "Body size", labels, rs)
+}
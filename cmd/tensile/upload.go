@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	uploadStr string
+	uploadTo  *bucket // Parsed from -upload
+
+	uploadError      = "ERROR: invalid -upload %q, expected s3://bucket/prefix or gs://bucket/prefix\n"
+	uploadCredsError = "ERROR: -upload to %s needs %s in the environment\n"
+	uploadModeError  = "ERROR: -upload is not supported with -sweep, -body-size-sweep or -hosts\n"
+)
+
+func init() {
+	attackFlags.StringVar(&uploadStr, "upload", "", "After the run, upload the results, report and a JSON summary under a prefix for the run, e.g. s3://bucket/loadtests or gs://bucket/loadtests")
+}
+
+// Time allowed for each upload
+const uploadTimeout = 30 * time.Minute
+
+// An object storage destination, from -upload
+type bucket struct {
+	scheme string // s3 or gs
+	name   string
+	prefix string
+}
+
+// Parse -upload, checking the credentials it needs are set
+func parseUpload() (*bucket, error) {
+	if uploadStr == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(uploadStr)
+	if err != nil || (u.Scheme != "s3" && u.Scheme != "gs") || u.Host == "" {
+		return nil, fmt.Errorf(uploadError, uploadStr)
+	}
+	b := &bucket{scheme: u.Scheme, name: u.Host, prefix: strings.Trim(u.Path, "/")}
+	switch {
+	case b.scheme == "s3" && (os.Getenv("AWS_ACCESS_KEY_ID") == "" || os.Getenv("AWS_SECRET_ACCESS_KEY") == ""):
+		return nil, fmt.Errorf(uploadCredsError, uploadStr, "AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	case b.scheme == "gs" && os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN") == "" && os.Getenv("STORAGE_EMULATOR_HOST") == "":
+		return nil, fmt.Errorf(uploadCredsError, uploadStr, "GOOGLE_OAUTH_ACCESS_TOKEN")
+	}
+	return b, nil
+}
+
+// Files written by the run, to upload
+func runFiles() []string {
+	var fs []string
+	if recordFile != "" {
+		if checkpoint > 0 {
+			for n := 1; ; n++ {
+				name := rotatedName(recordFile, n)
+				if _, err := os.Stat(name); err != nil {
+					break
+				}
+				fs = append(fs, name)
+			}
+		} else {
+			fs = append(fs, recordFile)
+		}
+	}
+	for _, f := range []string{outputFile, sqlitePath, checkpointFile} {
+		if f != "" {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+// Upload the files of the run, and its summary as summary.json, under a
+// prefix named for when it started, logging any that fail
+func upload(b *bucket, start time.Time, res tensile.Results) {
+	dir := path.Join(b.prefix, start.UTC().Format("20060102T150405Z"))
+	summary, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		slog.Error("uploading", "err", err)
+		return
+	}
+	put := func(name string, body io.Reader, size int64) {
+		key := path.Join(dir, name)
+		if err := b.put(key, body, size); err != nil {
+			slog.Error("uploading", "object", b.scheme+"://"+b.name+"/"+key, "err", err)
+			return
+		}
+		infof("Uploaded:\t%s://%s/%s\n", b.scheme, b.name, key)
+	}
+	put("summary.json", bytes.NewReader(summary), int64(len(summary)))
+	for _, name := range runFiles() {
+		f, err := os.Open(name)
+		if err != nil {
+			slog.Error("uploading", "file", name, "err", err)
+			continue
+		}
+		if fi, err := f.Stat(); err != nil {
+			slog.Error("uploading", "file", name, "err", err)
+		} else {
+			put(filepath.Base(name), f, fi.Size())
+		}
+		f.Close()
+	}
+}
+
+// Put an object of size bytes
+func (b *bucket) put(key string, body io.Reader, size int64) error {
+	var req *http.Request
+	var err error
+	if b.scheme == "s3" {
+		req, err = b.s3Request(key, body)
+	} else {
+		req, err = b.gcsRequest(key, body)
+	}
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("User-Agent", tensile.App+tensile.Version)
+	c := http.Client{Timeout: uploadTimeout}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Request to put an object in Google Cloud Storage with a simple upload. The
+// token is an OAuth access token, e.g. from gcloud auth print-access-token.
+// STORAGE_EMULATOR_HOST points it at an emulator instead.
+func (b *bucket) gcsRequest(key string, body io.Reader) (*http.Request, error) {
+	base := "https://storage.googleapis.com"
+	if h := os.Getenv("STORAGE_EMULATOR_HOST"); h != "" {
+		base = strings.TrimSuffix(h, "/")
+		if !strings.Contains(base, "://") {
+			base = "http://" + base
+		}
+	}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", base, url.PathEscape(b.name), url.QueryEscape(key))
+	req, err := http.NewRequest("POST", u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if t := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); t != "" {
+		req.Header.Set("Authorization", "Bearer "+t)
+	}
+	return req, nil
+}
+
+// Request to put an object in S3, signed with the AWS_ environment
+// credentials. AWS_ENDPOINT_URL points it at another S3 compatible store,
+// addressing the bucket by path.
+func (b *bucket) s3Request(key string, body io.Reader) (*http.Request, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	u := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", b.name, region, awsEscape(key))
+	if e := os.Getenv("AWS_ENDPOINT_URL"); e != "" {
+		u = fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(e, "/"), awsEscape(b.name), awsEscape(key))
+	}
+	req, err := http.NewRequest("PUT", u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	// The body isn't hashed, so recordings of any size are streamed
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	if t := os.Getenv("AWS_SESSION_TOKEN"); t != "" {
+		req.Header.Set("X-Amz-Security-Token", t)
+	}
+	signV4(req, os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), region, "s3", time.Now())
+	return req, nil
+}
+
+// URI encode s as AWS signatures expect, leaving slashes
+func awsEscape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', strings.IndexByte("-._~/", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// Sign req with AWS Signature Version 4, over its host and headers. The
+// payload hash is taken from X-Amz-Content-Sha256, which must be set.
+func signV4(req *http.Request, keyID, secret, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate, day := now.Format("20060102T150405Z"), now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	headers := map[string]string{"host": req.URL.Host}
+	for k, vs := range req.Header {
+		if k == "Authorization" || k == "User-Agent" {
+			continue
+		}
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(vs, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	// Query parameters sorted by name, then value, each escaped
+	q := req.URL.Query()
+	params := make([]string, 0, len(q))
+	for k, vs := range q {
+		for _, v := range vs {
+			params = append(params, awsEscape(k)+"="+strings.ReplaceAll(awsEscape(v), "/", "%2F"))
+		}
+	}
+	sort.Strings(params)
+	p := req.URL.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	canon := strings.Join([]string{req.Method, p, strings.Join(params, "&"), canonHeaders.String(), signed, req.Header.Get("X-Amz-Content-Sha256")}, "\n")
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	sum := sha256.Sum256([]byte(canon))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	key := hmacSHA256([]byte("AWS4"+secret), day)
+	for _, s := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", keyID, scope, signed, sig))
+}
+
+// Check -upload can be used with the other flags, and parse it
+func checkUpload() error {
+	if uploadStr != "" && tabulated() {
+		return errors.New(uploadModeError)
+	}
+	var err error
+	uploadTo, err = parseUpload()
+	return err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	userAgentsFile string
+	userAgents     []string
+
+	userAgentsError = "ERROR: unable to load -user-agents: %s\n"
+)
+
+func init() {
+	attackFlags.StringVar(&userAgentsFile, "user-agents", "", "File of User-Agents, one per line, to send with each request in turn, or \"browsers\" for a built-in set of browser User-Agents")
+}
+
+// Load -user-agents
+func parseUserAgents() error {
+	switch userAgentsFile {
+	case "":
+		return nil
+	case "browsers":
+		userAgents = tensile.BrowserUserAgents
+		return nil
+	}
+	f, err := os.Open(userAgentsFile)
+	if err != nil {
+		return fmt.Errorf(userAgentsError, err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if ua := strings.TrimSpace(sc.Text()); ua != "" && !strings.HasPrefix(ua, "#") {
+			userAgents = append(userAgents, ua)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf(userAgentsError, err)
+	}
+	if len(userAgents) == 0 {
+		return fmt.Errorf(userAgentsError, "no User-Agents in "+userAgentsFile)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/intermernet/tensile"
+)
+
+var (
+	wsURL         string
+	wsConnections int
+	wsMessage     string
+	wsRate        float64
+	wsDuration    time.Duration
+	wsTimeout     time.Duration
+
+	wsFlags = flag.NewFlagSet("ws", flag.ExitOnError)
+
+	wsFormatError = "ERROR: unsupported -output format %q for ws, expected text or json\n"
+)
+
+func init() {
+	wsFlags.Usage = usageFor(wsFlags, "tensile ws [flags]")
+	wsFlags.StringVar(&wsURL, "url", "ws://localhost/", "Target WebSocket URL")
+	wsFlags.StringVar(&wsURL, "u", "ws://localhost/", "Target WebSocket URL (short flag)")
+	wsFlags.IntVar(&wsConnections, "connections", 10, "Concurrent connections")
+	wsFlags.StringVar(&wsMessage, "message", "ping", "Message sent on each connection")
+	wsFlags.Float64Var(&wsRate, "rate", 1, "Messages per second per connection, 0 to send each when the last is answered")
+	wsFlags.DurationVar(&wsDuration, "duration", 30*time.Second, "Time to hold the connections open")
+	wsFlags.DurationVar(&wsTimeout, "timeout", 10*time.Second, "Connect timeout")
+	wsFlags.StringVar(&outputFormat, "output", "text", "Report format (text, json)")
+	wsFlags.StringVar(&outputFile, "o", "", "Write the report to a file instead of stdout")
+}
+
+// WebSocket subcommand
+func wsCmd(args []string) {
+	wsFlags.Parse(args)
+	setupLogging()
+	var report func(io.Writer, tensile.WSResults) error
+	switch outputFormat {
+	case "text":
+		report = wsTextReport
+	case "json":
+		report = func(w io.Writer, res tensile.WSResults) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "\t")
+			return enc.Encode(res)
+		}
+	default:
+		log.Fatal(fmt.Errorf(wsFormatError, outputFormat))
+	}
+	infof("\n\t%s\n\n", tensile.App+tensile.Version)
+	infof("Target URL:\t%s\nConnections:\t%d\nDuration:\t%s\n", wsURL, wsConnections, wsDuration)
+	if wsRate > 0 {
+		infof("Rate:\t\t%g/s per connection\n", wsRate)
+	}
+	infof("\n")
+	cfg := tensile.WSConfig{
+		URL:         wsURL,
+		Connections: wsConnections,
+		Message:     []byte(wsMessage),
+		Rate:        wsRate,
+		Duration:    wsDuration,
+		Timeout:     wsTimeout,
+	}
+	res, err := tensile.AttackWS(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := writeOutput(func(w io.Writer) error { return report(w, res) }); err != nil {
+		log.Fatal(fmt.Errorf(reportWriteError, err))
+	}
+}
+
+// Plain text WebSocket report
+func wsTextReport(w io.Writer, res tensile.WSResults) error {
+	fmt.Fprintf(w, "Connected:\t%d of %d\nFailed:\t\t%d\nDropped:\t%d\n", res.Connected, res.Connections, res.Failed, res.Dropped)
+	fmt.Fprintf(w, "Sent:\t\t%d\nReceived:\t%d\nTotal time:\t%s\n\n", res.Sent, res.Received, res.Duration)
+	for _, m := range []struct {
+		name string
+		m    tensile.Metric
+	}{{"Connect", res.Connect}, {"RTT", res.RTT}} {
+		fmt.Fprintf(w, "%s mean:\t%s\n", m.name, m.m.Mean)
+		for _, p := range tensile.Percentiles {
+			fmt.Fprintf(w, "%s %s:\t%s\n", m.name, tensile.PercentileName(p), m.m.Percentiles[tensile.PercentileName(p)])
+		}
+		if _, err := fmt.Fprintf(w, "%s max:\t%s\n\n", m.name, m.m.Max); err != nil {
+			return err
+		}
+	}
+	return nil
+}
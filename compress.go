@@ -0,0 +1,38 @@
+package tensile
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+)
+
+// DefaultMaxDecoded is the default limit on the decompressed size of a body
+const DefaultMaxDecoded = 64 << 20
+
+// Content encodings supported for Config.Compress, and br when built with
+// -tags brotli
+var encodings = map[string]func(io.Reader) (io.ReadCloser, error){
+	"gzip":    func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	"deflate": zlib.NewReader,
+}
+
+// Decodes reports whether encoding is supported for Config.Compress
+func Decodes(encoding string) bool {
+	return encodings[encoding] != nil
+}
+
+// Reader counting the bytes read, and keeping the first error other than EOF
+type countReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if err != nil && err != io.EOF && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
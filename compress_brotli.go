@@ -0,0 +1,13 @@
+//go:build brotli
+
+package tensile
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+func init() {
+	encodings["br"] = func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(brotli.NewReader(r)), nil }
+}
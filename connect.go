@@ -0,0 +1,272 @@
+package tensile
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ErrAddress is returned by AttackConnect for an address that isn't host:port
+var ErrAddress = errors.New("tensile: Address must be host:port")
+
+// Classes of connection errors, counted in ConnectResults.Errors
+const (
+	ConnRefused = "refused"
+	ConnTimeout = "timeout"
+	ConnReset   = "reset"
+	ConnTLS     = "tls"
+	ConnOther   = "other"
+)
+
+// Longest wait for a session ticket after a TLS 1.3 handshake
+const ticketWait = 250 * time.Millisecond
+
+// ConnectConfig of a TCP connect attack
+type ConnectConfig struct {
+	Address    string // host:port
+	Requests   int    // Total connections, or 0 for no limit with a Duration
+	Concurrent int    // Maximum concurrent connection attempts
+	Rate       float64
+	Duration   time.Duration
+	Timeout    time.Duration // Connect timeout, or 10 seconds if 0
+	Logger     *slog.Logger
+
+	// If set, a full TLS handshake is made on each connection before it is
+	// closed, resuming the session of an earlier handshake if Resume is set
+	TLS    *tls.Config
+	Resume bool
+}
+
+// ConnectResults of a TCP connect attack
+type ConnectResults struct {
+	Address    string           `json:"address"`
+	Requests   int64            `json:"requests"` // Connection attempts
+	Connected  int64            `json:"connected"`
+	Failed     int64            `json:"failed"`
+	Errors     map[string]int64 `json:"errors,omitempty"` // Failures by class, e.g. ConnRefused
+	Duration   time.Duration    `json:"duration_ns"`
+	Throughput float64          `json:"throughput"` // Connections, or handshakes, per second
+	Connect    Metric           `json:"connect"`    // Time to connect
+	Handshake  *Metric          `json:"handshake,omitempty"`
+	Resumed    int64            `json:"resumed,omitempty"` // Handshakes resuming a session
+}
+
+// State of a TCP connect attack
+type connectAttack struct {
+	cfg ConnectConfig
+	log *slog.Logger
+
+	tls     *tls.Config
+	tickets *ticketCache
+
+	mu        sync.Mutex
+	res       ConnectResults
+	connect   metricStats
+	handshake metricStats
+	prevErr   string
+}
+
+// Session cache counting the tickets stored
+type ticketCache struct {
+	tls.ClientSessionCache
+	n atomic.Int64
+}
+
+func (c *ticketCache) Put(key string, cs *tls.ClientSessionState) {
+	if cs != nil {
+		c.n.Add(1)
+	}
+	c.ClientSessionCache.Put(key, cs)
+}
+
+// AttackConnect opens TCP connections to cfg.Address and closes them as soon
+// as they are established, measuring connection rate and latency without any
+// application protocol. If cfg.TLS is set, connections are closed after a TLS
+// handshake instead.
+func AttackConnect(ctx context.Context, cfg ConnectConfig) (ConnectResults, error) {
+	switch {
+	case cfg.Requests < 0 || (cfg.Requests == 0 && cfg.Duration <= 0):
+		return ConnectResults{}, ErrRequests
+	case cfg.Concurrent <= 0:
+		return ConnectResults{}, ErrConcurrent
+	case cfg.Rate < 0:
+		return ConnectResults{}, ErrRate
+	}
+	if _, _, err := net.SplitHostPort(cfg.Address); err != nil {
+		return ConnectResults{}, ErrAddress
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	a := &connectAttack{
+		cfg:     cfg,
+		log:     cfg.Logger,
+		res:     ConnectResults{Address: cfg.Address},
+		connect: metricStats{hist: NewHistogram()},
+	}
+	if a.log == nil {
+		a.log = slog.Default()
+	}
+	if cfg.TLS != nil {
+		a.tls = cfg.TLS.Clone()
+		if a.tls.ServerName == "" {
+			a.tls.ServerName, _, _ = net.SplitHostPort(cfg.Address)
+		}
+		a.tls.ClientSessionCache = nil
+		if cfg.Resume {
+			a.tickets = &ticketCache{ClientSessionCache: tls.NewLRUClientSessionCache(1)}
+			a.tls.ClientSessionCache = a.tickets
+		} else {
+			a.tls.SessionTicketsDisabled = true
+		}
+		a.handshake = metricStats{hist: NewHistogram()}
+	}
+	start := time.Now()
+	tokens := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Concurrent; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range tokens {
+				a.dial(ctx)
+			}
+		}()
+	}
+	a.dispatch(ctx, tokens)
+	wg.Wait()
+	a.res.Duration = time.Since(start)
+	if a.res.Duration > 0 {
+		a.res.Throughput = float64(a.res.Connected) / a.res.Duration.Seconds()
+	}
+	a.res.Connect = a.connect.summary()
+	a.res.Connect.Histogram = nil
+	if a.tls != nil {
+		h := a.handshake.summary()
+		h.Histogram = nil
+		a.res.Handshake = &h
+	}
+	return a.res, ctx.Err()
+}
+
+// Send a token for each connection, at cfg.Rate if set, until cfg.Requests
+// or cfg.Duration
+func (a *connectAttack) dispatch(ctx context.Context, tokens chan<- struct{}) {
+	defer close(tokens)
+	var deadline <-chan time.Time
+	if a.cfg.Duration > 0 {
+		t := time.NewTimer(a.cfg.Duration)
+		defer t.Stop()
+		deadline = t.C
+	}
+	next := time.Now()
+	for i := 0; a.cfg.Requests == 0 || i < a.cfg.Requests; i++ {
+		if a.cfg.Rate > 0 {
+			d := time.Until(next)
+			if -d > maxLag {
+				next = time.Now()
+			}
+			if !wait(ctx, deadline, d) {
+				return
+			}
+			next = next.Add(time.Duration(float64(time.Second) / a.cfg.Rate))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case tokens <- struct{}{}:
+		}
+	}
+}
+
+// Open and close a connection, after a TLS handshake if cfg.TLS is set
+func (a *connectAttack) dial(ctx context.Context) {
+	d := net.Dialer{Timeout: a.cfg.Timeout}
+	start := time.Now()
+	c, err := d.DialContext(ctx, "tcp", a.cfg.Address)
+	lat := time.Since(start)
+	var hs time.Duration
+	resumed := false
+	if err == nil && a.tls != nil {
+		hctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+		tc := tls.Client(c, a.tls)
+		start = time.Now()
+		if err = tc.HandshakeContext(hctx); err == nil {
+			hs = time.Since(start)
+			resumed = tc.ConnectionState().DidResume
+			if a.tickets != nil && a.tickets.n.Load() == 0 && tc.ConnectionState().Version >= tls.VersionTLS13 {
+				// TLS 1.3 session tickets are sent after the handshake
+				tc.SetReadDeadline(time.Now().Add(ticketWait))
+				tc.Read(make([]byte, 1))
+			}
+		} else {
+			err = &tlsError{err}
+		}
+		cancel()
+		c = tc
+	}
+	if c != nil {
+		c.Close()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil && ctx.Err() != nil {
+		// Cancelled, not a failure of the target
+		return
+	}
+	a.res.Requests++
+	if err == nil {
+		a.res.Connected++
+		a.connect.add(lat)
+		if a.tls != nil {
+			a.handshake.add(hs)
+			if resumed {
+				a.res.Resumed++
+			}
+		}
+		return
+	}
+	a.res.Failed++
+	class := connClass(err)
+	if a.res.Errors == nil {
+		a.res.Errors = make(map[string]int64)
+	}
+	a.res.Errors[class]++
+	if class != a.prevErr {
+		a.log.Error("connect failed", "err", err)
+	} else {
+		a.log.Debug("connect failed", "err", err)
+	}
+	a.prevErr = class
+}
+
+// Error of a TLS handshake
+type tlsError struct{ err error }
+
+func (e *tlsError) Error() string { return "tls handshake: " + e.err.Error() }
+func (e *tlsError) Unwrap() error { return e.err }
+
+// Class of a connection error
+func connClass(err error) string {
+	var ne net.Error
+	var te *tlsError
+	switch {
+	case errors.As(err, &te) && !errors.Is(err, syscall.ECONNRESET) && !(errors.As(err, &ne) && ne.Timeout()):
+		return ConnTLS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ConnRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return ConnReset
+	case errors.As(err, &ne) && ne.Timeout():
+		return ConnTimeout
+	}
+	return ConnOther
+}
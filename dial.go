@@ -0,0 +1,273 @@
+package tensile
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Most local ports tried for a connection before giving up, when some in
+// the range are in use
+const portTries = 8
+
+// Local addresses used in turn, so each has its own range of ephemeral ports
+type rotation struct {
+	ips  []net.IP
+	next atomic.Uint64
+}
+
+func (r *rotation) ip() net.IP {
+	return r.ips[(r.next.Add(1)-1)%uint64(len(r.ips))]
+}
+
+// Dialer of connections with the local address and socket options of a
+// config
+type dialer struct {
+	cfg    *Config
+	all    rotation
+	v4, v6 rotation
+	port   atomic.Uint64 // Next local port, as an offset into the range
+
+	conns4, conns6 atomic.Int64 // Connections made, by address family
+
+	resolver *net.Resolver
+
+	mu       sync.Mutex
+	resolved map[string][]net.IP // With DNSOnce, addresses by network and host
+	spread   map[string]int      // With SpreadAddrs, connections by network and host
+	lookups  metricStats         // Otherwise, DNS lookup times
+}
+
+// Dialer of the transports
+func (a *attack) dialer() *dialer {
+	c := &a.cfg
+	d := &dialer{cfg: c, resolver: net.DefaultResolver, resolved: make(map[string][]net.IP), spread: make(map[string]int), lookups: metricStats{hist: NewHistogram()}}
+	if c.DNSServer != "" {
+		d.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var nd net.Dialer
+				return nd.DialContext(ctx, network, c.DNSServer)
+			},
+		}
+	}
+	for _, ip := range c.LocalAddrs {
+		d.all.ips = append(d.all.ips, ip)
+		if ip.To4() != nil {
+			d.v4.ips = append(d.v4.ips, ip)
+		} else {
+			d.v6.ips = append(d.v6.ips, ip)
+		}
+	}
+	return d
+}
+
+// Connections made
+func (d *dialer) opened() int64 {
+	return d.conns4.Load() + d.conns6.Load()
+}
+
+// Connections made, by network, if any
+func (d *dialer) conns() map[string]int64 {
+	m := make(map[string]int64)
+	if n := d.conns4.Load(); n > 0 {
+		m["tcp4"] = n
+	}
+	if n := d.conns6.Load(); n > 0 {
+		m["tcp6"] = n
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// DNS lookup times, if hosts were resolved for every connection
+func (d *dialer) dns() *Metric {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lookups.hist.Total() == 0 {
+		return nil
+	}
+	m := d.lookups.summary()
+	return &m
+}
+
+// Local IP address for a connection to ip, or nil for any
+func (d *dialer) localIP(ip net.IP) net.IP {
+	if len(d.all.ips) == 0 {
+		return nil
+	}
+	// Preferably from a local address of the same family
+	switch {
+	case ip.To4() != nil && len(d.v4.ips) > 0:
+		return d.v4.ip()
+	case ip.To4() == nil && len(d.v6.ips) > 0:
+		return d.v6.ip()
+	}
+	return d.all.ip()
+}
+
+// Addresses of host on network, from Hosts or looked up once with DNSOnce, or
+// for every connection otherwise
+func (d *dialer) resolve(ctx context.Context, network, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if ips, ok := d.cfg.Hosts[strings.ToLower(host)]; ok {
+		return hostsIPs(network, host, ips)
+	}
+	key := network + " " + host
+	if d.cfg.DNSOnce {
+		d.mu.Lock()
+		ips, ok := d.resolved[key]
+		d.mu.Unlock()
+		if ok {
+			return ips, nil
+		}
+	}
+	ipNet := "ip"
+	switch network {
+	case "tcp4":
+		ipNet = "ip4"
+	case "tcp6":
+		ipNet = "ip6"
+	}
+	start := time.Now()
+	ips, err := d.resolver.LookupIP(ctx, ipNet, host)
+	took := time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	if d.cfg.DNSOnce {
+		d.resolved[key] = ips
+	} else {
+		d.lookups.add(took)
+	}
+	d.mu.Unlock()
+	return ips, nil
+}
+
+// The addresses of a Hosts entry on network
+func hostsIPs(network, host string, ips []net.IP) ([]net.IP, error) {
+	var l []net.IP
+	for _, ip := range ips {
+		if network == "tcp" || (network == "tcp4") == (ip.To4() != nil) {
+			l = append(l, ip)
+		}
+	}
+	if len(l) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	return l, nil
+}
+
+func (d *dialer) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.cfg.Network != "" {
+		network = d.cfg.Network
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := d.resolve(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+	if d.cfg.SpreadAddrs && len(ips) > 1 {
+		ips = d.rotate(network, host, ips)
+	}
+	// Each address in turn, until one connects
+	var c net.Conn
+	for _, ip := range ips {
+		if c, err = d.dialIP(ctx, network, ip, port); err == nil {
+			return c, nil
+		}
+	}
+	return nil, err
+}
+
+// The addresses of host, starting from the next in turn
+func (d *dialer) rotate(network, host string, ips []net.IP) []net.IP {
+	key := network + " " + host
+	d.mu.Lock()
+	i := d.spread[key] % len(ips)
+	d.spread[key]++
+	d.mu.Unlock()
+	rot := make([]net.IP, 0, len(ips))
+	return append(append(rot, ips[i:]...), ips[:i]...)
+}
+
+// Dial ip, retrying with other local ports if the one tried is in use
+func (d *dialer) dialIP(ctx context.Context, network string, ip net.IP, port string) (net.Conn, error) {
+	addr := net.JoinHostPort(ip.String(), port)
+	local := &net.TCPAddr{IP: d.localIP(ip)}
+	var c net.Conn
+	var err error
+	for try := 0; try < portTries; try++ {
+		if d.cfg.PortMin > 0 {
+			n := uint64(d.cfg.PortMax - d.cfg.PortMin + 1)
+			local.Port = d.cfg.PortMin + int((d.port.Add(1)-1)%n)
+		}
+		nd := net.Dialer{LocalAddr: local}
+		if local.IP == nil && local.Port == 0 {
+			nd.LocalAddr = nil
+		}
+		c, err = nd.DialContext(ctx, network, addr)
+		if err == nil || d.cfg.PortMin == 0 || !(errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.EADDRNOTAVAIL)) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	tc, ok := c.(*net.TCPConn)
+	if !ok {
+		return c, nil
+	}
+	if ra, ok := tc.RemoteAddr().(*net.TCPAddr); ok && ra.IP.To4() == nil {
+		d.conns6.Add(1)
+	} else {
+		d.conns4.Add(1)
+	}
+	if err := d.setOptions(tc); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Set the socket options of a connection
+func (d *dialer) setOptions(c *net.TCPConn) error {
+	if d.cfg.Nagle {
+		if err := c.SetNoDelay(false); err != nil {
+			return err
+		}
+	}
+	switch {
+	case d.cfg.Linger < 0:
+		if err := c.SetLinger(0); err != nil {
+			return err
+		}
+	case d.cfg.Linger > 0:
+		// In whole seconds, rounded up
+		if err := c.SetLinger(int((d.cfg.Linger + time.Second - 1) / time.Second)); err != nil {
+			return err
+		}
+	}
+	if d.cfg.SendBuffer > 0 {
+		if err := c.SetWriteBuffer(d.cfg.SendBuffer); err != nil {
+			return err
+		}
+	}
+	if d.cfg.RecvBuffer > 0 {
+		return c.SetReadBuffer(d.cfg.RecvBuffer)
+	}
+	return nil
+}
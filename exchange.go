@@ -0,0 +1,86 @@
+package tensile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+)
+
+// Write an exchange to w, including the bodies if body is set. If err is set
+// the response, if any, has failed a check and its body has been read, so only
+// its headers are written.
+func writeExchange(w io.Writer, req *http.Request, resp *http.Response, err error, body bool) error {
+	if body && req.GetBody != nil {
+		// The body has been sent, so dump a new copy
+		if rc, gerr := req.GetBody(); gerr == nil {
+			req = req.Clone(req.Context())
+			req.Body = rc
+		}
+	}
+	if b, derr := httputil.DumpRequestOut(req, body); derr == nil {
+		fmt.Fprintf(w, "%s\n\n", bytes.TrimSpace(b))
+	}
+	if err != nil {
+		if resp != nil {
+			if b, derr := httputil.DumpResponse(resp, false); derr == nil {
+				fmt.Fprintf(w, "%s\n\n", bytes.TrimSpace(b))
+			}
+		}
+		_, werr := fmt.Fprintf(w, "Error: %s\n\n", err)
+		return werr
+	}
+	b, err := httputil.DumpResponse(resp, body)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n\n", bytes.TrimSpace(b))
+	return err
+}
+
+// Dump an exchange if fewer than cfg.Debug have been dumped
+func (a *attack) dump(req *http.Request, resp *http.Response, err error) {
+	a.dumpMu.Lock()
+	defer a.dumpMu.Unlock()
+	if a.dumped >= a.cfg.Debug {
+		return
+	}
+	a.dumped++
+	w := a.cfg.DebugOutput
+	if w == nil {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, "--- Exchange %d ---\n", a.dumped)
+	if werr := writeExchange(w, req, resp, err, a.cfg.DebugBodies); werr != nil {
+		a.log.Warn("dumping exchange", "err", werr)
+	}
+}
+
+// Save a failed exchange to the cfg.SaveErrors directory if fewer than
+// cfg.SaveErrorsMax have been saved
+func (a *attack) saveError(req *http.Request, resp *http.Response, err error) {
+	a.dumpMu.Lock()
+	defer a.dumpMu.Unlock()
+	if a.saved >= a.cfg.SaveErrorsMax {
+		return
+	}
+	a.saved++
+	path := filepath.Join(a.cfg.SaveErrors, fmt.Sprintf("error-%04d.txt", a.saved))
+	f, ferr := os.Create(path)
+	if ferr != nil {
+		a.log.Error("saving failed exchange", "err", ferr)
+		return
+	}
+	werr := writeExchange(f, req, resp, err, true)
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		a.log.Error("saving failed exchange", "path", path, "err", werr)
+		return
+	}
+	a.log.Debug("saved failed exchange", "path", path)
+}
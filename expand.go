@@ -0,0 +1,138 @@
+package tensile
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Ranges in brackets, e.g. [1-100], [001-100], [a-z] or [0-100:10]
+var (
+	numRange   = regexp.MustCompile(`^([0-9]+)-([0-9]+)(?::([0-9]+))?$`)
+	alphaRange = regexp.MustCompile(`^([a-zA-Z])-([a-zA-Z])(?::([0-9]+))?$`)
+)
+
+// ExpandURL expands the patterns in a URL as curl does, returning every URL
+// it describes, or an error if there are more than limit. A list in braces,
+// like {red,green,blue}, is replaced by each of its items, and a range in
+// brackets, like [1-100] or [a-z], by each of its values. Numbers are
+// padded with zeros to the width of the first, as in [001-100], and a
+// range may have a step, as in [0-100:10]. Brackets that aren't a range, like
+// those of an IPv6 host, are left alone, and a backslash escapes a bracket or
+// brace. The leftmost pattern varies slowest.
+func ExpandURL(pattern string, limit int) ([]string, error) {
+	var parts [][]string
+	var lit strings.Builder
+	total := 1
+	add := func(set []string) error {
+		parts = append(parts, []string{lit.String()}, set)
+		lit.Reset()
+		if total *= len(set); total > limit {
+			return fmt.Errorf("tensile: %q expands to more than %d URLs", pattern, limit)
+		}
+		return nil
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern) && strings.IndexByte("[]{}", pattern[i+1]) >= 0:
+			i++
+			lit.WriteByte(pattern[i])
+		case c == '{':
+			end := strings.IndexByte(pattern[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("tensile: unmatched { in %q", pattern)
+			}
+			if err := add(strings.Split(pattern[i+1:i+end], ",")); err != nil {
+				return nil, err
+			}
+			i += end
+		case c == '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("tensile: unmatched [ in %q", pattern)
+			}
+			set, ok, err := expandRange(pattern[i+1:i+end], limit)
+			if err != nil {
+				return nil, fmt.Errorf("tensile: %s in %q", err, pattern)
+			}
+			if !ok {
+				lit.WriteString(pattern[i : i+end+1])
+			} else if err := add(set); err != nil {
+				return nil, err
+			}
+			i += end
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	parts = append(parts, []string{lit.String()})
+	urls := make([]string, 0, total)
+	var expand func(prefix string, parts [][]string)
+	expand = func(prefix string, parts [][]string) {
+		if len(parts) == 0 {
+			urls = append(urls, prefix)
+			return
+		}
+		for _, s := range parts[0] {
+			expand(prefix+s, parts[1:])
+		}
+	}
+	expand("", parts)
+	return urls, nil
+}
+
+// Values of a range in brackets, or false if it isn't one
+func expandRange(s string, limit int) ([]string, bool, error) {
+	step := 1
+	stepOf := func(m []string) error {
+		if m[3] == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(m[3])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid step in [%s]", s)
+		}
+		step = n
+		return nil
+	}
+	if m := alphaRange.FindStringSubmatch(s); m != nil {
+		if err := stepOf(m); err != nil {
+			return nil, true, err
+		}
+		from, to := m[1][0], m[2][0]
+		if from > to || (from <= 'Z') != (to <= 'Z') {
+			return nil, true, fmt.Errorf("invalid range [%s]", s)
+		}
+		var set []string
+		for c := int(from); c <= int(to); c += step {
+			set = append(set, string(rune(c)))
+		}
+		return set, true, nil
+	}
+	m := numRange.FindStringSubmatch(s)
+	if m == nil {
+		return nil, false, nil
+	}
+	if err := stepOf(m); err != nil {
+		return nil, true, err
+	}
+	from, err1 := strconv.Atoi(m[1])
+	to, err2 := strconv.Atoi(m[2])
+	if err1 != nil || err2 != nil || from > to {
+		return nil, true, fmt.Errorf("invalid range [%s]", s)
+	}
+	if (to-from)/step >= limit {
+		return nil, true, fmt.Errorf("range [%s] has more than %d values", s, limit)
+	}
+	width := 0
+	if len(m[1]) > 1 && m[1][0] == '0' {
+		width = len(m[1])
+	}
+	set := make([]string, 0, (to-from)/step+1)
+	for n := from; n <= to; n += step {
+		set = append(set, fmt.Sprintf("%0*d", width, n))
+	}
+	return set, true, nil
+}
@@ -0,0 +1,52 @@
+package tensile
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+)
+
+// Outcomes of requests sent with Expect: 100-continue, counted in
+// Results.Expect
+const (
+	ExpectContinued = "continued" // The server sent 100 Continue
+	ExpectRejected  = "rejected"  // The server answered before the body was sent
+	ExpectTimedOut  = "timeout"   // The body was sent after cfg.ExpectContinue without a 100 Continue
+)
+
+// Trace of a request sent with Expect: 100-continue
+type expectTrace struct {
+	continued, sent atomic.Bool
+}
+
+// Send req with Expect: 100-continue, tracing whether a 100 Continue arrives
+// and the body is sent
+func (e *expectTrace) trace(req *http.Request) *http.Request {
+	req.Header.Set("Expect", "100-continue")
+	req.Body = &sentReader{req.Body, &e.sent}
+	ct := &httptrace.ClientTrace{Got100Continue: func() { e.continued.Store(true) }}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), ct))
+}
+
+// Outcome of the request
+func (e *expectTrace) outcome() string {
+	switch {
+	case e.continued.Load():
+		return ExpectContinued
+	case e.sent.Load():
+		return ExpectTimedOut
+	}
+	return ExpectRejected
+}
+
+// Body recording whether it has been read
+type sentReader struct {
+	io.ReadCloser
+	sent *atomic.Bool
+}
+
+func (s *sentReader) Read(p []byte) (int, error) {
+	s.sent.Store(true)
+	return s.ReadCloser.Read(p)
+}
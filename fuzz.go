@@ -0,0 +1,91 @@
+package tensile
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultFuzzMaxLen is the longest fuzzed value, in bytes, when
+// Config.FuzzMaxLen is 0
+const DefaultFuzzMaxLen = 8192
+
+// Characters special to parsers, shells, SQL, HTML and URLs
+const fuzzSpecial = `'"<>&;|$%{}[]()\/*?!#@=+,:~^` + "`"
+
+// Printable ASCII, which may be sent in headers as is
+const fuzzPrintable = " !\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~"
+
+// Strings often mishandled
+var fuzzStrings = []string{
+	"", "0", "-1", "18446744073709551616", "1e309", "NaN", "null", "true", "[]", "{}",
+	"' OR '1'='1", "<script>alert(1)</script>", "../../../../etc/passwd", "%s%s%s%n", "${jndi:ldap://x}",
+}
+
+// A random string of n bytes from chars
+func fuzzFrom(rng *rand.Rand, chars string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = chars[rng.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+// A random value of up to limit bytes: long, of special characters, percent
+// encoded, doubly encoded, non-ASCII or well known to break parsers. Values
+// for headers are printable ASCII, as Go won't send others.
+func fuzzValue(rng *rand.Rand, limit int, header bool) string {
+	n := rng.Intn(limit + 1)
+	var s string
+	switch rng.Intn(6) {
+	case 0:
+		s = strings.Repeat("A", n)
+	case 1:
+		s = fuzzFrom(rng, fuzzSpecial, n)
+	case 2:
+		s = url.QueryEscape(fuzzFrom(rng, fuzzPrintable, n/3))
+	case 3:
+		s = url.QueryEscape(url.QueryEscape(fuzzFrom(rng, fuzzSpecial, n/5)))
+	case 4:
+		r := []rune("\u00e9\u4e2d\U0001d11e\u202e\ufeff\u0000 ")
+		var b strings.Builder
+		for b.Len() < n {
+			b.WriteRune(r[rng.Intn(len(r))])
+		}
+		s = b.String()
+	default:
+		s = fuzzStrings[rng.Intn(len(fuzzStrings))]
+	}
+	if len(s) > limit {
+		s = s[:limit]
+	}
+	if header {
+		s = strings.Map(func(r rune) rune {
+			if r < ' ' || r > '~' {
+				return '?'
+			}
+			return r
+		}, s)
+	}
+	return s
+}
+
+// Set each of cfg.FuzzHeaders and cfg.FuzzParams of req to a random value
+func (a *attack) fuzz(req *http.Request) {
+	limit := a.cfg.FuzzMaxLen
+	if limit == 0 {
+		limit = DefaultFuzzMaxLen
+	}
+	for _, h := range a.cfg.FuzzHeaders {
+		req.Header[http.CanonicalHeaderKey(h)] = []string{fuzzValue(a.rng, limit, true)}
+	}
+	if len(a.cfg.FuzzParams) == 0 {
+		return
+	}
+	q := req.URL.Query()
+	for _, p := range a.cfg.FuzzParams {
+		q.Set(p, fuzzValue(a.rng, limit, false))
+	}
+	req.URL.RawQuery = q.Encode()
+}
@@ -0,0 +1,29 @@
+package tensile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFuzz(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, fuzzed := r.Header["X-Fuzz"]
+		if !fuzzed || len(r.Header.Get("X-Fuzz")) > 16 || !r.URL.Query().Has("q") {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	a := NewAttacker(WithURL(srv.URL), WithRequests(20), WithConcurrency(2), WithFuzz([]string{"X-Fuzz"}, []string{"q"}, 16))
+	if c := a.Config(); len(c.FuzzHeaders) != 1 || len(c.FuzzParams) != 1 || c.FuzzMaxLen != 16 {
+		t.Fatalf("WithFuzz set %v, %v, %d", c.FuzzHeaders, c.FuzzParams, c.FuzzMaxLen)
+	}
+	res, err := a.Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Errors != 0 {
+		t.Fatalf("%d requests without the fuzzed header and parameter, checks %v", res.Errors, res.Checks)
+	}
+}
@@ -0,0 +1,61 @@
+package tensile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Largest GraphQL response body searched for errors
+const graphQLMaxBody = 16 << 20
+
+// GraphQLBody returns the standard JSON POST Body of a GraphQL query, with
+// variables, if not empty, which must be a JSON object
+func GraphQLBody(query string, variables []byte) (Bytes, error) {
+	req := struct {
+		Query     string          `json:"query"`
+		Variables json.RawMessage `json:"variables,omitempty"`
+	}{Query: query}
+	if len(bytes.TrimSpace(variables)) > 0 {
+		var v map[string]json.RawMessage
+		if err := json.Unmarshal(variables, &v); err != nil {
+			return Bytes{}, ErrJSON
+		}
+		req.Variables = variables
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return Bytes{}, err
+	}
+	return Bytes{b, "application/json"}, nil
+}
+
+// Check a GraphQL response body for errors, which GraphQL servers usually
+// return with a 200 status
+func checkGraphQL(body []byte) error {
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("invalid GraphQL response: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("%d GraphQL errors, the first: %s", len(resp.Errors), resp.Errors[0].Message)
+	}
+	return nil
+}
+
+// Writer to a buffer of up to n bytes, discarding the rest
+type limitWriter struct {
+	b *bytes.Buffer
+	n int
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if room := l.n - l.b.Len(); room > 0 {
+		l.b.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
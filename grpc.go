@@ -0,0 +1,65 @@
+package tensile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// gRPC status names, by code
+var grpcCodes = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// Name of a gRPC status code
+func grpcCodeName(code int) string {
+	if code >= 0 && code < len(grpcCodes) {
+		return grpcCodes[code]
+	}
+	return strconv.Itoa(code)
+}
+
+// GRPCMessage returns a request Body of a gRPC call, the encoded protobuf
+// message msg in an uncompressed gRPC frame
+func GRPCMessage(msg []byte) Body {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return Bytes{Data: append(frame, msg...), Type: "application/grpc"}
+}
+
+// Record the gRPC status of a successful response, failing the grpc check
+// unless it is OK. The status is in the trailers, or the headers of a
+// response without a body, so the body must have been read.
+func grpcStatus(r *response) {
+	s := r.Trailer.Get("Grpc-Status")
+	msg := r.Trailer.Get("Grpc-Message")
+	if s == "" {
+		s, msg = r.Header.Get("Grpc-Status"), r.Header.Get("Grpc-Message")
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		r.fail(CheckGRPC, fmt.Errorf("missing or invalid grpc-status %q", s))
+		return
+	}
+	r.grpc = grpcCodeName(code)
+	if code != 0 {
+		if m, err := url.PathUnescape(msg); err == nil {
+			msg = m
+		}
+		r.fail(CheckGRPC, fmt.Errorf("gRPC status %s: %s", r.grpc, msg))
+	}
+}
+
+// Transport protocols of gRPC calls, HTTP/2 only, and over cleartext for
+// http URLs
+func grpcProtocols() *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(true)
+	return p
+}
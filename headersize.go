@@ -0,0 +1,22 @@
+package tensile
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Bytes of each padding header line, at most, as servers commonly allow
+// header lines of 8KB
+const maxPadLine = 4096
+
+// Add X-Tensile-Pad-N headers to h whose lines, with their CRLFs, total size
+// bytes, give or take a few
+func padHeader(h http.Header, size int) {
+	for i := 1; size > 0; i++ {
+		name := "X-Tensile-Pad-" + strconv.Itoa(i)
+		n := max(min(size, maxPadLine)-len(name)-len(": \r\n"), 1)
+		h.Set(name, strings.Repeat("x", n))
+		size -= len(name) + len(": \r\n") + n
+	}
+}
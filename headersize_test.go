@@ -0,0 +1,36 @@
+package tensile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHeaderSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := 0
+		for k, vs := range r.Header {
+			if strings.HasPrefix(k, "X-Tensile-Pad-") {
+				size += len(k) + len(": \r\n") + len(vs[0])
+			}
+		}
+		// Padding may be a few bytes over
+		if size < 2048 || size > 2048+16 {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	a := NewAttacker(WithURL(srv.URL), WithRequests(4), WithConcurrency(1), WithHeaderSize(2048))
+	if n := a.Config().HeaderSize; n != 2048 {
+		t.Fatalf("WithHeaderSize set %d", n)
+	}
+	res, err := a.Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Errors != 0 {
+		t.Fatalf("%d requests not padded to 2048 bytes", res.Errors)
+	}
+}
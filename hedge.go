@@ -0,0 +1,91 @@
+package tensile
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Response body that cancels its request's context when closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// Reply to one of a pair of hedged requests
+type hedgeReply struct {
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+// Send a request into r. With cfg.Hedge set, a duplicate is sent if the
+// request hasn't answered within cfg.Hedge, and the first successful reply
+// is used, cancelling the other.
+func (a *attack) send(t http.RoundTripper, req *http.Request, r *response) {
+	if a.cfg.Hedge <= 0 {
+		r.Response, r.err = t.RoundTrip(req)
+		return
+	}
+	replies := make(chan hedgeReply, 2)
+	try := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		hreq := req.Clone(ctx)
+		if req.GetBody != nil {
+			hreq.Body, _ = req.GetBody()
+		}
+		go func() {
+			resp, err := t.RoundTrip(hreq)
+			replies <- hedgeReply{resp, err, cancel}
+		}()
+	}
+	try()
+	pending := 1
+	timer := time.NewTimer(a.cfg.Hedge)
+	defer timer.Stop()
+	var rep hedgeReply
+	for {
+		select {
+		case <-timer.C:
+			r.hedges++
+			pending++
+			try()
+			continue
+		case rep = <-replies:
+			pending--
+		}
+		if rep.err == nil || pending == 0 {
+			break
+		}
+		// Failed, wait for the other request if there is one
+		rep.cancel()
+		if r.hedges == 0 {
+			break
+		}
+	}
+	// Cancel and clean up the losing request
+	if pending > 0 {
+		go func() {
+			l := <-replies
+			l.cancel()
+			if l.err == nil {
+				l.resp.Body.Close()
+			}
+		}()
+	}
+	// The request has been replaced with a clone, so cancel its context when
+	// the body is closed
+	r.Response, r.err = rep.resp, rep.err
+	if rep.err != nil {
+		rep.cancel()
+		return
+	}
+	rep.resp.Body = cancelBody{rep.resp.Body, rep.cancel}
+}
@@ -0,0 +1,185 @@
+package tensile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// Sub-bucket bits of the latency histogram. Each power of two range is split
+// into 2^(histBits-1) linear buckets, bounding the error to under 0.2%.
+const histBits = 10
+
+// Sub-bucket bits of the histograms of timeline intervals, which need less
+// precision, bounding the error to under 2%
+const slotBits = 7
+
+// Histogram is a log-linear latency histogram, mergeable across runs. Its
+// size depends only on the range of latencies recorded, at most a few
+// thousand buckets, not on how many are.
+type Histogram struct {
+	bits   int
+	counts map[int]int64
+	total  int64
+}
+
+// NewHistogram returns an empty histogram
+func NewHistogram() *Histogram {
+	return newHistogram(histBits)
+}
+
+// Empty histogram with the given sub-bucket bits
+func newHistogram(bits int) *Histogram {
+	return &Histogram{bits: bits, counts: make(map[int]int64)}
+}
+
+// Bucket index of a value
+func (h *Histogram) bucketOf(v int64) int {
+	if v < 0 {
+		v = 0
+	}
+	shift := bits.Len64(uint64(v)) - h.bits
+	if shift <= 0 {
+		return int(v)
+	}
+	return shift<<(h.bits-1) + int(v>>uint(shift))
+}
+
+// Lowest value of a bucket
+func (h *Histogram) bucketLow(i int) int64 {
+	if i < 1<<h.bits {
+		return int64(i)
+	}
+	shift := i>>(h.bits-1) - 1
+	sub := i - shift<<(h.bits-1)
+	return int64(sub) << uint(shift)
+}
+
+// Add records a latency
+func (h *Histogram) Add(d time.Duration) {
+	h.counts[h.bucketOf(int64(d))]++
+	h.total++
+}
+
+// Merge another histogram into h
+func (h *Histogram) Merge(o *Histogram) {
+	if o.bits == h.bits {
+		for i, n := range o.counts {
+			h.counts[i] += n
+		}
+	} else {
+		for i, n := range o.counts {
+			h.counts[h.bucketOf(o.bucketLow(i))] += n
+		}
+	}
+	h.total += o.total
+}
+
+// Percentile returns the latency at percentile p (0-100)
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	idx := make([]int, 0, len(h.counts))
+	for i := range h.counts {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	rank := int64(math.Ceil(p / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for _, i := range idx {
+		seen += h.counts[i]
+		if seen >= rank {
+			return time.Duration(h.bucketLow(i))
+		}
+	}
+	return time.Duration(h.bucketLow(idx[len(idx)-1]))
+}
+
+// Total number of latencies recorded
+func (h *Histogram) Total() int64 {
+	return h.total
+}
+
+// Lines of the percentile distribution per halving of the distance to 100%,
+// as in HdrHistogram's own output
+const hgrmTicks = 5
+
+// WriteHgrm writes the percentile distribution in HdrHistogram's .hgrm
+// format, as read by its plotting tools and written by wrk2, with values in
+// multiples of unit, e.g. time.Millisecond
+func (h *Histogram) WriteHgrm(w io.Writer, unit time.Duration) error {
+	idx := make([]int, 0, len(h.counts))
+	for i := range h.counts {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	scale := float64(unit)
+	high := func(i int) float64 { return float64(h.bucketLow(i+1)-1) / scale }
+	fmt.Fprintf(w, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+	var seen int64
+	var sum, sumSq float64
+	level := 0.0
+	for n, i := range idx {
+		seen += h.counts[i]
+		for 100*float64(seen) >= level*float64(h.total) {
+			fmt.Fprintf(w, "%12.3f %2.12f %10d %14.2f\n", high(i), level/100, seen, 1/(1-level/100))
+			if n == len(idx)-1 {
+				// One last line at 100%
+				fmt.Fprintf(w, "%12.3f %2.12f %10d\n", high(i), 1.0, seen)
+				break
+			}
+			ticks := hgrmTicks * math.Exp2(math.Floor(math.Log2(100/(100-level)))+1)
+			level += 100 / ticks
+		}
+		mid := float64(h.bucketLow(i)+h.bucketLow(i+1)-1) / 2 / scale
+		sum += mid * float64(h.counts[i])
+		sumSq += mid * mid * float64(h.counts[i])
+	}
+	var mean, sd, top float64
+	buckets := 1
+	if h.total > 0 {
+		mean = sum / float64(h.total)
+		sd = math.Sqrt(math.Max(0, sumSq/float64(h.total)-mean*mean))
+		top = high(idx[len(idx)-1])
+		buckets = max(1, idx[len(idx)-1]>>(h.bits-1))
+	}
+	fmt.Fprintf(w, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", mean, sd)
+	fmt.Fprintf(w, "#[Max     = %12.3f, Total count    = %12d]\n", top, h.total)
+	_, err := fmt.Fprintf(w, "#[Buckets = %12d, SubBuckets     = %12d]\n", buckets, 1<<h.bits)
+	return err
+}
+
+// MarshalJSON encodes the histogram as a sparse list of [bucket low ns,
+// count] pairs
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	pairs := make([][2]int64, 0, len(h.counts))
+	for i, n := range h.counts {
+		pairs = append(pairs, [2]int64{h.bucketLow(i), n})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return json.Marshal(pairs)
+}
+
+// UnmarshalJSON decodes a histogram encoded by MarshalJSON
+func (h *Histogram) UnmarshalJSON(b []byte) error {
+	var pairs [][2]int64
+	if err := json.Unmarshal(b, &pairs); err != nil {
+		return err
+	}
+	h.bits = histBits
+	h.counts = make(map[int]int64, len(pairs))
+	h.total = 0
+	for _, p := range pairs {
+		h.counts[h.bucketOf(p[0])] += p[1]
+		h.total += p[1]
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package tensile
+
+import "net/http"
+
+// Bytes of a body passed to a ResponseValidator, at most
+const maxValidatedBody = 10 << 20
+
+// RequestModifier changes each request before it's sent, such as to sign it
+// or set a token. A request it returns an error for fails without being sent.
+type RequestModifier interface {
+	ModifyRequest(req *http.Request) error
+}
+
+// ResponseValidator checks each response and its body, decompressed and cut
+// to 10MB. A response it returns an error for fails the validator check.
+type ResponseValidator interface {
+	ValidateResponse(resp *http.Response, body []byte) error
+}
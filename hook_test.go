@@ -0,0 +1,59 @@
+package tensile
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// Modifier and validator calling functions
+type hookFuncs struct {
+	modify   func(*http.Request) error
+	validate func(*http.Response, []byte) error
+}
+
+func (h hookFuncs) ModifyRequest(req *http.Request) error { return h.modify(req) }
+
+func (h hookFuncs) ValidateResponse(resp *http.Response, body []byte) error {
+	return h.validate(resp, body)
+}
+
+func TestHooks(t *testing.T) {
+	var signed atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Signed") == "yes" {
+			signed.Add(1)
+		}
+		w.Write([]byte("bad"))
+	}))
+	defer srv.Close()
+	hooks := hookFuncs{
+		modify: func(req *http.Request) error {
+			req.Header.Set("X-Signed", "yes")
+			return nil
+		},
+		validate: func(resp *http.Response, body []byte) error {
+			if string(body) == "bad" {
+				return errors.New("bad body")
+			}
+			return nil
+		},
+	}
+	a := NewAttacker(WithURL(srv.URL), WithRequests(4), WithConcurrency(1), WithMaxErrors(4), WithRequestModifier(hooks), WithResponseValidator(hooks))
+	if c := a.Config(); c.RequestModifier == nil || c.ResponseValidator == nil {
+		t.Fatal("hooks not set")
+	}
+	res, err := a.Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Load() != 4 {
+		t.Errorf("%d requests modified, want 4", signed.Load())
+	}
+	if res.Errors != 4 {
+		t.Errorf("%d responses failed validation, want 4", res.Errors)
+	}
+}
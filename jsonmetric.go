@@ -0,0 +1,121 @@
+package tensile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bytes of a body searched for JSON metrics, at most
+const jsonMetricMaxBody = 16 << 20
+
+// JSONMetric is a duration reported in the JSON bodies of responses, found
+// at Path, such as $.meta.duration_ms, and counted in Unit. Its values are
+// reported as their own distribution in Results.JSONMetrics.
+type JSONMetric struct {
+	Name string
+	Path string
+	Unit time.Duration
+}
+
+// Step of a JSON path: a key of an object, or an index of an array if key
+// is empty
+type jsonStep struct {
+	key   string
+	index int
+}
+
+// Parse a JSON path of keys and indexes, e.g. $.items[0].meta["took ms"]
+func parseJSONPath(path string) ([]jsonStep, error) {
+	s, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("JSON path %q must start with $", path)
+	}
+	var steps []jsonStep
+	for s != "" {
+		switch {
+		case s[0] == '.':
+			end := strings.IndexAny(s[1:], ".[")
+			if end < 0 {
+				end = len(s) - 1
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("JSON path %q has an empty key", path)
+			}
+			steps = append(steps, jsonStep{key: s[1 : end+1]})
+			s = s[end+1:]
+		case strings.HasPrefix(s, `["`) || strings.HasPrefix(s, "['"):
+			end := strings.Index(s[2:], string(s[1])+"]")
+			if end < 0 {
+				return nil, fmt.Errorf("JSON path %q has an unterminated key", path)
+			}
+			steps = append(steps, jsonStep{key: s[2 : end+2]})
+			s = s[end+4:]
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			n, err := strconv.Atoi(s[1:max(end, 1)])
+			if end < 0 || err != nil || n < 0 {
+				return nil, fmt.Errorf("JSON path %q has an invalid index", path)
+			}
+			steps = append(steps, jsonStep{index: n})
+			s = s[end+1:]
+		default:
+			return nil, fmt.Errorf("JSON path %q is invalid at %q", path, s)
+		}
+	}
+	return steps, nil
+}
+
+// Value at a path of a decoded JSON document, if there is one
+func jsonLookup(v any, path []jsonStep) (any, bool) {
+	for _, st := range path {
+		switch c := v.(type) {
+		case map[string]any:
+			if v = c[st.key]; v == nil || st.key == "" {
+				return nil, false
+			}
+		case []any:
+			if st.key != "" || st.index >= len(c) {
+				return nil, false
+			}
+			v = c[st.index]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// Values of cfg.JSONMetrics in a JSON body, by name. Metrics missing from
+// the body, or not numbers, are skipped.
+func (a *attack) jsonMetrics(body []byte) map[string]time.Duration {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	var doc any
+	if d.Decode(&doc) != nil {
+		return nil
+	}
+	var ms map[string]time.Duration
+	for i, m := range a.cfg.JSONMetrics {
+		v, ok := jsonLookup(doc, a.jsonPaths[i])
+		if !ok {
+			continue
+		}
+		n, ok := v.(json.Number)
+		if !ok {
+			continue
+		}
+		f, err := n.Float64()
+		if err != nil || f < 0 {
+			continue
+		}
+		if ms == nil {
+			ms = make(map[string]time.Duration, len(a.cfg.JSONMetrics))
+		}
+		ms[m.Name] += time.Duration(f * float64(m.Unit))
+	}
+	return ms
+}
@@ -0,0 +1,29 @@
+package tensile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJSONMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"took": 5}`))
+	}))
+	defer srv.Close()
+	metric := JSONMetric{Name: "took", Path: "$.took", Unit: time.Millisecond}
+	a := NewAttacker(WithURL(srv.URL), WithRequests(4), WithConcurrency(1), WithJSONMetrics(metric))
+	if ms := a.Config().JSONMetrics; len(ms) != 1 || ms[0] != metric {
+		t.Fatalf("WithJSONMetrics set %v", ms)
+	}
+	res, err := a.Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := res.JSONMetrics["took"]; !ok || m.Mean != 5*time.Millisecond {
+		t.Errorf("JSON metrics %v, want took of 5ms", res.JSONMetrics)
+	}
+}
@@ -0,0 +1,77 @@
+package tensile
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// href attributes of anchors, quoted or not
+	anchorHref = regexp.MustCompile(`(?is)<a\s[^>]*?\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	// Tags that may load assets, and their attributes
+	assetTag  = regexp.MustCompile(`(?is)<(script|img|link)\s[^>]*>`)
+	assetAttr = regexp.MustCompile(`(?is)\s(src|href|rel)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// Absolute http and https URLs, without fragments, each once
+type urlSet struct {
+	base *url.URL
+	urls []string
+	seen map[string]bool
+}
+
+// Add a reference as found in HTML
+func (s *urlSet) add(ref string) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return
+	}
+	u, err := s.base.Parse(html.UnescapeString(ref))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	u.Fragment, u.RawFragment = "", ""
+	if k := u.String(); !s.seen[k] {
+		s.seen[k] = true
+		s.urls = append(s.urls, k)
+	}
+}
+
+// Links returns the absolute http and https URLs linked to by the anchors of
+// an HTML page at base, without fragments, each once in the order found
+func Links(page []byte, base *url.URL) []string {
+	s := urlSet{base: base, seen: make(map[string]bool)}
+	for _, m := range anchorHref.FindAllSubmatch(page, -1) {
+		s.add(string(m[1]) + string(m[2]) + string(m[3]))
+	}
+	return s.urls
+}
+
+// Assets returns the absolute http and https URLs of the scripts, images,
+// stylesheets and icons an HTML page at base loads, each once in the order
+// found
+func Assets(page []byte, base *url.URL) []string {
+	s := urlSet{base: base, seen: make(map[string]bool)}
+	for _, tag := range assetTag.FindAllSubmatch(page, -1) {
+		attrs := make(map[string]string)
+		for _, m := range assetAttr.FindAllSubmatch(tag[0], -1) {
+			attrs[strings.ToLower(string(m[1]))] = string(m[2]) + string(m[3]) + string(m[4])
+		}
+		switch strings.ToLower(string(tag[1])) {
+		case "script", "img":
+			if src, ok := attrs["src"]; ok {
+				s.add(src)
+			}
+		case "link":
+			for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+				if rel == "stylesheet" || rel == "icon" {
+					s.add(attrs["href"])
+					break
+				}
+			}
+		}
+	}
+	return s.urls
+}
@@ -0,0 +1,297 @@
+package tensile
+
+import (
+	"sort"
+	"time"
+)
+
+// Merge combines results from independent load generators run in parallel.
+// Counts are summed and latency histograms merged; the run duration is the
+// longest of the inputs and throughput the sum of each generator's. Only tags
+// shared by every input are kept. Timelines are aligned by offset, and the
+// target, method, backend and phase breakdowns merged by name. These have no
+// histograms, so their percentiles are the highest of the inputs.
+func Merge(rs ...Results) Results {
+	m := Results{
+		Tags:        make(Tags),
+		Status:      make(map[int]int64),
+		Checks:      make(map[string]int64),
+		Percentiles: make(map[string]time.Duration),
+		Histogram:   NewHistogram(),
+	}
+	var total time.Duration
+	highest := make(map[string]time.Duration) // Percentiles, without histograms
+	timed := false                            // Whether Min is set
+	timing := make(map[string]*metricStats)
+	jsonMetrics := make(map[string]*metricStats)
+	classes := make(map[string]*metricStats)
+	audit := make(map[string]map[string]int64)
+	var transfer, pageLoad, firstByte, chunkGap, dns *metricStats
+	certs := make(map[string]bool)
+	for i, s := range rs {
+		if i == 0 {
+			m.URL = s.URL
+			m.Seed = s.Seed
+			for k, v := range s.Tags {
+				m.Tags[k] = v
+			}
+		}
+		if s.Seed != m.Seed {
+			// Only kept if every input shares it
+			m.Seed = 0
+		}
+		for k, v := range m.Tags {
+			if s.Tags[k] != v {
+				delete(m.Tags, k)
+			}
+		}
+		m.Requests += s.Requests
+		m.Replies += s.Replies
+		m.Errors += s.Errors
+		m.Aborted += s.Aborted
+		m.Retries += s.Retries
+		m.Hedges += s.Hedges
+		m.Bytes += s.Bytes
+		m.Decoded += s.Decoded
+		m.Saved += s.Saved
+		m.Throughput += s.Throughput
+		if s.Duration > m.Duration {
+			m.Duration = s.Duration
+		}
+		if m.Stopped == "" {
+			m.Stopped = s.Stopped
+		}
+		// Inputs without requests have no latencies
+		if s.Requests > 0 && (!timed || s.Min < m.Min) {
+			m.Min, timed = s.Min, true
+		}
+		if s.Max > m.Max {
+			m.Max = s.Max
+		}
+		total += s.Mean * time.Duration(s.Requests)
+		for p, d := range s.Percentiles {
+			highest[p] = max(highest[p], d)
+		}
+		for j, p := range s.Timeline {
+			if j == len(m.Timeline) {
+				m.Timeline = append(m.Timeline, Point{Offset: p.Offset})
+			}
+			t := &m.Timeline[j]
+			t.Requests += p.Requests
+			t.Errors += p.Errors
+			t.Throughput += p.Throughput
+			t.Held += p.Held
+			t.P50, t.P99 = max(t.P50, p.P50), max(t.P99, p.P99)
+		}
+		for c, n := range s.Status {
+			m.Status[c] += n
+		}
+		for c, n := range s.Checks {
+			m.Checks[c] += n
+		}
+		for _, h := range s.Audit {
+			for v, n := range h.Values {
+				countAudit(audit, h.Header, v, n)
+			}
+		}
+		for o, n := range s.Expect {
+			if m.Expect == nil {
+				m.Expect = make(map[string]int64)
+			}
+			m.Expect[o] += n
+		}
+		for c, n := range s.GRPC {
+			if m.GRPC == nil {
+				m.GRPC = make(map[string]int64)
+			}
+			m.GRPC[c] += n
+		}
+		for c, n := range s.Compare {
+			if m.Compare == nil {
+				m.Compare = make(map[string]int64)
+			}
+			m.Compare[c] += n
+		}
+		m.Pauses = append(m.Pauses, s.Pauses...)
+		for _, ch := range s.Certs {
+			if !certs[ch.Host] {
+				certs[ch.Host] = true
+				m.Certs = append(m.Certs, ch)
+			}
+		}
+		if s.Conns != nil {
+			if m.Conns == nil {
+				m.Conns = &ConnStats{}
+			}
+			m.Conns.New += s.Conns.New
+			m.Conns.Reused += s.Conns.Reused
+			m.Conns.TLSFull += s.Conns.TLSFull
+			m.Conns.TLSResumed += s.Conns.TLSResumed
+		}
+		for net, n := range s.Connections {
+			if m.Connections == nil {
+				m.Connections = make(map[string]int64)
+			}
+			m.Connections[net] += n
+		}
+		for class, sm := range s.StatusLatency {
+			c := classes[class]
+			if c == nil {
+				c = &metricStats{hist: NewHistogram()}
+				classes[class] = c
+			}
+			c.merge(sm)
+		}
+		for name, sm := range s.ServerTiming {
+			t := timing[name]
+			if t == nil {
+				t = &metricStats{hist: NewHistogram()}
+				timing[name] = t
+			}
+			t.merge(sm)
+		}
+		for name, sm := range s.JSONMetrics {
+			t := jsonMetrics[name]
+			if t == nil {
+				t = &metricStats{hist: NewHistogram()}
+				jsonMetrics[name] = t
+			}
+			t.merge(sm)
+		}
+		if s.DNS != nil {
+			if dns == nil {
+				dns = &metricStats{hist: NewHistogram()}
+			}
+			dns.merge(*s.DNS)
+		}
+		if s.Transfer != nil {
+			if transfer == nil {
+				transfer = &metricStats{hist: NewHistogram()}
+			}
+			transfer.merge(*s.Transfer)
+		}
+		if s.PageLoad != nil {
+			if pageLoad == nil {
+				pageLoad = &metricStats{hist: NewHistogram()}
+			}
+			pageLoad.merge(*s.PageLoad)
+		}
+		if s.FirstByte != nil {
+			if firstByte == nil {
+				firstByte = &metricStats{hist: NewHistogram()}
+			}
+			firstByte.merge(*s.FirstByte)
+		}
+		if s.ChunkGap != nil {
+			if chunkGap == nil {
+				chunkGap = &metricStats{hist: NewHistogram()}
+			}
+			chunkGap.merge(*s.ChunkGap)
+		}
+		m.Stalls += s.Stalls
+		if s.Histogram != nil {
+			m.Histogram.Merge(s.Histogram)
+		}
+	}
+	if m.Requests > 0 {
+		m.ErrorRate = float64(m.Errors) / float64(m.Requests)
+	}
+	if m.Replies > 0 {
+		m.Average = m.Duration / time.Duration(m.Replies)
+	}
+	if m.Requests > 0 {
+		m.Mean = total / time.Duration(m.Requests)
+	}
+	if m.Histogram.Total() > 0 {
+		for _, p := range Percentiles {
+			m.Percentiles[PercentileName(p)] = m.Histogram.Percentile(p)
+		}
+	} else {
+		m.Percentiles = highest
+	}
+	names, parts := breakdowns(rs, func(r Results) []TargetResults { return r.Targets },
+		func(t TargetResults) (string, Results) { return t.Target, t.Results })
+	for _, n := range names {
+		m.Targets = append(m.Targets, TargetResults{n, mergeBreakdown(parts[n])})
+	}
+	names, parts = breakdowns(rs, func(r Results) []MethodResults { return r.Methods },
+		func(t MethodResults) (string, Results) { return t.Method, t.Results })
+	for _, n := range names {
+		m.Methods = append(m.Methods, MethodResults{n, mergeBreakdown(parts[n])})
+	}
+	names, parts = breakdowns(rs, func(r Results) []PhaseResults { return r.Phases },
+		func(t PhaseResults) (string, Results) { return t.Phase, t.Results })
+	for _, n := range names {
+		m.Phases = append(m.Phases, PhaseResults{n, mergeBreakdown(parts[n])})
+	}
+	names, parts = breakdowns(rs, func(r Results) []BackendResults { return r.Backends },
+		func(t BackendResults) (string, Results) { return t.Backend, t.Results })
+	for _, n := range names {
+		m.Backends = append(m.Backends, BackendResults{Backend: n, Results: mergeBreakdown(parts[n])})
+	}
+	sort.Slice(m.Backends, func(i, j int) bool { return m.Backends[i].Backend < m.Backends[j].Backend })
+	markOutliers(m.Backends)
+	m.Audit = audits(audit)
+	if len(timing) > 0 {
+		m.ServerTiming = make(map[string]Metric, len(timing))
+		for name, t := range timing {
+			m.ServerTiming[name] = t.summary()
+		}
+	}
+	if len(jsonMetrics) > 0 {
+		m.JSONMetrics = make(map[string]Metric, len(jsonMetrics))
+		for name, t := range jsonMetrics {
+			m.JSONMetrics[name] = t.summary()
+		}
+	}
+	if len(classes) > 0 {
+		m.StatusLatency = make(map[string]Metric, len(classes))
+		for class, c := range classes {
+			m.StatusLatency[class] = c.summary()
+		}
+	}
+	if transfer != nil {
+		t := transfer.summary()
+		m.Transfer = &t
+	}
+	if pageLoad != nil {
+		t := pageLoad.summary()
+		m.PageLoad = &t
+	}
+	if firstByte != nil {
+		t := firstByte.summary()
+		m.FirstByte = &t
+	}
+	if chunkGap != nil {
+		t := chunkGap.summary()
+		m.ChunkGap = &t
+	}
+	if dns != nil {
+		t := dns.summary()
+		m.DNS = &t
+	}
+	return m
+}
+
+// Breakdowns of rs grouped by name, with the names in order of first appearance
+func breakdowns[T any](rs []Results, list func(Results) []T, split func(T) (string, Results)) ([]string, map[string][]Results) {
+	var names []string
+	parts := make(map[string][]Results)
+	for _, r := range rs {
+		for _, b := range list(r) {
+			name, res := split(b)
+			if parts[name] == nil {
+				names = append(names, name)
+			}
+			parts[name] = append(parts[name], res)
+		}
+	}
+	return names, parts
+}
+
+// Merge the results of one breakdown, without the histogram or timeline
+func mergeBreakdown(rs []Results) Results {
+	m := Merge(rs...)
+	m.Histogram, m.Timeline = nil, nil
+	return m
+}
@@ -0,0 +1,22 @@
+package tensile
+
+// MethodWeight is a request method sent in proportion to its Weight, with its
+// own Body, if any
+type MethodWeight struct {
+	Method string
+	Weight int
+	Body   Body
+}
+
+// Index into cfg.Methods of the method of the next request, chosen at random
+// by weight
+func (a *attack) pickMethod() int {
+	n := a.rng.Intn(a.methodTotal)
+	for i, m := range a.cfg.Methods {
+		if n < m.Weight {
+			return i
+		}
+		n -= m.Weight
+	}
+	return len(a.cfg.Methods) - 1
+}
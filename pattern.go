@@ -0,0 +1,131 @@
+package tensile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pattern varies the request rate over an attack
+type Pattern interface {
+	// Rate in requests per second at offset t from the start
+	Rate(t time.Duration) float64
+}
+
+// Phase is a named period of an attack, reported separately
+type Phase struct {
+	Name  string
+	Start time.Duration // Offset from the start of the attack
+	End   time.Duration // Offset from the start, or 0 for the end of the attack
+}
+
+// Phaser is implemented by patterns with natural phases
+type Phaser interface {
+	Phases() []Phase
+}
+
+// Spike is a Base rate, multiplied by Multiplier for Length from At, then
+// back to Base
+type Spike struct {
+	Base       float64
+	Multiplier float64
+	At, Length time.Duration
+}
+
+// Rate at offset t
+func (s Spike) Rate(t time.Duration) float64 {
+	if t >= s.At && t < s.At+s.Length {
+		return s.Base * s.Multiplier
+	}
+	return s.Base
+}
+
+// Phases before, during and after the spike
+func (s Spike) Phases() []Phase {
+	return []Phase{
+		{"pre-spike", 0, s.At},
+		{"spike", s.At, s.At + s.Length},
+		{"recovery", s.At + s.Length, 0},
+	}
+}
+
+// Sine varies the rate between Min and Max over each Period, starting at Min
+type Sine struct {
+	Min, Max float64
+	Period   time.Duration
+}
+
+// Rate at offset t
+func (s Sine) Rate(t time.Duration) float64 {
+	x := 2 * math.Pi * float64(t) / float64(s.Period)
+	return s.Min + (s.Max-s.Min)*(1-math.Cos(x))/2
+}
+
+// SchedulePoint is the rate at an offset from the start
+type SchedulePoint struct {
+	At   time.Duration
+	Rate float64
+}
+
+// Schedule is a rate interpolated linearly between points, in order of
+// offset. The rate before the first point is that of the first point, and
+// after the last that of the last.
+type Schedule []SchedulePoint
+
+// Rate at offset t
+func (s Schedule) Rate(t time.Duration) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	for i, p := range s {
+		if t < p.At {
+			if i == 0 {
+				return p.Rate
+			}
+			prev := s[i-1]
+			f := float64(t-prev.At) / float64(p.At-prev.At)
+			return prev.Rate + f*(p.Rate-prev.Rate)
+		}
+	}
+	return s[len(s)-1].Rate
+}
+
+// ParseSchedule reads a schedule of one "offset,rate" point per line, e.g.
+// "5m,200". Blank lines and lines starting with # are ignored.
+func ParseSchedule(r io.Reader) (Schedule, error) {
+	var s Schedule
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		at, rate, ok := strings.Cut(line, ",")
+		if !ok {
+			return nil, fmt.Errorf("tensile: schedule line %d: expected offset,rate", n)
+		}
+		var p SchedulePoint
+		var err error
+		if p.At, err = time.ParseDuration(strings.TrimSpace(at)); err != nil {
+			return nil, fmt.Errorf("tensile: schedule line %d: %s", n, err)
+		}
+		if p.Rate, err = strconv.ParseFloat(strings.TrimSpace(rate), 64); err != nil || p.Rate < 0 {
+			return nil, fmt.Errorf("tensile: schedule line %d: invalid rate %q", n, rate)
+		}
+		if len(s) > 0 && p.At <= s[len(s)-1].At {
+			return nil, fmt.Errorf("tensile: schedule line %d: offsets must increase", n)
+		}
+		s = append(s, p)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(s) == 0 {
+		return nil, fmt.Errorf("tensile: empty schedule")
+	}
+	return s, nil
+}
@@ -0,0 +1,60 @@
+package tensile
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Longest time to wait for prewarming requests
+const prewarmTimeout = 30 * time.Second
+
+// Establish cfg.Concurrent keep-alive connections, including any TLS
+// handshakes, to the host of each target, with concurrent HEAD requests
+// spread over the transport shards like the workers. Each connection is held
+// until every request has been answered, so each request opens its own, then
+// left idle in the pool of its shard.
+func (a *attack) prewarm(ctx context.Context, ts []*http.Transport) {
+	ctx, cancel := context.WithTimeout(ctx, prewarmTimeout)
+	defer cancel()
+	start := time.Now()
+	seen := make(map[string]bool)
+	var warmed atomic.Int64
+	for _, tg := range a.cfg.Targets {
+		u, err := url.Parse(tg.URL)
+		if err != nil || seen[u.Scheme+"://"+u.Host] {
+			continue
+		}
+		seen[u.Scheme+"://"+u.Host] = true
+		var answered, done sync.WaitGroup
+		release := make(chan struct{})
+		for i := 0; i < a.cfg.Concurrent; i++ {
+			answered.Add(1)
+			done.Add(1)
+			t := ts[i%len(ts)]
+			go func() {
+				defer done.Done()
+				req, _ := http.NewRequestWithContext(ctx, http.MethodHead, tg.URL, nil)
+				req.Header.Set("User-Agent", App+Version)
+				resp, err := t.RoundTrip(req)
+				answered.Done()
+				<-release
+				if err != nil {
+					a.log.Debug("prewarming failed", "url", tg.URL, "err", err)
+					return
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				warmed.Add(1)
+			}()
+		}
+		answered.Wait()
+		close(release)
+		done.Wait()
+	}
+	a.log.Info("connections prewarmed", "connections", warmed.Load(), "took", time.Since(start))
+}
@@ -0,0 +1,713 @@
+package tensile
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrProto is returned by ParseProto for definitions it can't parse
+var ErrProto = errors.New("tensile: unsupported or invalid .proto definition")
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Proto is a parsed .proto file, with enough of its messages and services to
+// encode the requests of unary calls. Imports, extensions and the well known
+// types aren't supported.
+type Proto struct {
+	messages map[string]*protoMessage // By full name
+	enums    map[string]map[string]int32
+	methods  map[string]protoMethod // By Service/Method and full names
+}
+
+type protoMessage struct {
+	name   string
+	fields map[string]*protoField // By name and JSON name
+}
+
+type protoField struct {
+	name     string
+	number   uint64
+	typ      string // Scalar type, or full name of a message or enum once resolved
+	repeated bool
+	key      string // Key type of a map field
+	scope    string // Scope the type was named in
+}
+
+type protoMethod struct {
+	path  string // HTTP/2 path, /package.Service/Method
+	input string // Full name of the request message, once resolved
+	scope string // Package the service is in
+	unary bool
+}
+
+// Scalar types and their wire types
+var protoScalars = map[string]int{
+	"double": wireFixed64, "float": wireFixed32,
+	"int32": wireVarint, "int64": wireVarint, "uint32": wireVarint, "uint64": wireVarint,
+	"sint32": wireVarint, "sint64": wireVarint, "bool": wireVarint,
+	"fixed32": wireFixed32, "sfixed32": wireFixed32, "fixed64": wireFixed64, "sfixed64": wireFixed64,
+	"string": wireBytes, "bytes": wireBytes,
+}
+
+// Tokenizer of .proto files
+type protoLexer struct {
+	toks []string
+	pos  int
+}
+
+// Split a .proto file into tokens, dropping comments
+func lexProto(src string) (*protoLexer, error) {
+	l := &protoLexer{}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated comment", ErrProto)
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrProto)
+			}
+			l.toks = append(l.toks, src[i:j+1])
+			i = j + 1
+		case c == '_' || c == '.' || c == '-' || c == '+' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			l.toks = append(l.toks, src[i:j])
+			i = j
+		default:
+			l.toks = append(l.toks, string(c))
+			i++
+		}
+	}
+	return l, nil
+}
+
+// Next token, or "" at the end
+func (l *protoLexer) next() string {
+	if l.pos >= len(l.toks) {
+		l.pos++
+		return ""
+	}
+	l.pos++
+	return l.toks[l.pos-1]
+}
+
+// Peek at the next token
+func (l *protoLexer) peek() string {
+	if l.pos >= len(l.toks) {
+		return ""
+	}
+	return l.toks[l.pos]
+}
+
+// Consume the expected token
+func (l *protoLexer) expect(tok string) error {
+	if t := l.next(); t != tok {
+		return fmt.Errorf("%w: found %q, expected %q", ErrProto, t, tok)
+	}
+	return nil
+}
+
+// Skip to the end of a statement, or past a block
+func (l *protoLexer) skip() error {
+	depth := 0
+	for {
+		switch l.next() {
+		case "":
+			return fmt.Errorf("%w: unexpected end of file", ErrProto)
+		case ";":
+			if depth == 0 {
+				return nil
+			}
+		case "{":
+			depth++
+		case "}":
+			if depth--; depth <= 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// ParseProto parses a .proto file
+func ParseProto(r io.Reader) (*Proto, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	l, err := lexProto(string(src))
+	if err != nil {
+		return nil, err
+	}
+	p := &Proto{
+		messages: make(map[string]*protoMessage),
+		enums:    make(map[string]map[string]int32),
+		methods:  make(map[string]protoMethod),
+	}
+	pkg := ""
+	for tok := l.next(); tok != ""; tok = l.next() {
+		switch tok {
+		case "package":
+			pkg = l.next()
+			if err := l.expect(";"); err != nil {
+				return nil, err
+			}
+		case "message":
+			err = p.parseMessage(l, pkg)
+		case "enum":
+			err = p.parseEnum(l, pkg)
+		case "service":
+			err = p.parseService(l, pkg)
+		case ";":
+		default:
+			// syntax, import, option and extend
+			err = l.skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := p.resolve(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Join a scope and a name
+func scoped(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// Parse a message definition, after "message"
+func (p *Proto) parseMessage(l *protoLexer, scope string) error {
+	m := &protoMessage{name: scoped(scope, l.next()), fields: make(map[string]*protoField)}
+	p.messages[m.name] = m
+	if err := l.expect("{"); err != nil {
+		return err
+	}
+	return p.parseFields(l, m)
+}
+
+// Parse the fields of a message or oneof, up to the closing brace
+func (p *Proto) parseFields(l *protoLexer, m *protoMessage) error {
+	for {
+		var err error
+		switch tok := l.next(); tok {
+		case "}":
+			return nil
+		case "":
+			return fmt.Errorf("%w: unexpected end of file", ErrProto)
+		case ";":
+		case "message":
+			err = p.parseMessage(l, m.name)
+		case "enum":
+			err = p.parseEnum(l, m.name)
+		case "oneof":
+			l.next()
+			if err = l.expect("{"); err == nil {
+				err = p.parseFields(l, m)
+			}
+		case "option", "reserved", "extensions", "extend":
+			err = l.skip()
+		case "group":
+			err = fmt.Errorf("%w: groups", ErrProto)
+		default:
+			f := &protoField{scope: m.name}
+			switch tok {
+			case "repeated":
+				f.repeated = true
+				tok = l.next()
+			case "optional", "required":
+				tok = l.next()
+			}
+			if tok == "map" {
+				if err = l.expect("<"); err != nil {
+					return err
+				}
+				f.key = l.next()
+				if err = l.expect(","); err != nil {
+					return err
+				}
+				tok = l.next()
+				if err = l.expect(">"); err != nil {
+					return err
+				}
+				f.repeated = true
+			}
+			f.typ, f.name = tok, l.next()
+			if err = l.expect("="); err != nil {
+				return err
+			}
+			if f.number, err = strconv.ParseUint(l.next(), 0, 29); err != nil || f.number == 0 {
+				return fmt.Errorf("%w: field number of %s.%s", ErrProto, m.name, f.name)
+			}
+			m.fields[f.name] = f
+			m.fields[jsonName(f.name)] = f
+			err = l.skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// lowerCamelCase JSON name of a field
+func jsonName(name string) string {
+	var b strings.Builder
+	up := false
+	for _, c := range name {
+		switch {
+		case c == '_':
+			up = true
+		case up:
+			b.WriteRune(unicode.ToUpper(c))
+			up = false
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// Parse an enum definition, after "enum"
+func (p *Proto) parseEnum(l *protoLexer, scope string) error {
+	name := scoped(scope, l.next())
+	vals := make(map[string]int32)
+	p.enums[name] = vals
+	if err := l.expect("{"); err != nil {
+		return err
+	}
+	for {
+		switch tok := l.next(); tok {
+		case "}":
+			return nil
+		case "":
+			return fmt.Errorf("%w: unexpected end of file", ErrProto)
+		case ";":
+		case "option", "reserved":
+			if err := l.skip(); err != nil {
+				return err
+			}
+		default:
+			if err := l.expect("="); err != nil {
+				return err
+			}
+			n, err := strconv.ParseInt(l.next(), 0, 32)
+			if err != nil {
+				return fmt.Errorf("%w: value of %s.%s", ErrProto, name, tok)
+			}
+			vals[tok] = int32(n)
+			if err := l.skip(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Parse a service definition, after "service"
+func (p *Proto) parseService(l *protoLexer, pkg string) error {
+	name := l.next()
+	full := scoped(pkg, name)
+	if err := l.expect("{"); err != nil {
+		return err
+	}
+	for {
+		switch tok := l.next(); tok {
+		case "}":
+			return nil
+		case "":
+			return fmt.Errorf("%w: unexpected end of file", ErrProto)
+		case ";":
+		case "rpc":
+			m := protoMethod{scope: pkg, unary: true}
+			rpc := l.next()
+			if err := l.expect("("); err != nil {
+				return err
+			}
+			if l.peek() == "stream" {
+				l.next()
+				m.unary = false
+			}
+			m.input = l.next()
+			if err := l.expect(")"); err != nil {
+				return err
+			}
+			if err := l.expect("returns"); err != nil {
+				return err
+			}
+			if err := l.expect("("); err != nil {
+				return err
+			}
+			if l.peek() == "stream" {
+				l.next()
+				m.unary = false
+			}
+			l.next()
+			if err := l.expect(")"); err != nil {
+				return err
+			}
+			if l.peek() == "{" {
+				if err := l.skip(); err != nil {
+					return err
+				}
+			} else if err := l.expect(";"); err != nil {
+				return err
+			}
+			m.path = "/" + full + "/" + rpc
+			p.methods[name+"/"+rpc] = m
+			p.methods[full+"/"+rpc] = m
+		default:
+			if err := l.skip(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Full name of a type named in scope, or "" if there is no such message or
+// enum. Names are looked up in scope, then each enclosing scope.
+func (p *Proto) lookup(scope, name string) string {
+	if full, ok := strings.CutPrefix(name, "."); ok {
+		if _, ok := p.messages[full]; ok {
+			return full
+		}
+		if _, ok := p.enums[full]; ok {
+			return full
+		}
+		return ""
+	}
+	for {
+		full := scoped(scope, name)
+		if _, ok := p.messages[full]; ok {
+			return full
+		}
+		if _, ok := p.enums[full]; ok {
+			return full
+		}
+		if scope == "" {
+			return ""
+		}
+		i := strings.LastIndexByte(scope, '.')
+		if i < 0 {
+			scope = ""
+		} else {
+			scope = scope[:i]
+		}
+	}
+}
+
+// Resolve the types of fields and method inputs to full names
+func (p *Proto) resolve() error {
+	for _, m := range p.messages {
+		for _, f := range m.fields {
+			if f.scope == "" {
+				continue
+			}
+			if _, ok := protoScalars[f.typ]; !ok {
+				full := p.lookup(f.scope, f.typ)
+				if full == "" {
+					return fmt.Errorf("%w: unknown type %s of %s.%s", ErrProto, f.typ, m.name, f.name)
+				}
+				f.typ = full
+			}
+			if f.key != "" {
+				if _, ok := protoScalars[f.key]; !ok || f.key == "double" || f.key == "float" || f.key == "bytes" {
+					return fmt.Errorf("%w: map key type %s of %s.%s", ErrProto, f.key, m.name, f.name)
+				}
+			}
+			f.scope = ""
+		}
+	}
+	for k, m := range p.methods {
+		input := p.lookup(m.scope, m.input)
+		if _, ok := p.messages[input]; !ok {
+			return fmt.Errorf("%w: unknown request type %s of %s", ErrProto, m.input, m.path)
+		}
+		m.input, m.scope = input, ""
+		p.methods[k] = m
+	}
+	return nil
+}
+
+// Call returns the HTTP/2 path of a unary call, given as Service/Method with
+// or without the package, and its request message encoded from JSON
+func (p *Proto) Call(call string, data []byte) (string, []byte, error) {
+	call = strings.TrimPrefix(call, "/")
+	m, ok := p.methods[call]
+	if !ok {
+		return "", nil, fmt.Errorf("tensile: no method %s in .proto definition", call)
+	}
+	if !m.unary {
+		return "", nil, fmt.Errorf("tensile: %s is a streaming method, only unary calls are supported", call)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", nil, ErrJSON
+	}
+	msg, err := p.encodeMessage(nil, m.input, v)
+	if err != nil {
+		return "", nil, fmt.Errorf("tensile: encoding %s: %w", m.input, err)
+	}
+	return m.path, msg, nil
+}
+
+// Append the encoding of a message
+func (p *Proto) encodeMessage(b []byte, name string, v interface{}) ([]byte, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", name)
+	}
+	m := p.messages[name]
+	for k, fv := range obj {
+		f, ok := m.fields[k]
+		if !ok {
+			return nil, fmt.Errorf("no field %s in %s", k, name)
+		}
+		var err error
+		if b, err = p.encodeField(b, f, fv); err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return b, nil
+}
+
+// Append the encoding of a field
+func (p *Proto) encodeField(b []byte, f *protoField, v interface{}) ([]byte, error) {
+	if v == nil {
+		return b, nil
+	}
+	if f.key != "" {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("map must be an object")
+		}
+		for k, ev := range obj {
+			kv, err := mapKey(f.key, k)
+			if err != nil {
+				return nil, err
+			}
+			entry, err := p.encodeValue(nil, &protoField{number: 1, typ: f.key}, kv)
+			if err != nil {
+				return nil, err
+			}
+			if entry, err = p.encodeValue(entry, &protoField{number: 2, typ: f.typ}, ev); err != nil {
+				return nil, err
+			}
+			b = appendTag(b, f.number, wireBytes)
+			b = binary.AppendUvarint(b, uint64(len(entry)))
+			b = append(b, entry...)
+		}
+		return b, nil
+	}
+	if !f.repeated {
+		return p.encodeValue(b, f, v)
+	}
+	l, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.New("repeated field must be an array")
+	}
+	if w, ok := p.wireType(f.typ); ok && w != wireBytes {
+		// Packed
+		var packed []byte
+		for _, ev := range l {
+			var err error
+			if packed, err = p.appendScalar(packed, f.typ, ev); err != nil {
+				return nil, err
+			}
+		}
+		b = appendTag(b, f.number, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(packed)))
+		return append(b, packed...), nil
+	}
+	for _, ev := range l {
+		var err error
+		if b, err = p.encodeValue(b, f, ev); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Map keys are JSON strings, convert them to the key type
+func mapKey(typ, k string) (interface{}, error) {
+	switch typ {
+	case "string":
+		return k, nil
+	case "bool":
+		b, err := strconv.ParseBool(k)
+		return b, err
+	}
+	return json.Number(k), nil
+}
+
+// Wire type of a scalar or enum, and whether it is packable
+func (p *Proto) wireType(typ string) (int, bool) {
+	if w, ok := protoScalars[typ]; ok {
+		return w, typ != "string" && typ != "bytes"
+	}
+	if _, ok := p.enums[typ]; ok {
+		return wireVarint, true
+	}
+	return wireBytes, false
+}
+
+// Append a tagged value
+func (p *Proto) encodeValue(b []byte, f *protoField, v interface{}) ([]byte, error) {
+	if _, ok := p.messages[f.typ]; ok {
+		msg, err := p.encodeMessage(nil, f.typ, v)
+		if err != nil {
+			return nil, err
+		}
+		b = appendTag(b, f.number, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(msg)))
+		return append(b, msg...), nil
+	}
+	w, _ := p.wireType(f.typ)
+	return p.appendScalar(appendTag(b, f.number, w), f.typ, v)
+}
+
+func appendTag(b []byte, n uint64, wire int) []byte {
+	return binary.AppendUvarint(b, n<<3|uint64(wire))
+}
+
+// Append an untagged scalar or enum value
+func (p *Proto) appendScalar(b []byte, typ string, v interface{}) ([]byte, error) {
+	if vals, ok := p.enums[typ]; ok {
+		if s, ok := v.(string); ok {
+			n, ok := vals[s]
+			if !ok {
+				return nil, fmt.Errorf("no value %s in %s", s, typ)
+			}
+			return binary.AppendUvarint(b, uint64(int64(n))), nil
+		}
+		typ = "int32"
+	}
+	switch typ {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("expected a string")
+		}
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		return append(b, s...), nil
+	case "bytes":
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("expected a base64 string")
+		}
+		d, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			if d, err = base64.URLEncoding.DecodeString(s); err != nil {
+				return nil, errors.New("expected a base64 string")
+			}
+		}
+		b = binary.AppendUvarint(b, uint64(len(d)))
+		return append(b, d...), nil
+	case "bool":
+		t, ok := v.(bool)
+		if !ok {
+			return nil, errors.New("expected true or false")
+		}
+		if t {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	}
+	// Numbers, which may also be JSON strings
+	var s string
+	switch n := v.(type) {
+	case json.Number:
+		s = string(n)
+	case string:
+		s = n
+	default:
+		return nil, errors.New("expected a number")
+	}
+	switch typ {
+	case "double", "float":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, errors.New("expected a number")
+		}
+		if typ == "float" {
+			return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(f))), nil
+		}
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(f)), nil
+	case "uint32", "uint64", "fixed32", "fixed64":
+		bits := 64
+		if strings.HasSuffix(typ, "32") {
+			bits = 32
+		}
+		u, err := strconv.ParseUint(s, 10, bits)
+		if err != nil {
+			return nil, fmt.Errorf("expected a %s", typ)
+		}
+		switch typ {
+		case "fixed32":
+			return binary.LittleEndian.AppendUint32(b, uint32(u)), nil
+		case "fixed64":
+			return binary.LittleEndian.AppendUint64(b, u), nil
+		}
+		return binary.AppendUvarint(b, u), nil
+	}
+	bits := 64
+	if strings.HasSuffix(typ, "32") {
+		bits = 32
+	}
+	i, err := strconv.ParseInt(s, 10, bits)
+	if err != nil {
+		return nil, fmt.Errorf("expected a %s", typ)
+	}
+	switch typ {
+	case "sint32", "sint64":
+		return binary.AppendVarint(b, i), nil
+	case "sfixed32":
+		return binary.LittleEndian.AppendUint32(b, uint32(i)), nil
+	case "sfixed64":
+		return binary.LittleEndian.AppendUint64(b, uint64(i)), nil
+	}
+	// int32 and int64, negative values sign extended to 10 bytes
+	return binary.AppendUvarint(b, uint64(i)), nil
+}
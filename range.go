@@ -0,0 +1,124 @@
+package tensile
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ByteRange is a single range of bytes, as in a Range header. Last is -1 for
+// the rest of the body. If First is -1 the range is the final Last bytes.
+type ByteRange struct {
+	First, Last int64
+}
+
+// ParseByteRange parses a Range header value of a single range, e.g.
+// bytes=0-1023, bytes=1024- or bytes=-512
+func ParseByteRange(s string) (ByteRange, error) {
+	invalid := fmt.Errorf("tensile: invalid range %q, expected bytes=first-last", s)
+	spec, ok := strings.CutPrefix(strings.TrimSpace(s), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return ByteRange{}, invalid
+	}
+	f, l, ok := strings.Cut(spec, "-")
+	if !ok || (f == "" && l == "") {
+		return ByteRange{}, invalid
+	}
+	b := ByteRange{-1, -1}
+	var err error
+	if f != "" {
+		if b.First, err = strconv.ParseInt(f, 10, 64); err != nil || b.First < 0 {
+			return ByteRange{}, invalid
+		}
+	}
+	if l != "" {
+		if b.Last, err = strconv.ParseInt(l, 10, 64); err != nil || b.Last < 0 || (f != "" && b.Last < b.First) {
+			return ByteRange{}, invalid
+		}
+	}
+	return b, nil
+}
+
+// String returns the range as a Range header value
+func (b ByteRange) String() string {
+	switch {
+	case b.First < 0:
+		return fmt.Sprintf("bytes=-%d", b.Last)
+	case b.Last < 0:
+		return fmt.Sprintf("bytes=%d-", b.First)
+	}
+	return fmt.Sprintf("bytes=%d-%d", b.First, b.Last)
+}
+
+// The first and last bytes of a body of size bytes covered by the range
+func (b ByteRange) bounds(size int64) (int64, int64) {
+	if b.First < 0 {
+		return max(0, size-b.Last), size - 1
+	}
+	if b.Last < 0 || b.Last >= size {
+		return b.First, size - 1
+	}
+	return b.First, b.Last
+}
+
+// Parse a Content-Range header value of the form bytes first-last/size
+func parseContentRange(s string) (first, last, size int64, err error) {
+	spec, ok := strings.CutPrefix(s, "bytes ")
+	r, sz, ok2 := strings.Cut(spec, "/")
+	f, l, ok3 := strings.Cut(r, "-")
+	if !ok || !ok2 || !ok3 {
+		return 0, 0, 0, errors.New("malformed")
+	}
+	if first, err = strconv.ParseInt(f, 10, 64); err == nil {
+		if last, err = strconv.ParseInt(l, 10, 64); err == nil {
+			size, err = strconv.ParseInt(sz, 10, 64)
+		}
+	}
+	return first, last, size, err
+}
+
+// Range to request from target t: cfg.Range, or a random range of
+// cfg.RandomRange bytes within the size of the target, once it is known
+func (a *attack) nextRange(t int, rnd reqRand) ByteRange {
+	if a.cfg.Range != nil {
+		return *a.cfg.Range
+	}
+	n := a.cfg.RandomRange
+	a.validMu.Lock()
+	size := a.sizes[t]
+	a.validMu.Unlock()
+	var first int64
+	if size > n {
+		first = rnd.Int63n(0, size-n+1)
+	}
+	return ByteRange{first, first + n - 1}
+}
+
+// Check the status, Content-Range and body size of the response to a range
+// request, learning the size of its target
+func (a *attack) checkRange(r *response) error {
+	if r.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("range %s: status %d, expected 206", r.rng, r.StatusCode)
+	}
+	cr := r.Header.Get("Content-Range")
+	first, last, size, err := parseContentRange(cr)
+	if err != nil {
+		return fmt.Errorf("range %s: invalid Content-Range %q", r.rng, cr)
+	}
+	a.validMu.Lock()
+	if a.sizes == nil {
+		a.sizes = make(map[int]int64)
+	}
+	a.sizes[r.target] = size
+	a.validMu.Unlock()
+	f, l := r.rng.bounds(size)
+	if first != f || last != l {
+		return fmt.Errorf("range %s: Content-Range %q, expected bytes %d-%d/%d", r.rng, cr, f, l, size)
+	}
+	if r.Header.Get("Content-Encoding") == "" && r.size != l-f+1 {
+		return fmt.Errorf("range %s: %d bytes, expected %d", r.rng, r.size, l-f+1)
+	}
+	return nil
+}
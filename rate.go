@@ -0,0 +1,111 @@
+package tensile
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Longest the dispatcher may fall behind its schedule before the schedule is
+// reset, rather than catching up in a burst
+const maxLag = time.Second
+
+// Poll interval while a pattern's rate is 0
+const idlePoll = 10 * time.Millisecond
+
+// Paced requests sent this long after they were due are late
+const lateLag = 10 * time.Millisecond
+
+// How well the dispatcher kept to its schedule
+type schedule struct {
+	paced, late, blocked, resets int64
+	lagMax                       time.Duration
+}
+
+// Record the handoff of a request due at due, which waited for a free worker
+// if blocked
+func (s *schedule) sent(due time.Time, blocked bool) {
+	s.paced++
+	if lag := time.Since(due); lag > lateLag {
+		s.late++
+		s.lagMax = max(s.lagMax, lag)
+	}
+	if blocked {
+		s.blocked++
+	}
+}
+
+// Record in c how far the schedule slipped, and whether that makes the client
+// the bottleneck: over 1% of requests late
+func (s *schedule) annotate(c *ClientStats) {
+	c.Paced, c.Late, c.Blocked, c.LagMax, c.Resets = s.paced, s.late, s.blocked, s.lagMax, s.resets
+	if c.Bottleneck != "" || s.late*100 <= s.paced {
+		return
+	}
+	c.Bottleneck = fmt.Sprintf("%.1f%% of requests were sent late, up to %s behind schedule", float64(s.late)/float64(s.paced)*100, s.lagMax)
+	if s.blocked > 0 {
+		c.Bottleneck += fmt.Sprintf(", and %d waited for one of the -concurrent workers", s.blocked)
+	}
+}
+
+// Rate at offset t, from cfg.Pattern or cfg.Rate
+func (a *attack) rate(t time.Duration) float64 {
+	if a.cfg.Pattern != nil {
+		return a.cfg.Pattern.Rate(t)
+	}
+	return a.cfg.Rate
+}
+
+// Wait for d. Returns false if ctx is done or the deadline passes first.
+func wait(ctx context.Context, deadline <-chan time.Time, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-deadline:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Wait until the next request is due at the current rate, and schedule the
+// one after. Returns when it was due, and false if ctx is done or the deadline
+// passes first.
+func (a *attack) pace(ctx context.Context, deadline <-chan time.Time, next *time.Time) (time.Time, bool) {
+	d := time.Until(*next)
+	if -d > maxLag {
+		a.sched.resets++
+		*next = time.Now()
+	}
+	if !wait(ctx, deadline, d) {
+		return *next, false
+	}
+	r := a.rate(next.Sub(a.start))
+	for r <= 0 {
+		// Idle until the pattern picks up again
+		if !wait(ctx, deadline, idlePoll) {
+			return *next, false
+		}
+		*next = time.Now()
+		r = a.rate(next.Sub(a.start))
+	}
+	due := *next
+	*next = next.Add(time.Duration(float64(time.Second) / r))
+	return due, true
+}
+
+// Before request i, wait for the next burst if the current one has been
+// sent. Returns when the burst was due, and false if ctx is done or the
+// deadline passes first.
+func (a *attack) burst(ctx context.Context, deadline <-chan time.Time, i int) (time.Time, bool) {
+	due := a.start.Add(time.Duration(i/a.cfg.Burst) * a.cfg.BurstInterval)
+	if i == 0 || i%a.cfg.Burst != 0 {
+		return due, true
+	}
+	return due, wait(ctx, deadline, time.Until(due))
+}
@@ -0,0 +1,445 @@
+package tensile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// Result of a single request
+type Result struct {
+	Start   time.Duration // Offset from the start of the run
+	Latency time.Duration
+	// Time to read the body after Latency, measured for long polls
+	Transfer time.Duration
+	// Time to load the page and its assets, with Config.PageAssets
+	PageLoad time.Duration
+	Status   int
+	Size     int64
+	Decoded  int64  // Decompressed size, if compression was requested
+	Saved    int64  // Size of the representation a 304 response revalidated
+	Expect   string // Outcome of Expect: 100-continue, e.g. ExpectContinued
+	Err      string
+	Retries  int    // Retries of transient failures, not counted as requests
+	Hedges   int    // Duplicate requests sent by hedging
+	Target   string // Name of the target, if there are several
+	Backend  string // IP address of the server, if traced
+	Method   string // Method of the request, if there is a mix
+	Check    string // Failed response check, e.g. CheckTruncated
+	GRPC     string // gRPC status of a gRPC call, e.g. OK
+	Compare  string // Outcome of comparing with Config.CompareHost, e.g. CompareMatch
+
+	// Durations of the metrics in the Server-Timing header, if any
+	ServerTiming map[string]time.Duration
+
+	// Values of the audited headers, if any
+	Headers map[string]string
+
+	// Time to the first byte of the body, the gaps between its chunks and
+	// the stalls among them, with Config.Stream
+	FirstByte time.Duration
+	ChunkGaps []time.Duration
+	Stalls    int
+
+	// Values of Config.JSONMetrics in the body, if any
+	JSONMetrics map[string]time.Duration
+}
+
+// Failed reports whether the request failed, with a transport error, an
+// error status or a failed response check
+func (r Result) Failed() bool {
+	return r.Err != "" || r.Status >= 400
+}
+
+// RunInfo is the header of a recording
+type RunInfo struct {
+	Version    string
+	URL        string
+	Requests   int
+	Concurrent int
+	Start      time.Time
+	Tags       Tags
+	Sample     int // If above 1, only one in Sample results was recorded
+}
+
+// Recordings start with RecordingMagic and a version byte, then the
+// length-prefixed JSON RunInfo, then a length-prefixed record for every
+// result. A record is a bitmap of the fields that aren't zero, then those
+// fields as varints, Start as the change from the previous result. Strings
+// repeated from one result to the next, like errors and target names, are
+// written once and then referred to by number, so a result takes a dozen or
+// so bytes.
+const (
+	RecordingMagic   = "TNSR"
+	recordingVersion = 1
+	maxInterned      = 1 << 16  // Strings referred to by number, at most
+	maxRecord        = 16 << 20 // Bytes in a record, at most
+)
+
+// ErrRecording is returned for a recording that isn't valid
+var ErrRecording = errors.New("tensile: not a valid recording")
+
+// Recorder is a streaming writer of raw results
+type Recorder struct {
+	w      *bufio.Writer
+	c      io.Closer
+	sample int
+	n      int               // Results written, including those not recorded
+	strs   map[string]uint64 // Numbers of the strings written so far
+	start  time.Duration     // Of the previous result recorded
+	buf    []byte
+}
+
+// Append a length-prefixed record to b
+func appendRecord(b, rec []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(rec)))
+	return append(b, rec...)
+}
+
+// NewRecorder writes the header of a recording to w. If info.Sample is above
+// 1, only the first of every info.Sample results written is recorded.
+func NewRecorder(w io.Writer, info RunInfo) (*Recorder, error) {
+	js, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	rec := &Recorder{w: bufio.NewWriter(w), sample: info.Sample, strs: make(map[string]uint64)}
+	b := append([]byte(RecordingMagic), recordingVersion)
+	if _, err := rec.w.Write(appendRecord(b, js)); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// CreateRecorder creates a recording file and writes its header
+func CreateRecorder(path string, info RunInfo) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := NewRecorder(f, info)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	rec.c = f
+	return rec, nil
+}
+
+// Append s to b: its number if written before, or else its length and bytes
+func (rec *Recorder) appendString(b []byte, s string) []byte {
+	if id, ok := rec.strs[s]; ok {
+		return binary.AppendUvarint(b, id<<1|1)
+	}
+	if s != "" && len(rec.strs) < maxInterned {
+		rec.strs[s] = uint64(len(rec.strs))
+	}
+	b = binary.AppendUvarint(b, uint64(len(s))<<1)
+	return append(b, s...)
+}
+
+// Write appends a result, unless it is left out of the sample
+func (rec *Recorder) Write(r Result) error {
+	rec.n++
+	if rec.sample > 1 && (rec.n-1)%rec.sample != 0 {
+		return nil
+	}
+	nums := [...]int64{int64(r.Start - rec.start), int64(r.Latency), int64(r.Transfer), int64(r.Status),
+		r.Size, r.Decoded, r.Saved, int64(r.Retries), int64(r.Hedges)}
+	strs := [...]string{r.Expect, r.Err, r.Target, r.Backend, r.Method, r.Check, r.GRPC}
+	rec.start = r.Start
+	var set uint64
+	for i, v := range nums {
+		if v != 0 {
+			set |= 1 << i
+		}
+	}
+	for i, s := range strs {
+		if s != "" {
+			set |= 1 << (len(nums) + i)
+		}
+	}
+	if len(r.ServerTiming) > 0 {
+		set |= 1 << (len(nums) + len(strs))
+	}
+	if len(r.Headers) > 0 {
+		set |= 1 << (len(nums) + len(strs) + 1)
+	}
+	if r.PageLoad != 0 {
+		set |= 1 << (len(nums) + len(strs) + 2)
+	}
+	if r.Compare != "" {
+		set |= 1 << (len(nums) + len(strs) + 3)
+	}
+	if r.FirstByte != 0 {
+		set |= 1 << (len(nums) + len(strs) + 4)
+	}
+	if len(r.ChunkGaps) > 0 {
+		set |= 1 << (len(nums) + len(strs) + 5)
+	}
+	if r.Stalls != 0 {
+		set |= 1 << (len(nums) + len(strs) + 6)
+	}
+	if len(r.JSONMetrics) > 0 {
+		set |= 1 << (len(nums) + len(strs) + 7)
+	}
+	b := binary.AppendUvarint(rec.buf[:0], set)
+	for _, v := range nums {
+		if v != 0 {
+			b = binary.AppendVarint(b, v)
+		}
+	}
+	for _, s := range strs {
+		if s != "" {
+			b = rec.appendString(b, s)
+		}
+	}
+	if len(r.ServerTiming) > 0 {
+		b = binary.AppendUvarint(b, uint64(len(r.ServerTiming)))
+		for k, v := range r.ServerTiming {
+			b = rec.appendString(b, k)
+			b = binary.AppendVarint(b, int64(v))
+		}
+	}
+	if len(r.Headers) > 0 {
+		b = binary.AppendUvarint(b, uint64(len(r.Headers)))
+		for k, v := range r.Headers {
+			b = rec.appendString(b, k)
+			b = rec.appendString(b, v)
+		}
+	}
+	if r.PageLoad != 0 {
+		b = binary.AppendVarint(b, int64(r.PageLoad))
+	}
+	if r.Compare != "" {
+		b = rec.appendString(b, r.Compare)
+	}
+	if r.FirstByte != 0 {
+		b = binary.AppendVarint(b, int64(r.FirstByte))
+	}
+	if len(r.ChunkGaps) > 0 {
+		b = binary.AppendUvarint(b, uint64(len(r.ChunkGaps)))
+		for _, g := range r.ChunkGaps {
+			b = binary.AppendVarint(b, int64(g))
+		}
+	}
+	if r.Stalls != 0 {
+		b = binary.AppendVarint(b, int64(r.Stalls))
+	}
+	if len(r.JSONMetrics) > 0 {
+		b = binary.AppendUvarint(b, uint64(len(r.JSONMetrics)))
+		for k, v := range r.JSONMetrics {
+			b = rec.appendString(b, k)
+			b = binary.AppendVarint(b, int64(v))
+		}
+	}
+	rec.buf = b
+	var n [binary.MaxVarintLen64]byte
+	if _, err := rec.w.Write(binary.AppendUvarint(n[:0], uint64(len(b)))); err != nil {
+		return err
+	}
+	_, err := rec.w.Write(b)
+	return err
+}
+
+// Flush writes any buffered results to the underlying writer
+func (rec *Recorder) Flush() error {
+	return rec.w.Flush()
+}
+
+// Close flushes the recording, and closes the file if made by CreateRecorder
+func (rec *Recorder) Close() error {
+	err := rec.w.Flush()
+	if rec.c != nil {
+		if cerr := rec.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// ReadRecording reads a recording file, calling fn for each result
+func ReadRecording(path string, fn func(Result)) (RunInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return RunInfo{}, err
+	}
+	defer f.Close()
+	return DecodeRecording(f, fn)
+}
+
+// DecodeRecording decodes a recording stream, calling fn for each result
+func DecodeRecording(rd io.Reader, fn func(Result)) (RunInfo, error) {
+	br := bufio.NewReader(rd)
+	if magic, _ := br.Peek(len(RecordingMagic) + 1); !bytes.HasPrefix(magic, []byte(RecordingMagic)) || magic[len(RecordingMagic)] != recordingVersion {
+		return RunInfo{}, ErrRecording
+	}
+	br.Discard(len(RecordingMagic) + 1)
+	var info RunInfo
+	d := recordDecoder{r: br}
+	if !d.next() {
+		return info, ErrRecording
+	}
+	if err := json.Unmarshal(d.rec, &info); err != nil {
+		return info, err
+	}
+	var start time.Duration
+	for d.next() {
+		var r Result
+		var v [9]int64
+		strs := [...]*string{&r.Expect, &r.Err, &r.Target, &r.Backend, &r.Method, &r.Check, &r.GRPC}
+		set := d.uvarint()
+		for i := range v {
+			if set&(1<<i) != 0 {
+				v[i] = d.varint()
+			}
+		}
+		start += time.Duration(v[0])
+		r.Start, r.Latency, r.Transfer, r.Status = start, time.Duration(v[1]), time.Duration(v[2]), int(v[3])
+		r.Size, r.Decoded, r.Saved, r.Retries, r.Hedges = v[4], v[5], v[6], int(v[7]), int(v[8])
+		for i, s := range strs {
+			if set&(1<<(len(v)+i)) != 0 {
+				*s = d.string()
+			}
+		}
+		if set&(1<<(len(v)+len(strs))) != 0 {
+			n := d.count()
+			r.ServerTiming = make(map[string]time.Duration, n)
+			for range n {
+				k := d.string()
+				r.ServerTiming[k] = time.Duration(d.varint())
+			}
+		}
+		if set&(1<<(len(v)+len(strs)+1)) != 0 {
+			n := d.count()
+			r.Headers = make(map[string]string, n)
+			for range n {
+				k := d.string()
+				r.Headers[k] = d.string()
+			}
+		}
+		if set&(1<<(len(v)+len(strs)+2)) != 0 {
+			r.PageLoad = time.Duration(d.varint())
+		}
+		if set&(1<<(len(v)+len(strs)+3)) != 0 {
+			r.Compare = d.string()
+		}
+		if set&(1<<(len(v)+len(strs)+4)) != 0 {
+			r.FirstByte = time.Duration(d.varint())
+		}
+		if set&(1<<(len(v)+len(strs)+5)) != 0 {
+			n := d.count()
+			r.ChunkGaps = make([]time.Duration, n)
+			for i := range n {
+				r.ChunkGaps[i] = time.Duration(d.varint())
+			}
+		}
+		if set&(1<<(len(v)+len(strs)+6)) != 0 {
+			r.Stalls = int(d.varint())
+		}
+		if set&(1<<(len(v)+len(strs)+7)) != 0 {
+			n := d.count()
+			r.JSONMetrics = make(map[string]time.Duration, n)
+			for range n {
+				k := d.string()
+				r.JSONMetrics[k] = time.Duration(d.varint())
+			}
+		}
+		if d.err != nil || len(d.rec) > 0 {
+			return info, ErrRecording
+		}
+		fn(r)
+	}
+	return info, d.err
+}
+
+// Reads the records of a recording, and the fields of each
+type recordDecoder struct {
+	r    *bufio.Reader
+	buf  []byte
+	rec  []byte   // Rest of the current record
+	strs []string // Strings by number
+	err  error    // Set if the records ended early, or one is invalid
+}
+
+// Read the next record, reporting whether there is one
+func (d *recordDecoder) next() bool {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		if err != io.EOF {
+			d.err = ErrRecording
+		}
+		return false
+	}
+	if n > maxRecord {
+		d.err = ErrRecording
+		return false
+	}
+	if uint64(cap(d.buf)) < n {
+		d.buf = make([]byte, n)
+	}
+	d.rec = d.buf[:n]
+	if _, err := io.ReadFull(d.r, d.rec); err != nil {
+		d.err = io.ErrUnexpectedEOF
+		return false
+	}
+	return true
+}
+
+func (d *recordDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.rec)
+	if n <= 0 {
+		d.err, d.rec = ErrRecording, nil
+		return 0
+	}
+	d.rec = d.rec[n:]
+	return v
+}
+
+func (d *recordDecoder) varint() int64 {
+	v, n := binary.Varint(d.rec)
+	if n <= 0 {
+		d.err, d.rec = ErrRecording, nil
+		return 0
+	}
+	d.rec = d.rec[n:]
+	return v
+}
+
+// Number of entries in a map, which can't be more than the bytes left
+func (d *recordDecoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.rec)) {
+		d.err, d.rec = ErrRecording, nil
+		return 0
+	}
+	return int(n)
+}
+
+// A string, by number or inline, see Recorder.appendString
+func (d *recordDecoder) string() string {
+	v := d.uvarint()
+	if v&1 == 1 {
+		if v>>1 >= uint64(len(d.strs)) {
+			d.err, d.rec = ErrRecording, nil
+			return ""
+		}
+		return d.strs[v>>1]
+	}
+	n := v >> 1
+	if n > uint64(len(d.rec)) {
+		d.err, d.rec = ErrRecording, nil
+		return ""
+	}
+	s := string(d.rec[:n])
+	d.rec = d.rec[n:]
+	if s != "" && len(d.strs) < maxInterned {
+		d.strs = append(d.strs, s)
+	}
+	return s
+}
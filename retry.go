@@ -0,0 +1,66 @@
+package tensile
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Whether a request method is idempotent, and so safe to retry
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// Whether a failed attempt is worth retrying: transport errors, throttling
+// and gateway errors
+func transient(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Backoff before retry n (from 0): exponential from base, with full jitter
+func backoff(rnd reqRand, base time.Duration, n int) time.Duration {
+	d := base << uint(n)
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rnd.Int63n(n+1, int64(d)) + 1)
+}
+
+// Send a request into r, retrying transient failures up to cfg.Retries times
+func (a *attack) roundTrip(ctx context.Context, t http.RoundTripper, req *http.Request, r *response) {
+	a.send(t, req, r)
+	if a.cfg.Retries <= 0 || (!a.cfg.RetryAll && !idempotent(req.Method)) {
+		return
+	}
+	for ; r.retries < a.cfg.Retries && transient(r.Response, r.err) && ctx.Err() == nil; r.retries++ {
+		if r.err == nil {
+			io.Copy(io.Discard, r.Body)
+			r.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff(r.rand, a.cfg.RetryBackoff, r.retries)):
+		}
+		if req.GetBody != nil {
+			if req.Body, r.err = req.GetBody(); r.err != nil {
+				r.Response = nil
+				return
+			}
+		}
+		a.log.Debug("retrying request", "attempt", r.retries+1, "url", req.URL.String())
+		a.send(t, req, r)
+	}
+}
@@ -0,0 +1,61 @@
+package tensile
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// CacheBustParam is the query parameter added to request URLs by
+// Config.CacheBust
+const CacheBustParam = "_tensile"
+
+// Add a random CacheBustParam to the query of u, so the request misses any
+// cache
+func cacheBust(rng *rand.Rand, u *url.URL) {
+	q := CacheBustParam + "=" + strconv.FormatUint(rng.Uint64(), 36)
+	if u.RawQuery == "" {
+		u.RawQuery = q
+	} else {
+		u.RawQuery += "&" + q
+	}
+}
+
+// Validators of a target's representation, from its latest full response
+type validator struct {
+	etag, modified string
+	size           int64
+}
+
+// Add conditional headers to req from the validators of target t, returning
+// the size of the representation they validate, or -1 if there are none yet
+func (a *attack) conditional(req *http.Request, t int) int64 {
+	a.validMu.Lock()
+	defer a.validMu.Unlock()
+	v, ok := a.valid[t]
+	if !ok {
+		return -1
+	}
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if v.modified != "" {
+		req.Header.Set("If-Modified-Since", v.modified)
+	}
+	return v.size
+}
+
+// Update the validators of target t from a full response
+func (a *attack) validate(t int, r *response) {
+	v := validator{r.Header.Get("ETag"), r.Header.Get("Last-Modified"), r.size}
+	if v.etag == "" && v.modified == "" {
+		return
+	}
+	a.validMu.Lock()
+	defer a.validMu.Unlock()
+	if a.valid == nil {
+		a.valid = make(map[int]validator)
+	}
+	a.valid[t] = v
+}
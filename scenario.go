@@ -0,0 +1,168 @@
+package tensile
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Bytes in a line of a scenario, at most, as long bodies are on one line
+const maxScenarioLine = 64 << 20
+
+// WriteScenario writes targets as a scenario, a YAML list of the steps of a
+// user journey. Strings are double quoted, and bodies that aren't UTF-8 are
+// base64 encoded.
+//
+//	steps:
+//	- name: "POST /login"
+//	  method: "POST"
+//	  url: "https://staging/login"
+//	  headers:
+//	    "Content-Type": "application/x-www-form-urlencoded"
+//	  body: "user=demo&password=demo"
+func WriteScenario(w io.Writer, steps []Target) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("steps:\n")
+	for _, s := range steps {
+		fmt.Fprintf(bw, "- name: %s\n", strconv.Quote(s.Name))
+		if s.Method != "" {
+			fmt.Fprintf(bw, "  method: %s\n", strconv.Quote(s.Method))
+		}
+		fmt.Fprintf(bw, "  url: %s\n", strconv.Quote(s.URL))
+		if len(s.Header) > 0 {
+			bw.WriteString("  headers:\n")
+			keys := make([]string, 0, len(s.Header))
+			for k := range s.Header {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				for _, v := range s.Header[k] {
+					fmt.Fprintf(bw, "    %s: %s\n", strconv.Quote(k), strconv.Quote(v))
+				}
+			}
+		}
+		switch {
+		case s.Body == nil:
+		case utf8.Valid(s.Body):
+			fmt.Fprintf(bw, "  body: %s\n", strconv.Quote(string(s.Body)))
+		default:
+			fmt.Fprintf(bw, "  body_base64: %q\n", base64.StdEncoding.EncodeToString(s.Body))
+		}
+	}
+	return bw.Flush()
+}
+
+// ParseScenario reads the steps of a scenario written by WriteScenario, as
+// targets. Headers repeated in a step are added in turn.
+func ParseScenario(r io.Reader) ([]Target, error) {
+	var steps []Target
+	var inHeaders bool
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxScenarioLine)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || line == "steps:" {
+			continue
+		}
+		fail := func(msg string) error {
+			return fmt.Errorf("tensile: scenario line %d: %s", n, msg)
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.HasPrefix(line, "- ") {
+			steps = append(steps, Target{})
+			indent, trimmed = 2, line[2:]
+		}
+		if len(steps) == 0 {
+			return nil, fail("expected steps:")
+		}
+		k, v, err := scenarioField(trimmed)
+		if err != nil {
+			return nil, fail(err.Error())
+		}
+		s := &steps[len(steps)-1]
+		if indent == 4 && inHeaders {
+			if s.Header == nil {
+				s.Header = make(http.Header)
+			}
+			s.Header.Add(k, v)
+			continue
+		}
+		if indent != 2 {
+			return nil, fail("unexpected indentation")
+		}
+		inHeaders = false
+		switch k {
+		case "name":
+			s.Name = v
+		case "method":
+			s.Method = v
+		case "url":
+			s.URL = v
+		case "headers":
+			inHeaders = true
+		case "body":
+			s.Body = []byte(v)
+		case "body_base64":
+			if s.Body, err = base64.StdEncoding.DecodeString(v); err != nil {
+				return nil, fail(err.Error())
+			}
+		default:
+			return nil, fail(fmt.Sprintf("unknown field %q", k))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("tensile: no steps in scenario")
+	}
+	for i, s := range steps {
+		if err := validURL(s.URL); err != nil {
+			return nil, fmt.Errorf("tensile: scenario step %d: invalid URL %q", i+1, s.URL)
+		}
+		if steps[i].Name == "" {
+			steps[i].Name = s.URL
+		}
+	}
+	return steps, nil
+}
+
+// Key and value of a line of a scenario, either of which may be quoted
+func scenarioField(s string) (string, string, error) {
+	var k string
+	if strings.HasPrefix(s, `"`) {
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", "", err
+		}
+		k, _ = strconv.Unquote(q)
+		s = s[len(q):]
+	} else {
+		i := strings.IndexByte(s, ':')
+		if i < 0 {
+			return "", "", fmt.Errorf("expected key: value")
+		}
+		k, s = s[:i], s[i:]
+	}
+	s, ok := strings.CutPrefix(s, ":")
+	if !ok {
+		return "", "", fmt.Errorf("expected key: value")
+	}
+	v := strings.TrimSpace(s)
+	if strings.HasPrefix(v, `"`) {
+		u, err := strconv.Unquote(v)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid string %s", v)
+		}
+		v = u
+	}
+	return k, v, nil
+}
@@ -0,0 +1,160 @@
+package tensile
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoLevel is returned by FindConcurrency and FindRate when even the
+// lowest level exceeds the limits
+var ErrNoLevel = errors.New("tensile: no level is within the limits")
+
+const (
+	// Throughput gain needed for a higher concurrency level to count as an
+	// improvement, rather than saturation
+	minGain = 1.05
+	// Fraction of the requested rate that must be achieved for it to count
+	// as sustained
+	minSustained = 0.9
+	// Precision of FindRate, as a fraction of the rate found
+	ratePrecision = 0.05
+	// Starting rate of FindRate if none is given
+	defaultRate = 10
+)
+
+// Limits a run must stay within, zero values are unlimited
+type Limits struct {
+	P99       time.Duration
+	ErrorRate float64 // Fraction of requests, e.g. 0.01 for 1%
+}
+
+// Within reports whether results are within the limits
+func (l Limits) Within(r Results) bool {
+	if l.P99 > 0 && r.Percentiles["p99"] > l.P99 {
+		return false
+	}
+	return l.ErrorRate <= 0 || r.ErrorRate <= l.ErrorRate
+}
+
+// Level is the result of a run at one concurrency level or rate
+type Level struct {
+	Concurrent int
+	Rate       float64
+	Results    Results
+	Within     bool // Within the limits
+}
+
+// FindConcurrency finds the saturation point of the target: the lowest
+// concurrency level giving the highest throughput within lim, up to max.
+// Concurrency is doubled from 1 until throughput stops improving or the
+// limits are exceeded, then bisected between the last good and first bad
+// levels. Each level is a separate run of cfg, so cfg should have a Duration
+// or a Requests count large enough for a stable measurement. fn, if not nil,
+// is called with each level as it completes. cfg.Results is not used.
+func FindConcurrency(ctx context.Context, cfg Config, lim Limits, max int, fn func(Level)) (Level, error) {
+	cfg.Results = nil
+	run := func(c int) (Level, error) {
+		cfg.Concurrent = c
+		res, err := Attack(ctx, cfg)
+		l := Level{Concurrent: c, Results: res, Within: lim.Within(res)}
+		if err == nil && fn != nil {
+			fn(l)
+		}
+		return l, err
+	}
+	better := func(l, best Level) bool {
+		return l.Within && (best.Concurrent == 0 || l.Results.Throughput >= best.Results.Throughput*minGain)
+	}
+	var best Level
+	hi := 0
+	for c := 1; c <= max; c *= 2 {
+		l, err := run(c)
+		if err != nil {
+			return best, err
+		}
+		if !better(l, best) {
+			hi = c
+			break
+		}
+		best = l
+	}
+	if best.Concurrent == 0 {
+		return best, ErrNoLevel
+	}
+	if hi == 0 {
+		// Still improving at max
+		hi = max + 1
+		if best.Concurrent == max {
+			return best, nil
+		}
+	}
+	lo := best.Concurrent
+	for hi-lo > 1 && hi-lo > lo/10 {
+		l, err := run((lo + hi) / 2)
+		if err != nil {
+			return best, err
+		}
+		if better(l, best) {
+			best, lo = l, l.Concurrent
+		} else {
+			hi = l.Concurrent
+		}
+	}
+	return best, nil
+}
+
+// FindRate finds the capacity of the target: the highest request rate that
+// is sustained, with p99 latency and error rate within lim. The rate is
+// doubled from cfg.Rate, or 10 per second, until a limit is exceeded, then
+// bisected to within 5%. As with FindConcurrency each level is a separate run
+// of cfg, and cfg.Concurrent must be high enough to sustain the rate.
+func FindRate(ctx context.Context, cfg Config, lim Limits, fn func(Level)) (Level, error) {
+	cfg.Results = nil
+	run := func(r float64) (Level, error) {
+		cfg.Rate = r
+		res, err := Attack(ctx, cfg)
+		l := Level{Concurrent: cfg.Concurrent, Rate: r, Results: res, Within: lim.Within(res)}
+		if res.Duration > 0 && float64(res.Requests)/res.Duration.Seconds() < r*minSustained {
+			l.Within = false
+		}
+		if err == nil && fn != nil {
+			fn(l)
+		}
+		return l, err
+	}
+	r := cfg.Rate
+	if r <= 0 {
+		r = defaultRate
+	}
+	var best Level
+	var hi float64
+	for {
+		l, err := run(r)
+		if err != nil {
+			return best, err
+		}
+		if !l.Within {
+			hi = r
+			break
+		}
+		best = l
+		r *= 2
+	}
+	if best.Rate == 0 {
+		return best, ErrNoLevel
+	}
+	lo := best.Rate
+	for (hi-lo)/lo > ratePrecision {
+		l, err := run((lo + hi) / 2)
+		if err != nil {
+			return best, err
+		}
+		if l.Within {
+			best, lo = l, l.Rate
+		} else {
+			hi = l.Rate
+		}
+	}
+	return best, nil
+}
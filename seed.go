@@ -0,0 +1,28 @@
+package tensile
+
+import "math/rand"
+
+// Random number of a request, drawn by the dispatcher so that the choices
+// workers make from it don't depend on how they're scheduled
+type reqRand uint64
+
+// The nth number derived from r, by splitmix64, in [0, max)
+func (r reqRand) Int63n(n int, max int64) int64 {
+	z := uint64(r) + uint64(n+1)*0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return int64(z>>1) % max
+}
+
+// Body b, with its random data generated from seed if it's a random
+// Synthetic body
+func seeded(b Body, seed int64) Body {
+	s, ok := b.(*Synthetic)
+	if !ok || !s.Random {
+		return b
+	}
+	c := &Synthetic{Size: s.Size, Random: true, block: make([]byte, len(s.block))}
+	rand.New(rand.NewSource(seed)).Read(c.block)
+	return c
+}
@@ -0,0 +1,44 @@
+package tensile
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// SHA-256 of the bodies a seeded attack with a random Synthetic body sends
+func seededBodies(t *testing.T, seed int64) map[[32]byte]int {
+	var mu sync.Mutex
+	sums := make(map[[32]byte]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		sums[sha256.Sum256(b)]++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	res, err := NewAttacker(WithURL(srv.URL), WithMethod(http.MethodPost), WithBody(NewSynthetic(4096, true)),
+		WithRequests(4), WithConcurrency(2), WithSeed(seed)).Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Seed != seed {
+		t.Errorf("seed %d reported, want %d", res.Seed, seed)
+	}
+	return sums
+}
+
+func TestSeededBody(t *testing.T) {
+	a, b, c := seededBodies(t, 1), seededBodies(t, 1), seededBodies(t, 2)
+	if len(a) != 1 || !maps.Equal(a, b) {
+		t.Fatalf("seed 1 sent bodies %x, then %x", a, b)
+	}
+	if maps.Equal(a, c) {
+		t.Fatal("seeds 1 and 2 sent the same body")
+	}
+}
@@ -0,0 +1,88 @@
+package tensile
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metric is the distribution of a duration over a run, such as a named
+// Server-Timing metric
+type Metric struct {
+	Count       int64                    `json:"count"`
+	Mean        time.Duration            `json:"mean_ns"`
+	Max         time.Duration            `json:"max_ns"`
+	Percentiles map[string]time.Duration `json:"percentiles_ns"`
+	Histogram   *Histogram               `json:"histogram,omitempty"`
+}
+
+// Running totals of a Server-Timing metric
+type metricStats struct {
+	hist       *Histogram
+	total, max time.Duration
+}
+
+func (m *metricStats) add(d time.Duration) {
+	m.hist.Add(d)
+	m.total += d
+	if d > m.max {
+		m.max = d
+	}
+}
+
+// Merge the summary of a metric, which must include its histogram for the
+// percentiles to be merged
+func (m *metricStats) merge(s Metric) {
+	m.total += s.Mean * time.Duration(s.Count)
+	if s.Max > m.max {
+		m.max = s.Max
+	}
+	if s.Histogram != nil {
+		m.hist.Merge(s.Histogram)
+	}
+}
+
+// Summary of the metric
+func (m *metricStats) summary() Metric {
+	s := Metric{Count: m.hist.Total(), Max: m.max, Percentiles: make(map[string]time.Duration), Histogram: m.hist}
+	if s.Count > 0 {
+		s.Mean = m.total / time.Duration(s.Count)
+	}
+	for _, p := range Percentiles {
+		s.Percentiles[PercentileName(p)] = m.hist.Percentile(p)
+	}
+	return s
+}
+
+// Parse the durations of the metrics in the Server-Timing headers of h, e.g.
+// `db;dur=53.2, cache;desc="miss";dur=0.4`. Metrics without a duration are
+// skipped, and repeated metrics summed.
+func parseServerTiming(h http.Header) map[string]time.Duration {
+	var m map[string]time.Duration
+	for _, v := range h.Values("Server-Timing") {
+		for _, metric := range strings.Split(v, ",") {
+			params := strings.Split(metric, ";")
+			name := strings.TrimSpace(params[0])
+			if name == "" {
+				continue
+			}
+			for _, p := range params[1:] {
+				k, v, _ := strings.Cut(p, "=")
+				if !strings.EqualFold(strings.TrimSpace(k), "dur") {
+					continue
+				}
+				ms, err := strconv.ParseFloat(strings.Trim(strings.TrimSpace(v), `"`), 64)
+				if err != nil || ms < 0 {
+					break
+				}
+				if m == nil {
+					m = make(map[string]time.Duration)
+				}
+				m[name] += time.Duration(ms * float64(time.Millisecond))
+				break
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,58 @@
+package tensile
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SitemapURL is a page listed in a sitemap
+type SitemapURL struct {
+	Loc      string
+	Priority float64 // From 0 to 1, 0.5 if not given
+}
+
+// Sitemap is a parsed sitemap.xml, listing pages or, for a sitemap index,
+// other sitemaps
+type Sitemap struct {
+	URLs     []SitemapURL
+	Sitemaps []string
+}
+
+// ParseSitemap reads a sitemap or sitemap index in the sitemaps.org format
+func ParseSitemap(r io.Reader) (Sitemap, error) {
+	var doc struct {
+		XMLName xml.Name
+		URLs    []struct {
+			Loc      string `xml:"loc"`
+			Priority string `xml:"priority"`
+		} `xml:"url"`
+		Sitemaps []struct {
+			Loc string `xml:"loc"`
+		} `xml:"sitemap"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return Sitemap{}, fmt.Errorf("tensile: sitemap: %s", err)
+	}
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		return Sitemap{}, fmt.Errorf("tensile: sitemap: unexpected <%s>, expected <urlset> or <sitemapindex>", doc.XMLName.Local)
+	}
+	var s Sitemap
+	for _, u := range doc.URLs {
+		p, err := strconv.ParseFloat(strings.TrimSpace(u.Priority), 64)
+		if err != nil || p < 0 || p > 1 {
+			p = 0.5
+		}
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			s.URLs = append(s.URLs, SitemapURL{loc, p})
+		}
+	}
+	for _, m := range doc.Sitemaps {
+		if loc := strings.TrimSpace(m.Loc); loc != "" {
+			s.Sitemaps = append(s.Sitemaps, loc)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,234 @@
+package tensile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modes of a slow client attack
+const (
+	SlowHeaders = "headers" // Headers are sent slowly, and never finished
+	SlowBody    = "body"    // Headers are sent, then the body slowly
+)
+
+var (
+	ErrSlowMode = errors.New("tensile: Mode must be SlowHeaders or SlowBody")
+	ErrByteRate = errors.New("tensile: ByteRate must be greater than 0")
+)
+
+// Shortest interval between writes of a slow client
+const minSlowTick = 10 * time.Millisecond
+
+// SlowConfig of a slow client attack
+type SlowConfig struct {
+	URL         string
+	Connections int
+	Mode        string
+	ByteRate    float64 // Bytes per second sent on each connection
+	BodySize    int64   // Content-Length declared in SlowBody mode, or 1MB if 0
+	Duration    time.Duration
+	Timeout     time.Duration // Connect timeout, or 10 seconds if 0
+	Logger      *slog.Logger
+}
+
+// SlowResults of a slow client attack
+type SlowResults struct {
+	URL         string        `json:"url"`
+	Mode        string        `json:"mode"`
+	Connections int           `json:"connections"`
+	Connected   int64         `json:"connected"`
+	Failed      int64         `json:"failed"`   // Connections that couldn't be opened
+	Closed      int64         `json:"closed"`   // Connections the server closed or answered
+	Survived    int64         `json:"survived"` // Connections still open at the end
+	Status      map[int]int64 `json:"status,omitempty"`
+	Sent        int64         `json:"sent_bytes"`
+	Duration    time.Duration `json:"duration_ns"`
+	Tolerated   Metric        `json:"tolerated"` // Time the server held closed connections
+}
+
+// State of a slow client attack
+type slowAttack struct {
+	cfg SlowConfig
+	log *slog.Logger
+	u   *url.URL
+
+	mu        sync.Mutex
+	res       SlowResults
+	tolerated metricStats
+}
+
+// AttackSlow opens cfg.Connections connections at once and sends requests on
+// them at cfg.ByteRate, to find how long the server tolerates slow clients.
+// Connections are held until the server closes or answers them, or for
+// cfg.Duration. Only run this against servers you are responsible for.
+func AttackSlow(ctx context.Context, cfg SlowConfig) (SlowResults, error) {
+	switch {
+	case cfg.Connections <= 0:
+		return SlowResults{}, ErrConnections
+	case cfg.Duration <= 0:
+		return SlowResults{}, ErrDuration
+	case cfg.Mode != SlowHeaders && cfg.Mode != SlowBody:
+		return SlowResults{}, ErrSlowMode
+	case cfg.ByteRate <= 0:
+		return SlowResults{}, ErrByteRate
+	}
+	if err := validURL(cfg.URL); err != nil {
+		return SlowResults{}, err
+	}
+	u, _ := url.Parse(cfg.URL)
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.BodySize <= 0 {
+		cfg.BodySize = 1 << 20
+	}
+	a := &slowAttack{
+		cfg:       cfg,
+		log:       cfg.Logger,
+		u:         u,
+		res:       SlowResults{URL: cfg.URL, Mode: cfg.Mode, Connections: cfg.Connections},
+		tolerated: metricStats{hist: NewHistogram()},
+	}
+	if a.log == nil {
+		a.log = slog.Default()
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.conn(ctx)
+		}()
+	}
+	wg.Wait()
+	a.res.Duration = time.Since(start)
+	a.res.Tolerated = a.tolerated.summary()
+	a.res.Tolerated.Histogram = nil
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return a.res, nil
+	}
+	return a.res, ctx.Err()
+}
+
+// Start of the request, sent at once, and the size of the rest to trickle
+// after it, or -1 if endless
+func (a *slowAttack) request() (string, int64) {
+	var b strings.Builder
+	method := "GET"
+	if a.cfg.Mode == SlowBody {
+		method = "POST"
+	}
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\nHost: %s\r\nUser-Agent: %s\r\n", method, a.u.RequestURI(), a.u.Host, App+Version)
+	if a.cfg.Mode == SlowBody {
+		fmt.Fprintf(&b, "Content-Type: application/octet-stream\r\nContent-Length: %d\r\n\r\n", a.cfg.BodySize)
+		return b.String(), a.cfg.BodySize
+	}
+	// An endless header, X-Tensile: xxx..., never terminated
+	b.WriteString("X-Tensile: ")
+	return b.String(), -1
+}
+
+// Open a connection and trickle a request on it until ctx is done, or the
+// server closes or answers it
+func (a *slowAttack) conn(ctx context.Context) {
+	dctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+	c, err := dialURL(dctx, a.u)
+	cancel()
+	if err != nil {
+		a.mu.Lock()
+		if ctx.Err() == nil {
+			a.res.Failed++
+		}
+		a.mu.Unlock()
+		if ctx.Err() == nil {
+			a.log.Debug("connect failed", "err", err)
+		}
+		return
+	}
+	defer c.Close()
+	start := time.Now()
+	a.mu.Lock()
+	a.res.Connected++
+	a.mu.Unlock()
+
+	// The server closes or answers the connection
+	closed := make(chan int, 1)
+	go func() {
+		status := 0
+		if resp, err := http.ReadResponse(bufio.NewReader(c), nil); err == nil {
+			status = resp.StatusCode
+			resp.Body.Close()
+		}
+		closed <- status
+	}()
+
+	head, size := a.request()
+	sent := int64(0)
+	n, err := c.Write([]byte(head))
+	sent += int64(n)
+	tick := time.Duration(float64(time.Second) / a.cfg.ByteRate)
+	per := int64(1)
+	if tick < minSlowTick {
+		tick = minSlowTick
+		per = int64(a.cfg.ByteRate * tick.Seconds())
+	}
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	status := -1
+	var body int64
+	for err == nil && status < 0 {
+		select {
+		case <-ctx.Done():
+			a.mu.Lock()
+			a.res.Survived++
+			a.res.Sent += sent
+			a.mu.Unlock()
+			return
+		case status = <-closed:
+		case <-t.C:
+			if size >= 0 && body >= size {
+				// The whole body is sent, wait for the answer
+				continue
+			}
+			p := bytes.Repeat([]byte{'x'}, int(per))
+			if size >= 0 && body+per > size {
+				p = p[:size-body]
+			}
+			n, err = c.Write(p)
+			body += int64(n)
+			sent += int64(n)
+		}
+	}
+	held := time.Since(start)
+	if status < 0 {
+		// A failed write, the answer may follow
+		select {
+		case status = <-closed:
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.res.Closed++
+	a.res.Sent += sent
+	a.tolerated.add(held)
+	if status > 0 {
+		if a.res.Status == nil {
+			a.res.Status = make(map[int]int64)
+		}
+		a.res.Status[status]++
+	}
+	a.log.Debug("connection closed by server", "held", held, "status", status)
+}
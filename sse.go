@@ -0,0 +1,202 @@
+package tensile
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default delay before reconnecting a Server-Sent Events stream, unless the
+// server sets one
+const sseRetry = time.Second
+
+// SSEConfig of a Server-Sent Events attack
+type SSEConfig struct {
+	URL         string
+	Connections int
+	Duration    time.Duration
+	Timeout     time.Duration // Connect timeout, or 10 seconds if 0
+	Logger      *slog.Logger
+}
+
+// SSEResults of a Server-Sent Events attack
+type SSEResults struct {
+	URL         string        `json:"url"`
+	Connections int           `json:"connections"`
+	Connected   int64         `json:"connected"`  // Streams opened, including reconnects
+	Failed      int64         `json:"failed"`     // Attempts to open a stream that failed
+	Reconnects  int64         `json:"reconnects"` // Streams reopened after ending early
+	Events      int64         `json:"events"`
+	Duration    time.Duration `json:"duration_ns"`
+	Connect     Metric        `json:"connect"`  // Time to open streams
+	Interval    Metric        `json:"interval"` // Time between events on a stream
+}
+
+// State of a Server-Sent Events attack
+type sseAttack struct {
+	cfg    SSEConfig
+	log    *slog.Logger
+	client *http.Client
+
+	mu       sync.Mutex
+	res      SSEResults
+	connect  metricStats
+	interval metricStats
+}
+
+// AttackSSE runs a Server-Sent Events attack, holding cfg.Connections
+// streams open for cfg.Duration and reconnecting any that end early
+func AttackSSE(ctx context.Context, cfg SSEConfig) (SSEResults, error) {
+	switch {
+	case cfg.Connections <= 0:
+		return SSEResults{}, ErrConnections
+	case cfg.Duration <= 0:
+		return SSEResults{}, ErrDuration
+	}
+	if err := validURL(cfg.URL); err != nil {
+		return SSEResults{}, err
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	t := &http.Transport{ResponseHeaderTimeout: cfg.Timeout, MaxIdleConnsPerHost: cfg.Connections}
+	defer t.CloseIdleConnections()
+	a := &sseAttack{
+		cfg:      cfg,
+		log:      cfg.Logger,
+		client:   &http.Client{Transport: t},
+		res:      SSEResults{URL: cfg.URL, Connections: cfg.Connections},
+		connect:  metricStats{hist: NewHistogram()},
+		interval: metricStats{hist: NewHistogram()},
+	}
+	if a.log == nil {
+		a.log = slog.Default()
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.stream(ctx)
+		}()
+	}
+	wg.Wait()
+	a.res.Duration = time.Since(start)
+	a.res.Connect, a.res.Interval = a.connect.summary(), a.interval.summary()
+	a.res.Connect.Histogram, a.res.Interval.Histogram = nil, nil
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return a.res, nil
+	}
+	return a.res, ctx.Err()
+}
+
+// Hold a stream open until ctx is done, reconnecting if it ends
+func (a *sseAttack) stream(ctx context.Context) {
+	var lastID string
+	retry := sseRetry
+	for opened := false; ; {
+		err := a.read(ctx, &lastID, &retry, opened)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			a.log.Error("event stream failed", "err", err)
+		} else {
+			a.log.Debug("event stream ended")
+		}
+		opened = true
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// Open a stream and read events until it ends
+func (a *sseAttack) read(ctx context.Context, lastID *string, retry *time.Duration, reconnect bool) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", a.cfg.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", App+Version)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if *lastID != "" {
+		req.Header.Set("Last-Event-ID", *lastID)
+	}
+	start := time.Now()
+	resp, err := a.client.Do(req)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err = fmt.Errorf("tensile: event stream status %s", resp.Status)
+	}
+	if err == nil {
+		if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt != "text/event-stream" {
+			resp.Body.Close()
+			err = fmt.Errorf("tensile: event stream Content-Type %q", resp.Header.Get("Content-Type"))
+		}
+	}
+	a.mu.Lock()
+	if err != nil {
+		if ctx.Err() == nil {
+			a.res.Failed++
+		}
+		a.mu.Unlock()
+		return err
+	}
+	a.res.Connected++
+	if reconnect {
+		a.res.Reconnects++
+	}
+	a.connect.add(time.Since(start))
+	a.mu.Unlock()
+	defer resp.Body.Close()
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(nil, 1<<20)
+	var last time.Time
+	data := false
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			// A blank line dispatches the event, if it has data
+			if data {
+				now := time.Now()
+				a.mu.Lock()
+				a.res.Events++
+				if !last.IsZero() {
+					a.interval.add(now.Sub(last))
+				}
+				a.mu.Unlock()
+				last = now
+			}
+			data = false
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = true
+		case "id":
+			*lastID = value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				*retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return sc.Err()
+}
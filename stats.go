@@ -0,0 +1,566 @@
+package tensile
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Percentiles included in every summary
+var Percentiles = []float64{50, 90, 95, 99}
+
+// Interval of the timeline
+const TimelineInterval = time.Second
+
+// Aggregate of the results completing in one timeline interval
+type slot struct {
+	requests, errors int64
+	opened, closed   int64 // Requests started, and completed, in the interval
+	hist             *Histogram
+}
+
+// Stats aggregates the results of a run, and is safe for concurrent use. The
+// request and error counts are kept outside the lock, so progress can be read
+// while results are being added.
+type Stats struct {
+	requests, errors atomic.Int64
+	last             atomic.Int64 // End offset of the latest result
+
+	mu              sync.Mutex
+	bounded         bool
+	min, max, total time.Duration
+	hist            *Histogram
+	size            int64
+	retries, hedges int64
+	decoded, saved  int64
+	status          map[int]int64
+	checks          map[string]int64
+	timing          map[string]*metricStats
+	jsonMetrics     map[string]*metricStats
+	classes         map[string]*metricStats // Latency by status class
+	audit           map[string]map[string]int64
+	expect          map[string]int64
+	grpc            map[string]int64
+	compare         map[string]int64
+	transfer        *metricStats
+	pageLoad        *metricStats
+	firstByte       *metricStats
+	chunkGap        *metricStats
+	stalls          int64
+	timeline        []*slot
+}
+
+// NewStats returns empty statistics. Percentiles are taken from the latency
+// histogram, so are accurate to within 0.2% however many results are added.
+func NewStats() *Stats {
+	return &Stats{hist: NewHistogram(), status: make(map[int]int64), checks: make(map[string]int64)}
+}
+
+// NewBoundedStats returns empty statistics using bounded memory however long
+// the run, by leaving out the timeline.
+func NewBoundedStats() *Stats {
+	s := NewStats()
+	s.bounded = true
+	return s
+}
+
+// Status classes latency is broken down by, with "error" for requests
+// without a response
+var statusClasses = [...]string{"error", "1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// Status class of a result
+func statusClass(r Result) string {
+	if c := r.Status / 100; c > 0 && c < len(statusClasses) {
+		return statusClasses[c]
+	}
+	return statusClasses[0]
+}
+
+// Add a result
+func (s *Stats) Add(r Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.requests.Add(1)
+	s.retries += int64(r.Retries)
+	s.hedges += int64(r.Hedges)
+	if r.Status != 0 {
+		s.status[r.Status]++
+	}
+	if r.Check != "" {
+		s.checks[r.Check]++
+	}
+	if r.Expect != "" {
+		if s.expect == nil {
+			s.expect = make(map[string]int64)
+		}
+		s.expect[r.Expect]++
+	}
+	if r.GRPC != "" {
+		if s.grpc == nil {
+			s.grpc = make(map[string]int64)
+		}
+		s.grpc[r.GRPC]++
+	}
+	if r.Compare != "" {
+		if s.compare == nil {
+			s.compare = make(map[string]int64)
+		}
+		s.compare[r.Compare]++
+	}
+	if r.Transfer > 0 {
+		if s.transfer == nil {
+			s.transfer = &metricStats{hist: NewHistogram()}
+		}
+		s.transfer.add(r.Transfer)
+	}
+	if r.PageLoad > 0 {
+		if s.pageLoad == nil {
+			s.pageLoad = &metricStats{hist: NewHistogram()}
+		}
+		s.pageLoad.add(r.PageLoad)
+	}
+	if r.FirstByte > 0 {
+		if s.firstByte == nil {
+			s.firstByte = &metricStats{hist: NewHistogram()}
+		}
+		s.firstByte.add(r.FirstByte)
+	}
+	for _, g := range r.ChunkGaps {
+		if s.chunkGap == nil {
+			s.chunkGap = &metricStats{hist: NewHistogram()}
+		}
+		s.chunkGap.add(g)
+	}
+	s.stalls += int64(r.Stalls)
+	for name, v := range r.Headers {
+		if s.audit == nil {
+			s.audit = make(map[string]map[string]int64)
+		}
+		countAudit(s.audit, name, v, 1)
+	}
+	for name, d := range r.ServerTiming {
+		m := s.timing[name]
+		if m == nil {
+			if s.timing == nil {
+				s.timing = make(map[string]*metricStats)
+			}
+			m = &metricStats{hist: NewHistogram()}
+			s.timing[name] = m
+		}
+		m.add(d)
+	}
+	for name, d := range r.JSONMetrics {
+		m := s.jsonMetrics[name]
+		if m == nil {
+			if s.jsonMetrics == nil {
+				s.jsonMetrics = make(map[string]*metricStats)
+			}
+			m = &metricStats{hist: NewHistogram()}
+			s.jsonMetrics[name] = m
+		}
+		m.add(d)
+	}
+	class := statusClass(r)
+	c := s.classes[class]
+	if c == nil {
+		if s.classes == nil {
+			s.classes = make(map[string]*metricStats)
+		}
+		c = &metricStats{hist: NewHistogram()}
+		s.classes[class] = c
+	}
+	c.add(r.Latency)
+	if r.Failed() {
+		s.errors.Add(1)
+	} else {
+		s.size += r.Size
+		s.decoded += r.Decoded
+		s.saved += r.Saved
+	}
+	if n == 1 || r.Latency < s.min {
+		s.min = r.Latency
+	}
+	if r.Latency > s.max {
+		s.max = r.Latency
+	}
+	s.total += r.Latency
+	s.hist.Add(r.Latency)
+	if !s.bounded {
+		s.addSlot(r)
+	}
+	end := int64(r.Start + r.Latency)
+	for last := s.last.Load(); end > last && !s.last.CompareAndSwap(last, end); {
+		last = s.last.Load()
+	}
+}
+
+// Merge the results added to o into s, for statistics accumulated in shards
+func (s *Stats) merge(o *Stats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := o.requests.Load()
+	if n == 0 {
+		return
+	}
+	if s.requests.Load() == 0 || o.min < s.min {
+		s.min = o.min
+	}
+	s.requests.Add(n)
+	s.errors.Add(o.errors.Load())
+	if last := o.last.Load(); last > s.last.Load() {
+		s.last.Store(last)
+	}
+	s.max = max(s.max, o.max)
+	s.total += o.total
+	s.hist.Merge(o.hist)
+	s.size += o.size
+	s.retries += o.retries
+	s.hedges += o.hedges
+	s.decoded += o.decoded
+	s.saved += o.saved
+	for k, v := range o.status {
+		s.status[k] += v
+	}
+	for k, v := range o.checks {
+		s.checks[k] += v
+	}
+	for k, v := range o.expect {
+		if s.expect == nil {
+			s.expect = make(map[string]int64)
+		}
+		s.expect[k] += v
+	}
+	for k, v := range o.grpc {
+		if s.grpc == nil {
+			s.grpc = make(map[string]int64)
+		}
+		s.grpc[k] += v
+	}
+	for k, v := range o.compare {
+		if s.compare == nil {
+			s.compare = make(map[string]int64)
+		}
+		s.compare[k] += v
+	}
+	for name, vs := range o.audit {
+		if s.audit == nil {
+			s.audit = make(map[string]map[string]int64)
+		}
+		for v, c := range vs {
+			countAudit(s.audit, name, v, c)
+		}
+	}
+	for name, m := range o.timing {
+		if s.timing == nil {
+			s.timing = make(map[string]*metricStats)
+		}
+		if s.timing[name] == nil {
+			s.timing[name] = &metricStats{hist: NewHistogram()}
+		}
+		s.timing[name].merge(m.summary())
+	}
+	for name, m := range o.jsonMetrics {
+		if s.jsonMetrics == nil {
+			s.jsonMetrics = make(map[string]*metricStats)
+		}
+		if s.jsonMetrics[name] == nil {
+			s.jsonMetrics[name] = &metricStats{hist: NewHistogram()}
+		}
+		s.jsonMetrics[name].merge(m.summary())
+	}
+	for class, m := range o.classes {
+		if s.classes == nil {
+			s.classes = make(map[string]*metricStats)
+		}
+		if s.classes[class] == nil {
+			s.classes[class] = &metricStats{hist: NewHistogram()}
+		}
+		s.classes[class].merge(m.summary())
+	}
+	if o.transfer != nil {
+		if s.transfer == nil {
+			s.transfer = &metricStats{hist: NewHistogram()}
+		}
+		s.transfer.merge(o.transfer.summary())
+	}
+	if o.pageLoad != nil {
+		if s.pageLoad == nil {
+			s.pageLoad = &metricStats{hist: NewHistogram()}
+		}
+		s.pageLoad.merge(o.pageLoad.summary())
+	}
+	if o.firstByte != nil {
+		if s.firstByte == nil {
+			s.firstByte = &metricStats{hist: NewHistogram()}
+		}
+		s.firstByte.merge(o.firstByte.summary())
+	}
+	if o.chunkGap != nil {
+		if s.chunkGap == nil {
+			s.chunkGap = &metricStats{hist: NewHistogram()}
+		}
+		s.chunkGap.merge(o.chunkGap.summary())
+	}
+	s.stalls += o.stalls
+	for len(s.timeline) < len(o.timeline) {
+		s.timeline = append(s.timeline, &slot{hist: newHistogram(slotBits)})
+	}
+	for i, sl := range o.timeline {
+		t := s.timeline[i]
+		t.requests += sl.requests
+		t.errors += sl.errors
+		t.opened += sl.opened
+		t.closed += sl.closed
+		t.hist.Merge(sl.hist)
+	}
+}
+
+// Add a result to the timeline slot it completed in
+func (s *Stats) addSlot(r Result) {
+	i := int((r.Start + r.Latency) / TimelineInterval)
+	for len(s.timeline) <= i {
+		s.timeline = append(s.timeline, &slot{hist: newHistogram(slotBits)})
+	}
+	s.timeline[int(r.Start/TimelineInterval)].opened++
+	sl := s.timeline[i]
+	sl.closed++
+	sl.requests++
+	if r.Failed() {
+		sl.errors++
+	}
+	sl.hist.Add(r.Latency)
+}
+
+// Point of the timeline, aggregating the results completing in one interval
+type Point struct {
+	Offset     time.Duration `json:"offset_ns"`
+	Requests   int64         `json:"requests"`
+	Errors     int64         `json:"errors"`
+	Throughput float64       `json:"throughput"`
+	P50        time.Duration `json:"p50_ns"`
+	P99        time.Duration `json:"p99_ns"`
+	Held       int64         `json:"held"` // Requests in flight at the end of the interval, e.g. long polls held open
+}
+
+// Results summarises a run
+type Results struct {
+	URL         string                   `json:"url"`
+	Tags        Tags                     `json:"tags,omitempty"`
+	Seed        int64                    `json:"seed,omitempty"` // Seed of the random choices of the run
+	Requests    int64                    `json:"requests"`
+	Replies     int64                    `json:"replies"`
+	Errors      int64                    `json:"errors"`
+	Retries     int64                    `json:"retries,omitempty"`
+	Hedges      int64                    `json:"hedges,omitempty"`
+	ErrorRate   float64                  `json:"error_rate"`
+	Bytes       int64                    `json:"bytes"`
+	Decoded     int64                    `json:"decoded_bytes,omitempty"` // Decompressed bytes, with compression
+	Saved       int64                    `json:"saved_bytes,omitempty"`   // Bytes not sent thanks to 304 responses
+	Duration    time.Duration            `json:"duration_ns"`
+	Stopped     string                   `json:"stopped,omitempty"` // Why the run stopped early, if it did, e.g. StopMaxErrors
+	Aborted     int64                    `json:"aborted,omitempty"` // Requests cancelled in flight, not otherwise counted
+	Average     time.Duration            `json:"average_ns"`
+	Throughput  float64                  `json:"throughput"`
+	Min         time.Duration            `json:"min_ns"`
+	Mean        time.Duration            `json:"mean_ns"`
+	Max         time.Duration            `json:"max_ns"`
+	Percentiles map[string]time.Duration `json:"percentiles_ns"`
+	Status      map[int]int64            `json:"status"`
+	Checks      map[string]int64         `json:"checks,omitempty"` // Failed response checks
+	Audit       []HeaderAudit            `json:"audit,omitempty"`
+	Expect      map[string]int64         `json:"expect_continue,omitempty"` // Outcomes of Expect: 100-continue
+	GRPC        map[string]int64         `json:"grpc_status,omitempty"`     // gRPC statuses of gRPC calls
+	Compare     map[string]int64         `json:"compare,omitempty"`         // Outcomes of comparing with Config.CompareHost
+	Transfer    *Metric                  `json:"transfer,omitempty"`        // Time to read bodies, for long polls and MaxBandwidth
+	PageLoad    *Metric                  `json:"page_load,omitempty"`       // Time to load pages and their assets
+	FirstByte   *Metric                  `json:"first_byte,omitempty"`      // Time to the first byte of bodies, with Config.Stream
+	ChunkGap    *Metric                  `json:"chunk_gap,omitempty"`       // Gaps between the chunks of bodies, with Config.Stream
+	Stalls      int64                    `json:"stalls,omitempty"`          // Chunk gaps of at least Config.StallThreshold
+	Client      *ClientStats             `json:"client,omitempty"`          // The load generator's own resource use
+	Connections map[string]int64         `json:"connections,omitempty"`     // New connections by network, tcp4 or tcp6
+	DNS         *Metric                  `json:"dns,omitempty"`             // DNS lookup times, if hosts were resolved for every connection
+	Conns       *ConnStats               `json:"conns,omitempty"`           // How requests got their connections
+	Certs       []CertChain              `json:"certs,omitempty"`           // Certificate chain of each HTTPS host
+	Pauses      []Pause                  `json:"pauses,omitempty"`          // Times the breaker paused dispatching
+	// Distributions of the metrics reported in Server-Timing headers
+	ServerTiming map[string]Metric `json:"server_timing,omitempty"`
+	// Distributions of the values of Config.JSONMetrics
+	JSONMetrics map[string]Metric `json:"json_metrics,omitempty"`
+	// Latency by status class, e.g. 5xx, or error for requests without a
+	// response, as errors are often much faster or slower than successes
+	StatusLatency map[string]Metric `json:"status_latency,omitempty"`
+	Histogram     *Histogram        `json:"histogram,omitempty"`
+	Timeline      []Point           `json:"timeline,omitempty"`
+	Phases        []PhaseResults    `json:"phases,omitempty"`
+	Targets       []TargetResults   `json:"targets,omitempty"`
+	Backends      []BackendResults  `json:"backends,omitempty"`
+	Methods       []MethodResults   `json:"methods,omitempty"`
+}
+
+// TargetResults summarises the requests to one target of a run
+type TargetResults struct {
+	Target string `json:"target"`
+	Results
+}
+
+// MethodResults summarises the requests of one method of a mix
+type MethodResults struct {
+	Method string `json:"method"`
+	Results
+}
+
+// BackendResults summarises the requests sent to one server IP address
+type BackendResults struct {
+	Backend string `json:"backend"`
+	Outlier string `json:"outlier,omitempty"` // How the backend stands out from the others, if it does
+	Results
+}
+
+// PhaseResults summarises one phase of a run
+type PhaseResults struct {
+	Phase string `json:"phase"`
+	Results
+}
+
+// NotModifiedRatio is the fraction of requests answered with 304 Not Modified
+func (r Results) NotModifiedRatio() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Status[http.StatusNotModified]) / float64(r.Requests)
+}
+
+// MismatchRate is the fraction of the responses compared with
+// Config.CompareHost that didn't match
+func (r Results) MismatchRate() float64 {
+	var n int64
+	for _, c := range r.Compare {
+		n += c
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(n-r.Compare[CompareMatch]) / float64(n)
+}
+
+// CompressionRatio is the ratio of decompressed to wire bytes, if
+// compression was requested
+func (r Results) CompressionRatio() float64 {
+	if r.Bytes == 0 {
+		return 0
+	}
+	return float64(r.Decoded) / float64(r.Bytes)
+}
+
+// Elapsed returns the end offset of the latest result
+func (s *Stats) Elapsed() time.Duration {
+	return time.Duration(s.last.Load())
+}
+
+// Progress returns the requests and errors so far
+func (s *Stats) Progress() (int64, int64) {
+	return s.requests.Load(), s.errors.Load()
+}
+
+// Summary of a run of url u that took d
+func (s *Stats) Summary(u string, d time.Duration) Results {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests, errors := s.Progress()
+	sum := Results{
+		URL:         u,
+		Requests:    requests,
+		Replies:     requests - errors,
+		Errors:      errors,
+		Retries:     s.retries,
+		Hedges:      s.hedges,
+		Bytes:       s.size,
+		Decoded:     s.decoded,
+		Saved:       s.saved,
+		Duration:    d,
+		Percentiles: make(map[string]time.Duration),
+		Status:      s.status,
+		Checks:      s.checks,
+		Histogram:   s.hist,
+	}
+	if requests > 0 {
+		sum.ErrorRate = float64(errors) / float64(requests)
+	}
+	if sum.Replies > 0 {
+		sum.Average = d / time.Duration(sum.Replies)
+	}
+	if d > 0 {
+		sum.Throughput = float64(sum.Replies) / d.Seconds()
+	}
+	if requests == 0 {
+		return sum
+	}
+	sum.Audit = audits(s.audit)
+	sum.Expect = s.expect
+	sum.GRPC = s.grpc
+	sum.Compare = s.compare
+	if len(s.timing) > 0 {
+		sum.ServerTiming = make(map[string]Metric, len(s.timing))
+		for name, m := range s.timing {
+			sum.ServerTiming[name] = m.summary()
+		}
+	}
+	if len(s.jsonMetrics) > 0 {
+		sum.JSONMetrics = make(map[string]Metric, len(s.jsonMetrics))
+		for name, m := range s.jsonMetrics {
+			sum.JSONMetrics[name] = m.summary()
+		}
+	}
+	sum.StatusLatency = make(map[string]Metric, len(s.classes))
+	for class, m := range s.classes {
+		sum.StatusLatency[class] = m.summary()
+	}
+	if s.transfer != nil {
+		t := s.transfer.summary()
+		sum.Transfer = &t
+	}
+	if s.pageLoad != nil {
+		t := s.pageLoad.summary()
+		sum.PageLoad = &t
+	}
+	if s.firstByte != nil {
+		t := s.firstByte.summary()
+		sum.FirstByte = &t
+	}
+	if s.chunkGap != nil {
+		t := s.chunkGap.summary()
+		sum.ChunkGap = &t
+	}
+	sum.Stalls = s.stalls
+	var held int64
+	for i, sl := range s.timeline {
+		held += sl.opened - sl.closed
+		p := Point{Offset: time.Duration(i) * TimelineInterval, Requests: sl.requests, Errors: sl.errors, Held: held}
+		// The last interval may be cut short by the end of the run
+		iv := TimelineInterval
+		if rest := d - p.Offset; rest > 0 && rest < iv {
+			iv = rest
+		}
+		if sl.requests > 0 {
+			p.Throughput = float64(sl.requests-sl.errors) / iv.Seconds()
+			p.P50, p.P99 = sl.hist.Percentile(50), sl.hist.Percentile(99)
+		}
+		sum.Timeline = append(sum.Timeline, p)
+	}
+	sum.Min, sum.Max = s.min, s.max
+	sum.Mean = s.total / time.Duration(requests)
+	for _, p := range Percentiles {
+		// Within the exact extremes, which the bucket may not be
+		sum.Percentiles[PercentileName(p)] = min(max(s.hist.Percentile(p), s.min), s.max)
+	}
+	return sum
+}
+
+// PercentileName returns the name of a percentile, e.g. p99
+func PercentileName(p float64) string {
+	return fmt.Sprintf("p%g", p)
+}
@@ -0,0 +1,24 @@
+package tensile
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBounded(t *testing.T) {
+	srv := okServer(t)
+	a := NewAttacker(WithURL(srv.URL), WithRequests(10), WithConcurrency(2), WithBounded())
+	if !a.Config().Bounded {
+		t.Fatal("WithBounded not set")
+	}
+	res, err := a.Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Replies != 10 {
+		t.Fatalf("got %d replies, want 10", res.Replies)
+	}
+	if res.Timeline != nil {
+		t.Error("timeline kept when bounded")
+	}
+}
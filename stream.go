@@ -0,0 +1,38 @@
+package tensile
+
+import (
+	"io"
+	"time"
+)
+
+// DefaultStallThreshold is the gap between chunks of a body counted as a
+// stall when Config.StallThreshold is 0
+const DefaultStallThreshold = time.Second
+
+// Reader timing the chunks of a body as they're read from the connection
+type chunkTimer struct {
+	r           io.Reader
+	start, last time.Time
+	stall       time.Duration
+	firstByte   time.Duration   // From start to the first chunk
+	gaps        []time.Duration // Between chunks
+	stalls      int             // Gaps of at least stall
+}
+
+func (c *chunkTimer) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		now := time.Now()
+		if c.last.IsZero() {
+			c.firstByte = now.Sub(c.start)
+		} else {
+			g := now.Sub(c.last)
+			c.gaps = append(c.gaps, g)
+			if g >= c.stall {
+				c.stalls++
+			}
+		}
+		c.last = now
+	}
+	return n, err
+}
@@ -0,0 +1,25 @@
+package tensile
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	srv := okServer(t)
+	a := NewAttacker(WithURL(srv.URL), WithRequests(4), WithConcurrency(1), WithStream(time.Second))
+	if c := a.Config(); !c.Stream || c.StallThreshold != time.Second {
+		t.Fatalf("WithStream set %v, %s", c.Stream, c.StallThreshold)
+	}
+	res, err := a.Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.FirstByte == nil {
+		t.Error("no time to first byte streaming")
+	}
+	if res.Stalls != 0 {
+		t.Errorf("%d stalls, want none", res.Stalls)
+	}
+}
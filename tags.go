@@ -0,0 +1,44 @@
+package tensile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tags are run metadata, e.g. git SHA, environment or build number. Tags
+// implements flag.Value.
+type Tags map[string]string
+
+// String returns sorted key=value pairs, one per line
+func (t Tags) String() string {
+	return strings.Join(t.pairs(), "\n")
+}
+
+// List returns sorted key=value pairs, comma separated, for display
+func (t Tags) List() string {
+	return strings.Join(t.pairs(), ", ")
+}
+
+// Sorted key=value pairs
+func (t Tags) pairs() []string {
+	kv := make([]string, 0, len(t))
+	for k, v := range t {
+		kv = append(kv, k+"="+v)
+	}
+	sort.Strings(kv)
+	return kv
+}
+
+// Set adds a key=value pair, or one per line, so values may contain commas
+func (t Tags) Set(s string) error {
+	for _, kv := range strings.Split(s, "\n") {
+		k, v, ok := strings.Cut(kv, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return fmt.Errorf("tensile: invalid tag %q, expected key=value", kv)
+		}
+		t[k] = strings.TrimSpace(v)
+	}
+	return nil
+}
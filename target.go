@@ -0,0 +1,56 @@
+package tensile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Target is a named URL to attack. Targets with the same name are reported
+// together.
+type Target struct {
+	Name string
+	URL  string
+
+	// If set, the Method, extra Header and Body of requests to the target,
+	// overriding those of the Config, as replayed from a scenario
+	Method string
+	Header http.Header
+	Body   []byte
+}
+
+// ParseTargets reads targets, one per line, as either a URL or a name and a
+// URL separated by whitespace. The name of a bare URL is the URL. Blank lines
+// and lines starting with # are ignored.
+func ParseTargets(r io.Reader) ([]Target, error) {
+	var ts []Target
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		var t Target
+		switch len(f) {
+		case 1:
+			t = Target{Name: f[0], URL: f[0]}
+		case 2:
+			t = Target{Name: f[0], URL: f[1]}
+		default:
+			return nil, fmt.Errorf("tensile: targets line %d: expected [name] URL", n)
+		}
+		if err := validURL(t.URL); err != nil {
+			return nil, fmt.Errorf("tensile: targets line %d: %s", n, err)
+		}
+		ts = append(ts, t)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ts) == 0 {
+		return nil, fmt.Errorf("tensile: no targets")
+	}
+	return ts, nil
+}
@@ -1,247 +1,1240 @@
 /*
-Tensile web stress test tool
+Package tensile is a web stress test library, and the core of the tensile
+web stress test tool.
 
 Mike Hughes 2014
 intermernet AT gmail DOT com
 
 LICENSE BSD 3 Clause
-
- ByteSize function (and bytesize.go) taken from http://golang.org/doc/progs/eff_bytesize.go
- Copyright the Go Authors.
 */
-package main
+package tensile
 
 import (
-	"flag"
-	"fmt"
-	"log"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"hash"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	app     = "Tensile web stress test tool v"
-	version = "0.1"
+	App     = "Tensile web stress test tool v"
+	Version = "0.1"
 )
 
 var (
-	reqs, max, numCPU, maxCPU, numErr, maxErr int
-
-	urlStr, flagErr string
-	reqsError       = "ERROR: -requests (-r) must be greater than 0\n"
-	maxError        = "ERROR: -concurrent (-c) must be greater than 0\n"
-	maxErrError     = "ERROR: -maxerror (-e) must be greater than 0, or -1 for unlimited\n"
-	urlError        = "ERROR: -url (-u) cannot be blank\n"
-	schemeError     = "ERROR: unsupported protocol scheme %s\n"
-	errLimError     = "ERROR: maximum error limit reached: %d\n"
-	errTotalError   = "ERROR: total errors: %d\n"
-	cpuWarn         = "NOTICE: -cpu=%d is greater than the number of CPUs on this system\n\tChanging -cpu to %d\n\n"
-	cpuLTE0Warn     = "NOTICE: -cpu=%d is less than 1\n\tChanging -cpu to 1\n\n"
-	maxGTreqsWarn   = "NOTICE: -concurrent=%d is greater than -requests\n\tChanging -concurrent to %d\n\n"
-
-	wg sync.WaitGroup
+	ErrRequests    = errors.New("tensile: Requests must be greater than 0, or 0 with a Duration")
+	ErrMaxDuration = errors.New("tensile: MaxDuration must not be negative")
+	ErrConcurrent  = errors.New("tensile: Concurrent must be greater than 0")
+	ErrMaxErrors   = errors.New("tensile: MaxErrors must be greater than 0, or -1 for unlimited")
+	ErrURL         = errors.New("tensile: URL must be an absolute http or https URL")
+	ErrRate        = errors.New("tensile: Rate must not be negative")
+	ErrBurst       = errors.New("tensile: Burst must not be negative, and needs a BurstInterval")
+	ErrSHA256      = errors.New("tensile: ExpectSHA256 must be a SHA-256 hash")
+	ErrCompress    = errors.New("tensile: Compress encodings must be gzip, deflate, or br if built with -tags brotli")
+	ErrRange       = errors.New("tensile: RandomRange must not be negative, or set with Range")
+	ErrChunked     = errors.New("tensile: Chunked needs a Body, and ChunkSize and ChunkDelay must not be negative")
+	ErrGRPC        = errors.New("tensile: GRPC needs a Body, the request message")
+	ErrLongPoll    = errors.New("tensile: LongPoll can't be used with Rate, Pattern or Burst")
+	ErrPool        = errors.New("tensile: MaxIdleConns, MaxIdleConnsPerHost, MaxConnsPerHost and TransportShards must not be negative")
+	ErrNetwork     = errors.New("tensile: Network must be tcp4 or tcp6, if set")
+	ErrSocket      = errors.New("tensile: PortMin to PortMax must be a range of ports, and SendBuffer and RecvBuffer must not be negative")
+	ErrMethods     = errors.New("tensile: every one of Methods must have a method and a positive weight")
+	ErrBreaker     = errors.New("tensile: Breaker must have an ErrorRate over 0 and up to 1, and a positive Window and Cooldown")
+	ErrPageAssets  = errors.New("tensile: AssetParallelism must not be negative")
+	ErrCompareHost = errors.New("tensile: CompareHost must be a host or host:port")
+	ErrFuzz        = errors.New("tensile: FuzzMaxLen must not be negative")
+	ErrHeaderSize  = errors.New("tensile: HeaderSize must not be negative")
+	ErrBandwidth   = errors.New("tensile: MaxBandwidth must not be negative")
+	ErrStall       = errors.New("tensile: StallThreshold must not be negative")
+	ErrJSONMetric  = errors.New("tensile: every one of JSONMetrics must have a name, a valid path and a positive unit")
 )
 
-func init() {
-	maxCPU = runtime.NumCPU()
-	flag.IntVar(&numCPU, "cpu", 1, "Number of CPUs")
-	flag.IntVar(&reqs, "requests", 50, "Total requests")
-	flag.IntVar(&reqs, "r", 50, "Total requests (short flag)")
-	flag.IntVar(&max, "concurrent", 5, "Maximum concurrent requests")
-	flag.IntVar(&max, "c", 5, "Maximum concurrent requests (short flag)")
-	flag.IntVar(&maxErr, "maxerror", 1, "Maximum errors before exiting")
-	flag.IntVar(&maxErr, "e", 1, "Maximum errors before exiting (short flag)")
-	flag.StringVar(&urlStr, "url", "http://localhost/", "Target URL")
-	flag.StringVar(&urlStr, "u", "http://localhost/", "Target URL (short flag)")
+// Config of an attack
+type Config struct {
+	URL        string // Target URL, unless Targets is set
+	Requests   int    // Total requests, or 0 for no limit with a Duration
+	Concurrent int    // Maximum concurrent requests
+	MaxErrors  int    // Maximum errors before stopping, -1 for unlimited
+	Tags       Tags   // Metadata recorded in the results
+
+	// Method of every request, GET if empty, and its Body, if any
+	Method string
+	Body   Body
+
+	// If set, dispatching pauses whenever the error rate trips the Breaker.
+	// MaxErrors should be -1, or the run will stop before the breaker trips.
+	Breaker *Breaker
+
+	// If set, the method of each request is chosen at random from Methods by
+	// weight, replacing Method and Body, and the results broken down by
+	// method
+	Methods []MethodWeight
+
+	// If set, the Body is sent with chunked transfer encoding, in chunks of
+	// at most ChunkSize bytes if not 0, with ChunkDelay between them
+	Chunked    bool
+	ChunkSize  int
+	ChunkDelay time.Duration
+
+	// If set, requests with a Body are sent with Expect: 100-continue, and
+	// the body is sent once the server answers 100 Continue, or after
+	// ExpectContinue without an answer
+	ExpectContinue time.Duration
+
+	// If set, requests are unary gRPC calls of the method at the path of the
+	// URL, with the GRPCMessage Body, made over HTTP/2. The gRPC status of
+	// each response is counted, and fails the grpc check unless it is OK.
+	GRPC bool
+
+	// If set, successful responses fail the graphql check if their body has
+	// GraphQL errors, as sent for GraphQLBody requests
+	GraphQL bool
+
+	// If set, requests are long polls: each of Concurrent virtual users polls
+	// again as soon as its last poll is answered, over a connection kept
+	// open for it. Latency is the time each poll was held, the time to read
+	// the response is reported as Transfer, and the timeline counts the polls
+	// held in each interval. Requests have no timeout.
+	LongPoll bool
+
+	// Limits of the connection pool: idle connections kept over all hosts,
+	// or unlimited if 0, idle connections kept per host, or Concurrent if 0,
+	// and connections per host, or unlimited if 0
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+
+	// Workers are spread over TransportShards connection pools, each with
+	// its share of the pool limits, to avoid lock contention in a single
+	// pool at high concurrency. If 0, see Shards.
+	TransportShards int
+
+	// If set, Concurrent keep-alive connections are opened to the host of
+	// each target before the attack starts, so short runs measure steady
+	// state latency rather than connection setup
+	Prewarm bool
+
+	// If set, connections are made from each of LocalAddrs in turn, to use
+	// particular interfaces, or to spread connections over more ephemeral
+	// ports than one address has. Targets given by IP address are reached
+	// from the local addresses of the same family.
+	LocalAddrs []net.IP
+
+	// If set, "tcp4" or "tcp6", connections are only made over IPv4 or IPv6
+	Network string
+
+	// If true, each host is resolved once, and its addresses reused for every
+	// connection. Otherwise it is resolved for every connection, exercising
+	// DNS based load balancing, and the lookup times reported.
+	DNSOnce bool
+
+	// If set, the host:port of the DNS server hosts are resolved with, rather
+	// than the system's
+	DNSServer string
+
+	// Addresses of hosts by lower case name, overriding DNS
+	Hosts map[string][]net.IP
+
+	// If true, connections to hosts with several addresses are spread evenly
+	// over them, rather than made to the first that answers, and the results
+	// broken down by backend address
+	SpreadAddrs bool
+
+	// If true, the results are broken down by backend address, as they are
+	// with SpreadAddrs
+	ByBackend bool
+
+	// If true, TLS sessions are resumed on new connections, with session
+	// tickets or IDs, rather than a full handshake made on each
+	TLSResume bool
+
+	// If set, connections are made from local ports PortMin to PortMax in
+	// turn, rather than ephemeral ports chosen by the system
+	PortMin, PortMax int
+
+	// Socket options of connections, the system defaults if 0 or false.
+	// Nagle turns TCP_NODELAY off. Linger sets SO_LINGER, rounded up to whole
+	// seconds, or if negative resets connections when closed rather than
+	// leaving them in TIME_WAIT. SendBuffer and RecvBuffer set SO_SNDBUF and
+	// SO_RCVBUF, in bytes, once connected.
+	Nagle                  bool
+	Linger                 time.Duration
+	SendBuffer, RecvBuffer int
+
+	// If set, requests are sent to each of Targets in turn, and the results
+	// are also summarised separately for each target
+	Targets []Target
+
+	// If set, no new requests are sent after Duration, letting those in
+	// flight finish
+	Duration time.Duration
+
+	// If set, a hard cap on the whole run, even with Requests: no new
+	// requests are sent after MaxDuration, and any still in flight
+	// DrainTimeout later are cancelled
+	MaxDuration time.Duration
+
+	// Time requests in flight are given to finish once none are sent after
+	// Duration or MaxDuration, before they're cancelled and counted in
+	// Results.Aborted. If 0, they're waited for after Duration and given 5s
+	// after MaxDuration. If negative, they're cancelled at once.
+	DrainTimeout time.Duration
+
+	// If set, requests are sent at Rate per second, limited by Concurrent
+	Rate float64
+
+	// If set, Pattern varies the rate over the attack, overriding Rate
+	Pattern Pattern
+
+	// If set, requests are sent in bursts of Burst every BurstInterval,
+	// overriding Rate and Pattern. Concurrent should be at least Burst.
+	Burst         int
+	BurstInterval time.Duration
+
+	// If set, memory use is bounded however long the attack runs, by leaving
+	// out the timeline
+	Bounded bool
+
+	// Results are also summarised separately for each of Phases. If nil, the
+	// phases of Pattern are used if it is a Phaser.
+	Phases []Phase
+
+	// Diagnostics are logged to Logger, or slog.Default() if nil
+	Logger *slog.Logger
+
+	// The request and response headers of the first Debug exchanges are
+	// dumped to DebugOutput, or stderr if nil, including the bodies if
+	// DebugBodies is set
+	Debug       int
+	DebugBodies bool
+	DebugOutput io.Writer
+
+	// The request, status, headers and body of up to SaveErrorsMax failed
+	// exchanges are saved to files in the SaveErrors directory
+	SaveErrors    string
+	SaveErrorsMax int
+
+	// Transient failures are retried up to Retries times, with exponential
+	// backoff from RetryBackoff and jitter. Only idempotent methods are
+	// retried unless RetryAll is set. Latency includes the retries.
+	Retries      int
+	RetryBackoff time.Duration
+	RetryAll     bool
+
+	// Requests taking at least SlowLog are logged with a breakdown of their
+	// timings and the response headers
+	SlowLog time.Duration
+
+	// If a request hasn't answered within Hedge a duplicate is sent, and
+	// whichever answers first is used
+	Hedge time.Duration
+
+	// If set, responses with a successful status fail the checksum check
+	// unless the SHA-256 hash of their body is ExpectSHA256
+	ExpectSHA256 []byte
+
+	// Successful responses fail the header check unless their headers pass
+	// every one of HeaderChecks
+	HeaderChecks []HeaderCheck
+
+	// If set, Compress is sent as Accept-Encoding and compressed bodies are
+	// decompressed, up to MaxDecoded bytes or DefaultMaxDecoded if 0, to
+	// report both the wire and decompressed sizes. gzip and deflate are
+	// supported, and br when built with -tags brotli.
+	Compress   []string
+	MaxDecoded int64
+
+	// If set, requests are sent with each of UserAgents in turn, rather than
+	// tensile's own, such as BrowserUserAgents
+	UserAgents []string
+
+	// If set, the chunks of response bodies are timed as they're read from
+	// the connection, for streaming responses such as video or generated
+	// text: the time to the first byte of the body and the gaps between
+	// chunks are reported, and gaps of at least StallThreshold, or
+	// DefaultStallThreshold if 0, counted as stalls
+	Stream         bool
+	StallThreshold time.Duration
+
+	// If set, each response body is read at up to MaxBandwidth bytes a
+	// second, as a slow client would, to test the buffering and write
+	// timeouts of servers
+	MaxBandwidth int64
+
+	// If set, requests are padded with headers of HeaderSize bytes in all, to
+	// test the header limits and buffers of servers and proxies
+	HeaderSize int
+
+	// If set, the durations at the paths of JSONMetrics in the JSON bodies of
+	// successful responses, such as timings the server reports, are
+	// reported as their own distributions
+	JSONMetrics []JSONMetric
+
+	// If set, RequestModifier is called with each request before it's sent,
+	// and ResponseValidator with each response, to sign requests or assert
+	// responses in code. Both are called concurrently by the workers.
+	RequestModifier   RequestModifier
+	ResponseValidator ResponseValidator
+
+	// If set, the random choices of the attack are made from Seed, so a run
+	// can be repeated: the methods of a mix, cache busting and fuzzed values
+	// for each request, random ranges, retry jitter and the data of random
+	// Synthetic bodies. Otherwise a random seed is used. Either way, it's reported in Results.Seed.
+	Seed int64
+
+	// If set, each request carries a random value for each of FuzzHeaders
+	// and the query parameters FuzzParams, of up to FuzzMaxLen bytes or
+	// DefaultFuzzMaxLen if 0, to test robustness under load
+	FuzzHeaders []string
+	FuzzParams  []string
+	FuzzMaxLen  int
+
+	// If set, a random CacheBustParam is added to the query of every request,
+	// so requests bypass caches and reach the origin
+	CacheBust bool
+
+	// If set, every request is for Range, or a random range of RandomRange
+	// bytes, and responses fail the range check unless they are 206 Partial
+	// Content of that range
+	Range       *ByteRange
+	RandomRange int64
+
+	// If set, requests are made conditional on the ETag and Last-Modified of
+	// the latest full response from their target, to measure 304 responses
+	// and the bandwidth they save
+	Revalidate bool
+
+	// If set, the scripts, images, stylesheets and icons of successful HTML
+	// responses from the origin of the page are then fetched as a browser
+	// would, AssetParallelism at a time or DefaultAssetParallelism if 0, and
+	// the time to load the page and its assets reported as PageLoad. Asset
+	// bytes are counted in the size of the page, and a failed asset fails
+	// the asset check.
+	PageAssets       bool
+	AssetParallelism int
+
+	// If set, every request is also sent to CompareHost, a host or host:port
+	// in place of that of the target, and the two responses compared: their
+	// statuses, headers other than those of the connection, Content-Length
+	// and CompareIgnore, and bodies. The outcomes are counted in
+	// Results.Compare. Only the target's responses are otherwise measured.
+	CompareHost   string
+	CompareIgnore []string
+
+	// The values of AuditHeaders are counted over all responses, to find
+	// inconsistencies between the servers behind the target
+	AuditHeaders []string
+
+	// If not nil every result is sent on Results as it arrives. The channel
+	// must be drained by the caller, and is closed when the attack ends.
+	Results chan<- Result
+}
+
+// Validate the config
+func (c Config) Validate() error {
+	switch {
+	case c.Requests < 0 || (c.Requests == 0 && c.Duration <= 0):
+		return ErrRequests
+	case c.Concurrent <= 0:
+		return ErrConcurrent
+	case c.MaxDuration < 0:
+		return ErrMaxDuration
+	case c.MaxErrors == 0 || c.MaxErrors < -1:
+		return ErrMaxErrors
+	case c.Rate < 0 || (c.Pattern != nil && c.Pattern.Rate(0) < 0):
+		return ErrRate
+	case c.Burst < 0 || (c.Burst > 0 && c.BurstInterval <= 0):
+		return ErrBurst
+	case len(c.ExpectSHA256) != 0 && len(c.ExpectSHA256) != sha256.Size:
+		return ErrSHA256
+	case c.RandomRange < 0 || (c.RandomRange > 0 && c.Range != nil):
+		return ErrRange
+	case c.Chunked && (c.Body == nil || c.ChunkSize < 0 || c.ChunkDelay < 0):
+		return ErrChunked
+	case c.GRPC && c.Body == nil:
+		return ErrGRPC
+	case c.LongPoll && (c.Rate > 0 || c.Pattern != nil || c.Burst > 0):
+		return ErrLongPoll
+	case c.MaxIdleConns < 0 || c.MaxIdleConnsPerHost < 0 || c.MaxConnsPerHost < 0 || c.TransportShards < 0:
+		return ErrPool
+	case c.PortMin < 0 || c.PortMax > 65535 || c.PortMin > c.PortMax || (c.PortMin == 0) != (c.PortMax == 0) || c.SendBuffer < 0 || c.RecvBuffer < 0:
+		return ErrSocket
+	case c.Network != "" && c.Network != "tcp4" && c.Network != "tcp6":
+		return ErrNetwork
+	case c.AssetParallelism < 0:
+		return ErrPageAssets
+	case strings.ContainsAny(c.CompareHost, "/?#@ "):
+		return ErrCompareHost
+	case c.FuzzMaxLen < 0:
+		return ErrFuzz
+	case c.HeaderSize < 0:
+		return ErrHeaderSize
+	case c.MaxBandwidth < 0:
+		return ErrBandwidth
+	case c.StallThreshold < 0:
+		return ErrStall
+	}
+	for _, m := range c.Methods {
+		if m.Method == "" || m.Weight <= 0 {
+			return ErrMethods
+		}
+	}
+	for _, m := range c.JSONMetrics {
+		if _, err := parseJSONPath(m.Path); err != nil || m.Name == "" || m.Unit <= 0 {
+			return ErrJSONMetric
+		}
+	}
+	if b := c.Breaker; b != nil && (b.ErrorRate <= 0 || b.ErrorRate > 1 || b.Window <= 0 || b.Cooldown <= 0) {
+		return ErrBreaker
+	}
+	for _, e := range c.Compress {
+		if encodings[e] == nil {
+			return ErrCompress
+		}
+	}
+	if len(c.Targets) == 0 {
+		return validURL(c.URL)
+	}
+	for _, t := range c.Targets {
+		if err := validURL(t.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check a target URL is absolute http or https
+func validURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrURL
+	}
+	return nil
+}
+
+// Request to a target
+type request struct {
+	*http.Request
+	target int
+	method int     // Index into cfg.Methods, if set
+	rand   reqRand // For random ranges and retry jitter
 }
 
 type response struct {
 	*http.Response
-	err error
+	err      error
+	target   int
+	method   int
+	rand     reqRand
+	start    time.Time
+	latency  time.Duration
+	retries  int
+	hedges   int
+	size     int64                    // Body bytes read
+	decoded  int64                    // Decompressed body bytes, if cfg.Compress is set
+	cached   int64                    // Size of the representation revalidated, or -1
+	rng      *ByteRange               // Range requested, if any
+	expect   string                   // Outcome of Expect: 100-continue, if sent
+	grpc     string                   // gRPC status name, if a gRPC call
+	transfer time.Duration            // Time to read the body, for long polls and MaxBandwidth
+	pageLoad time.Duration            // Time to load the page and its assets
+	chunks   *chunkTimer              // Times of the chunks of the body, with cfg.Stream
+	metrics  map[string]time.Duration // Values of cfg.JSONMetrics in the body
+	page     *bytes.Buffer            // HTML body, to find its assets
+	sum      hash.Hash                // Of the body, to compare
+	compare  string                   // Outcome of comparing, e.g. CompareMatch
+	backend  string                   // IP address of the server, if traced
+	wire     countReader              // Body as read by check
+
+	check    string // Failed check, if any
+	checkErr error
 }
 
-// Close response Body
-func (r *response) closeBody() {
+// Close response Body, if there is one
+func (r *response) closeBody(l *slog.Logger) {
+	if r.Response == nil {
+		return
+	}
 	if err := r.Body.Close(); err != nil {
-		log.Println(r.err)
+		l.Warn("closing response body", "err", err)
 	}
 }
 
-// Dispatcher
-func dispatcher(reqChan chan *http.Request, quit chan bool) {
-	defer close(reqChan)
-	for i := 0; i < reqs; i++ {
-		req, err := http.NewRequest("GET", urlStr, nil)
+// Statistics of the results of one transport shard's workers
+type accum struct {
+	st       *Stats
+	phases   []*Stats
+	targets  []*Stats
+	methods  []*Stats
+	mu       sync.Mutex
+	backends map[string]*Stats // By server IP address, if traced
+}
+
+// Requests and errors so far
+func (a *attack) progress() (requests, errors int64) {
+	if shards := a.live.Load(); shards != nil {
+		for _, s := range *shards {
+			r, e := s.st.Progress()
+			requests += r
+			errors += e
+		}
+	}
+	return requests, errors
+}
+
+// State of a single attack
+type attack struct {
+	cfg         Config
+	log         *slog.Logger
+	wg          sync.WaitGroup
+	numErr      atomic.Int64
+	aborted     atomic.Int64 // Requests cancelled in flight
+	shards      []*accum
+	live        atomic.Pointer[[]*accum] // Shards, once started, for progress
+	sched       schedule                 // Written by the dispatcher
+	dial        *dialer
+	methodTotal int // Sum of the weights of cfg.Methods
+	seed        int64
+	rng         *rand.Rand   // Random choices of the dispatcher, from seed
+	jsonPaths   [][]jsonStep // Paths of cfg.JSONMetrics
+	breaker     *breaker     // If cfg.Breaker is set
+	stopped     atomic.Pointer[string]
+	conns       connCounter
+	warmed      int64           // Connections opened by prewarming
+	protos      []*http.Request // By target
+	groups      []int           // Index in named of each target
+	named       []Target        // Targets by name, for their statistics
+	reqs        sync.Pool       // Requests for reuse
+	start       time.Time
+	cancel      context.CancelFunc
+
+	failMu     sync.Mutex
+	prevStatus int    // Status of the last error response logged
+	prevCheck  string // Last response check failure logged
+
+	prevMismatch string          // Last kind of mismatch logged
+	ignore       map[string]bool // Headers not compared
+
+	dumpMu sync.Mutex
+	dumped int
+	saved  int
+
+	validMu sync.Mutex
+	valid   map[int]validator // By target, if cfg.Revalidate is set
+	sizes   map[int]int64     // By target, learnt from range requests
+}
+
+// Group the targets by name. A group of several URLs is reported by name.
+func (a *attack) groupTargets() {
+	a.groups = make([]int, len(a.cfg.Targets))
+	a.named = nil
+	byName := make(map[string]int)
+	for i, tg := range a.cfg.Targets {
+		g, ok := byName[tg.Name]
+		if !ok {
+			g = len(a.named)
+			byName[tg.Name] = g
+			a.named = append(a.named, tg)
+		} else if a.named[g].URL != tg.URL {
+			a.named[g].URL = tg.Name
+		}
+		a.groups[i] = g
+	}
+}
+
+// Prototype request for each target, copied by the dispatcher rather than
+// building every request from scratch
+func (a *attack) prototypes(ctx context.Context) error {
+	a.protos = make([]*http.Request, len(a.cfg.Targets))
+	a.groupTargets()
+	for i, tg := range a.cfg.Targets {
+		method := a.cfg.Method
+		if tg.Method != "" {
+			method = tg.Method
+		}
+		req, err := http.NewRequestWithContext(ctx, method, tg.URL, nil)
 		if err != nil {
-			log.Println(err)
+			return err
 		}
-		select {
-		case <-quit:
-			return
-		default:
-			req.Header.Add("User-Agent", app+version)
-			reqChan <- req
+		req.Header.Add("User-Agent", App+Version)
+		for k, vs := range tg.Header {
+			req.Header[k] = vs
+		}
+		if len(a.cfg.Compress) > 0 {
+			req.Header.Set("Accept-Encoding", strings.Join(a.cfg.Compress, ", "))
+		}
+		if a.cfg.GRPC {
+			req.Header.Set("TE", "trailers")
 		}
+		if a.cfg.HeaderSize > 0 {
+			padHeader(req.Header, a.cfg.HeaderSize)
+		}
+		a.protos[i] = req
 	}
+	return nil
 }
 
-// Worker Pool
-func workerPool(reqChan chan *http.Request, respChan chan response, quit chan bool) {
-	defer close(respChan)
-	t := &http.Transport{}
-	defer t.CloseIdleConnections()
-	defer wg.Wait()
-	for i := 0; i < max; i++ {
-		wg.Add(1)
-		go worker(t, reqChan, respChan, quit)
+// Request to target t, a copy of its prototype reusing a pooled request if
+// there is one
+func (a *attack) newRequest(t int) *http.Request {
+	p := a.protos[t]
+	req, _ := a.reqs.Get().(*http.Request)
+	if req == nil {
+		return p.Clone(p.Context())
+	}
+	h, u := req.Header, req.URL
+	*req = *p
+	clear(h)
+	for k, v := range p.Header {
+		h[k] = v[:len(v):len(v)]
 	}
+	*u = *p.URL
+	req.Header, req.URL = h, u
+	return req
 }
 
-// Worker
-func worker(t *http.Transport, reqChan chan *http.Request, respChan chan response, quit chan bool) {
-	defer wg.Done()
-	for {
+// Return req to the pool for reuse once the transport is done with it: its
+// response body is closed, and no failed attempt may still be writing it
+func (a *attack) recycle(req *http.Request, r *response) {
+	if r.err == nil && r.retries == 0 {
+		a.reqs.Put(req)
+	}
+}
+
+// Reasons a run stopped early, in Results.Stopped
+const (
+	StopMaxErrors   = "max errors"   // MaxErrors was reached
+	StopMaxDuration = "max duration" // MaxDuration ended the run before its Requests or Duration
+)
+
+// Time requests in flight are given to finish after MaxDuration, by default
+const maxDurationDrain = 5 * time.Second
+
+// Record why the run stopped early, if it hasn't already
+func (a *attack) stop(reason string) {
+	a.stopped.CompareAndSwap(nil, &reason)
+}
+
+// Time after which no new requests are sent, or 0 for none
+func (a *attack) end() time.Duration {
+	d := a.cfg.Duration
+	if m := a.cfg.MaxDuration; m > 0 && (d == 0 || m < d) {
+		d = m
+	}
+	return d
+}
+
+// Whether MaxDuration ends the run before Duration would
+func (a *attack) capped() bool {
+	m, d := a.cfg.MaxDuration, a.cfg.Duration
+	return m > 0 && (d == 0 || m < d)
+}
+
+// Time requests in flight are given to finish after end, if they're to be
+// cancelled at all
+func (a *attack) drain() (time.Duration, bool) {
+	switch d := a.cfg.DrainTimeout; {
+	case d > 0:
+		return d, true
+	case d < 0:
+		return 0, true
+	case a.cfg.MaxDuration > 0:
+		return maxDurationDrain, true
+	}
+	return 0, false
+}
+
+// Dispatcher
+func (a *attack) dispatcher(ctx context.Context, reqChan chan<- request) {
+	defer close(reqChan)
+	sent := false
+	defer func() {
+		if !sent && ctx.Err() == nil && a.capped() {
+			a.stop(StopMaxDuration)
+		}
+	}()
+	var deadline <-chan time.Time
+	if d := a.end(); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		deadline = t.C
+	}
+	next := time.Now()
+	paced := a.cfg.Burst > 0 || a.cfg.Rate > 0 || a.cfg.Pattern != nil
+	for i := 0; a.cfg.Requests == 0 || i < a.cfg.Requests; i++ {
+		var due time.Time
+		ok := true
+		switch {
+		case a.cfg.Burst > 0:
+			due, ok = a.burst(ctx, deadline, i)
+		case paced:
+			due, ok = a.pace(ctx, deadline, &next)
+		}
+		if !ok {
+			return
+		}
+		if a.breaker != nil {
+			paused, ok := a.breaker.wait(ctx, deadline)
+			if !ok {
+				return
+			}
+			if paused {
+				// Resume at the current rate, rather than catching up
+				next = time.Now()
+			}
+		}
+		t := i % len(a.cfg.Targets)
+		req := a.newRequest(t)
+		if a.cfg.CacheBust {
+			cacheBust(a.rng, req.URL)
+		}
+		if len(a.cfg.UserAgents) > 0 {
+			req.Header["User-Agent"] = []string{a.cfg.UserAgents[i%len(a.cfg.UserAgents)]}
+		}
+		if len(a.cfg.FuzzHeaders) > 0 || len(a.cfg.FuzzParams) > 0 {
+			a.fuzz(req)
+		}
+		rq := request{Request: req, target: t}
+		if a.cfg.RandomRange > 0 || a.cfg.Retries > 0 {
+			rq.rand = reqRand(a.rng.Uint64())
+		}
+		if len(a.cfg.Methods) > 0 {
+			rq.method = a.pickMethod()
+			req.Method = a.cfg.Methods[rq.method].Method
+		}
+		blocked := false
 		select {
-		case req, ok := <-reqChan:
-			if ok {
-				resp, err := t.RoundTrip(req)
-				respChan <- response{resp, err}
-			} else {
+		case reqChan <- rq:
+		default:
+			// Every worker is busy
+			blocked = true
+			select {
+			case <-ctx.Done():
+				return
+			case <-deadline:
 				return
+			case reqChan <- rq:
 			}
-		case <-quit:
-			return
+		}
+		if paced {
+			a.sched.sent(due, blocked)
 		}
 	}
+	sent = true
 }
 
-// Kill Workers
-func killWorkers(quit chan bool) {
-	for {
-		select {
-		case quit <- true:
-		default:
-			return
+// Workers per transport shard when TransportShards is 0
+const shardWorkers = 256
+
+// Shards is the number of transport shards used: TransportShards, or if 0
+// one for every 256 workers, up to GOMAXPROCS
+func (c Config) Shards() int {
+	n := c.TransportShards
+	if n == 0 {
+		n = min((c.Concurrent+shardWorkers-1)/shardWorkers, runtime.GOMAXPROCS(0))
+	}
+	return max(1, min(n, c.Concurrent))
+}
+
+// Share of n of each of shards, rounded up, or 0 for unlimited
+func share(n, shards int) int {
+	return (n + shards - 1) / shards
+}
+
+// Transports shared by the workers, one per shard
+func (a *attack) transports() []*http.Transport {
+	n := a.cfg.Shards()
+	idlePerHost := a.cfg.MaxIdleConnsPerHost
+	if idlePerHost == 0 {
+		// Rather than http.DefaultMaxIdleConnsPerHost, which would close
+		// all but 2 connections as soon as they are idle
+		idlePerHost = a.cfg.Concurrent
+	}
+	a.dial = a.dialer()
+	ts := make([]*http.Transport, n)
+	var tlsConf *tls.Config
+	if a.cfg.TLSResume {
+		tlsConf = &tls.Config{ClientSessionCache: tls.NewLRUClientSessionCache(0)}
+	}
+	for i := range ts {
+		ts[i] = &http.Transport{
+			ExpectContinueTimeout: a.cfg.ExpectContinue,
+			MaxIdleConns:          share(a.cfg.MaxIdleConns, n),
+			MaxIdleConnsPerHost:   share(idlePerHost, n),
+			MaxConnsPerHost:       share(a.cfg.MaxConnsPerHost, n),
+			DialContext:           a.dial.dial,
+			TLSClientConfig:       tlsConf,
+			ForceAttemptHTTP2:     true,
+		}
+		if a.cfg.GRPC {
+			ts[i].Protocols = grpcProtocols()
 		}
 	}
+	a.log.Debug("connection pools", "shards", n, "workers", share(a.cfg.Concurrent, n),
+		"max_idle_conns_per_host", ts[0].MaxIdleConnsPerHost, "max_conns_per_host", ts[0].MaxConnsPerHost)
+	return ts
 }
 
-// Check maximum error count
-func checkMaxErr(quit chan bool) bool {
-	chk := false
-	numErr++
-	if numErr >= maxErr && maxErr != -1 {
-		killWorkers(quit)
-		log.Printf(errLimError, numErr)
-		chk = true
+// Worker Pool, with workers spread over the transport shards
+func (a *attack) workerPool(ctx context.Context, ts []*http.Transport, reqChan <-chan request, resChan chan<- []Result) {
+	defer close(resChan)
+	defer func() {
+		for _, t := range ts {
+			t.CloseIdleConnections()
+		}
+	}()
+	defer a.wg.Wait()
+	for i := 0; i < a.cfg.Concurrent; i++ {
+		a.wg.Add(1)
+		go a.worker(ctx, ts[i%len(ts)], a.shards[i%len(ts)], reqChan, resChan)
 	}
-	return chk
 }
 
-// Consumer
-func consumer(respChan chan response, quit chan bool) (int64, int64) {
-	defer close(quit)
+// Most results a worker sends to the consumer at once
+const resultBatch = 64
+
+// Longest a result is held in a partial batch, while others are added to it
+const resultFlush = 100 * time.Millisecond
+
+// Worker, adding its results to the statistics of its shard. Results are
+// only sent on to the consumer if they are streamed, in batches of up to
+// resultBatch. A partial batch is sent whenever no request is waiting, so
+// results aren't held back when the attack is paced, and once its first
+// result is resultFlush old, so they aren't held back by slow targets.
+func (a *attack) worker(ctx context.Context, t *http.Transport, acc *accum, reqChan <-chan request, resChan chan<- []Result) {
+	defer a.wg.Done()
 	var (
-		conns, size int64
-		prevStatus  int
+		batch []Result
+		first time.Time
 	)
-	for r := range respChan {
-		switch {
-		case r.err != nil:
-			log.Println(r.err)
-			if checkMaxErr(quit) {
-				return conns, size
-			}
-		case r.StatusCode >= 400:
-			if r.StatusCode != prevStatus {
-				log.Printf("ERROR: %s\n", r.Status)
-			}
-			prevStatus = r.StatusCode
-			if checkMaxErr(quit) {
-				return conns, size
-			}
+	send := func() {
+		if len(batch) > 0 {
+			resChan <- batch
+			batch = make([]Result, 0, resultBatch)
+		}
+	}
+	for {
+		var rq request
+		var ok bool
+		select {
+		case rq, ok = <-reqChan:
 		default:
-			rSize := r.ContentLength
-			if rSize >= 0 {
-				size += rSize
+			send()
+			rq, ok = <-reqChan
+		}
+		if !ok {
+			send()
+			return
+		}
+		r := a.do(ctx, t, rq)
+		res, ok := a.account(ctx, acc, r)
+		r.closeBody(a.log)
+		a.recycle(rq.Request, r)
+		*r = response{}
+		responses.Put(r)
+		if ok && a.cfg.Results != nil {
+			if len(batch) == 0 {
+				first = time.Now()
+			}
+			batch = append(batch, res)
+			if len(batch) == resultBatch || time.Since(first) >= resultFlush {
+				send()
 			}
-			conns++
 		}
-		r.closeBody()
 	}
-	return conns, size
 }
 
-func checkFlags() {
-	flag.Parse()
-	// Flag Errors
-	if reqs <= 0 {
-		flagErr += reqsError
+// Responses, reused from one request to the next
+var responses = sync.Pool{New: func() any { return new(response) }}
+
+// Make a request, and check the response, which is from the responses pool
+func (a *attack) do(ctx context.Context, t *http.Transport, rq request) *response {
+	req := rq.Request
+	var tm *timings
+	if a.cfg.SlowLog > 0 {
+		tm = &timings{}
+		req = tm.trace(req)
+	}
+	r := responses.Get().(*response)
+	*r = response{target: rq.target, method: rq.method, rand: rq.rand, start: time.Now(), cached: -1}
+	if a.cfg.Revalidate {
+		r.cached = a.conditional(req, rq.target)
+	}
+	if a.cfg.Range != nil || a.cfg.RandomRange > 0 {
+		rng := a.nextRange(rq.target, rq.rand)
+		r.rng = &rng
+		req.Header.Set("Range", rng.String())
+	}
+	var bt *backendTrace
+	if a.cfg.SpreadAddrs || a.cfg.ByBackend {
+		bt = &backendTrace{}
+		req = bt.trace(req)
+	}
+	body := a.cfg.Body
+	if len(a.cfg.Methods) > 0 {
+		body = a.cfg.Methods[rq.method].Body
+	}
+	if tg := a.cfg.Targets[rq.target]; tg.Body != nil {
+		body = Bytes{tg.Body, tg.Header.Get("Content-Type")}
+	}
+	var ec *expectTrace
+	if body != nil {
+		r.err = a.setBody(req, body)
+		if r.err == nil && a.cfg.ExpectContinue > 0 && req.Body != http.NoBody {
+			ec = &expectTrace{}
+			req = ec.trace(req)
+		}
+	}
+	if r.err == nil && a.cfg.RequestModifier != nil {
+		r.err = a.cfg.RequestModifier.ModifyRequest(req)
+	}
+	if r.err == nil {
+		a.roundTrip(ctx, t, req, r)
+	}
+	r.latency = time.Since(r.start)
+	if bt != nil {
+		if r.backend = bt.backend(); r.backend == "" && r.err != nil {
+			r.backend = dialBackend(r.err)
+		}
+	}
+	if ec != nil && r.err == nil {
+		r.expect = ec.outcome()
+	}
+	if tm != nil && r.latency >= a.cfg.SlowLog {
+		a.logSlow(req, r, tm)
+	}
+	if a.cfg.Debug > 0 {
+		a.dump(req, r.Response, r.err)
+	}
+	if a.cfg.SaveErrors != "" && (r.err != nil || r.StatusCode >= 400) {
+		a.saveError(req, r.Response, r.err)
+	}
+	if r.err == nil {
+		a.check(r)
+		if a.cfg.LongPoll || a.cfg.MaxBandwidth > 0 {
+			r.transfer = time.Since(r.start) - r.latency
+		}
+		if r.page != nil && r.check == "" {
+			a.loadAssets(t, rq.Request, r)
+		}
+		if a.cfg.CompareHost != "" {
+			a.compare(t, rq.Request, body, r)
+		}
+		if a.cfg.Revalidate && r.StatusCode == http.StatusOK {
+			a.validate(rq.target, r)
+		}
+		if a.cfg.SaveErrors != "" && r.check != "" && r.StatusCode < 400 {
+			a.saveError(req, r.Response, r.checkErr)
+		}
+	}
+	return r
+}
+
+// Result of a response
+func (a *attack) result(r *response) Result {
+	res := Result{Start: r.start.Sub(a.start), Latency: r.latency, Transfer: r.transfer, PageLoad: r.pageLoad, Retries: r.retries, Hedges: r.hedges, Backend: r.backend}
+	if c := r.chunks; c != nil && !c.last.IsZero() {
+		res.FirstByte, res.ChunkGaps, res.Stalls = c.firstByte, c.gaps, c.stalls
+	}
+	if len(a.cfg.Targets) > 1 {
+		res.Target = a.cfg.Targets[r.target].Name
+	}
+	if len(a.cfg.Methods) > 0 {
+		res.Method = a.cfg.Methods[r.method].Method
+	}
+	if r.err != nil {
+		res.Err = r.err.Error()
+	} else {
+		res.Status = r.StatusCode
+		res.Size = r.size
+		res.Decoded = r.decoded
+		res.Expect = r.expect
+		res.GRPC = r.grpc
+		res.Compare = r.compare
+		if r.StatusCode == http.StatusNotModified && r.cached > 0 {
+			res.Saved = r.cached
+		}
+		res.ServerTiming = parseServerTiming(r.Header)
+		res.JSONMetrics = r.metrics
+		if len(a.cfg.AuditHeaders) > 0 {
+			res.Headers = auditHeaders(a.cfg.AuditHeaders, r.Header)
+		}
+	}
+	if r.check != "" {
+		res.Check, res.Err = r.check, r.checkErr.Error()
+	}
+	return res
+}
+
+// Add the result of a response to the statistics of acc, reporting whether
+// it was counted. Once the maximum error count is reached the attack is
+// cancelled, and no further results are counted.
+func (a *attack) account(ctx context.Context, acc *accum, r *response) (Result, bool) {
+	if ctx.Err() != nil {
+		a.aborted.Add(1)
+		return Result{}, false
 	}
-	if max <= 0 {
-		flagErr += maxError
+	res := a.result(r)
+	var n int64
+	if res.Failed() {
+		n = a.numErr.Add(1)
+		if a.cfg.MaxErrors != -1 && n > int64(a.cfg.MaxErrors) {
+			return res, false
+		}
+		a.logFailure(r)
 	}
-	if maxErr == 0 || maxErr < -1 {
-		flagErr += maxErrError
+	acc.st.Add(res)
+	if len(acc.targets) > 0 {
+		acc.targets[a.groups[r.target]].Add(res)
 	}
-	if urlStr == "" {
-		flagErr += urlError
+	if len(acc.methods) > 0 {
+		acc.methods[r.method].Add(res)
 	}
-	u, err := url.Parse(urlStr)
-	if err != nil {
-		flagErr += err.Error()
+	if res.Backend != "" {
+		acc.backend(a, res.Backend).Add(res)
 	}
-	if u.Scheme != "http" && u.Scheme != "https" {
-		flagErr += fmt.Sprintf(schemeError, u.Scheme)
+	for i, p := range a.cfg.Phases {
+		if res.Start >= p.Start && (p.End == 0 || res.Start < p.End) {
+			acc.phases[i].Add(res)
+		}
 	}
-	if flagErr != "" {
-		log.Fatal(fmt.Errorf("\n%s", flagErr))
+	if a.breaker != nil {
+		if p, ok := a.breaker.add(res.Failed()); ok {
+			a.log.Warn("error rate tripped the breaker, pausing", "error_rate", p.ErrorRate, "cooldown", p.Duration)
+		}
 	}
-	// Flag Warnings
-	if numCPU > maxCPU {
-		fmt.Printf(cpuWarn, numCPU, maxCPU)
-		numCPU = maxCPU
+	if a.cfg.MaxErrors != -1 && n == int64(a.cfg.MaxErrors) {
+		a.stop(StopMaxErrors)
+		a.cancel()
+		a.log.Error("maximum error limit reached", "errors", n)
 	}
-	if numCPU < 1 {
-		fmt.Printf(cpuLTE0Warn, numCPU)
-		numCPU = 1
+	return res, true
+}
+
+// Log a failed response, at debug level if it failed like the last
+func (a *attack) logFailure(r *response) {
+	a.failMu.Lock()
+	defer a.failMu.Unlock()
+	switch {
+	case r.err != nil:
+		a.log.Error("request failed", "err", r.err)
+	case r.check != "" && r.StatusCode < 400:
+		if r.check != a.prevCheck {
+			a.log.Error("response check failed", "check", r.check, "err", r.checkErr)
+		} else {
+			a.log.Debug("response check failed", "check", r.check, "err", r.checkErr)
+		}
+		a.prevCheck = r.check
+	case r.StatusCode >= 400:
+		if r.StatusCode != a.prevStatus {
+			a.log.Error("error response", "status", r.Status)
+		} else {
+			a.log.Debug("error response", "status", r.Status)
+		}
+		a.prevStatus = r.StatusCode
 	}
-	if max > reqs {
-		fmt.Printf(maxGTreqsWarn, max, reqs)
-		max = reqs
+}
+
+// Consumer, streaming the results to cfg.Results until the workers exit
+func (a *attack) consumer(resChan <-chan []Result) {
+	for rs := range resChan {
+		for _, r := range rs {
+			a.cfg.Results <- r
+		}
 	}
 }
 
-func main() {
-	checkFlags()
-	fmt.Printf("\n\t%s\n\n", app+version)
-	runtime.GOMAXPROCS(numCPU)
-	reqChan := make(chan *http.Request)
-	respChan := make(chan response)
-	quit := make(chan bool, max)
-	fmt.Printf("Target URL:\t%s\nRequests:\t%d\nConcurrent:\t%d\nProcessors:\t%d\n\n", urlStr, reqs, max, numCPU)
-	start := time.Now()
-	go dispatcher(reqChan, quit)
-	go workerPool(reqChan, respChan, quit)
-	fmt.Printf("Waiting for replies...\n\n")
-	conns, size := consumer(respChan, quit)
-	if numErr > 0 {
-		log.Printf(errTotalError, numErr)
+// Statistics of all the shards
+func (a *attack) total() *accum {
+	if len(a.shards) == 1 {
+		return a.shards[0]
+	}
+	t := a.newAccum()
+	for _, s := range a.shards {
+		t.st.merge(s.st)
+		for i, st := range s.phases {
+			t.phases[i].merge(st)
+		}
+		for i, st := range s.targets {
+			t.targets[i].merge(st)
+		}
+		for i, st := range s.methods {
+			t.methods[i].merge(st)
+		}
+		for b, st := range s.backends {
+			t.backend(a, b).merge(st)
+		}
 	}
-	// Calculate stats
-	took := time.Since(start)
-	tookNS := took.Nanoseconds()
-	var averageNS int64
-	if conns != 0 {
-		averageNS = tookNS / conns
+	return t
+}
+
+// Statistics of a backend
+func (acc *accum) backend(a *attack, addr string) *Stats {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+	st := acc.backends[addr]
+	if st == nil {
+		st = a.newStats()
+		acc.backends[addr] = st
 	}
-	average, err := time.ParseDuration(fmt.Sprintf("%d", averageNS) + "ns")
-	if err != nil {
-		log.Println(err)
+	return st
+}
+
+// Empty statistics
+func (a *attack) newStats() *Stats {
+	if a.cfg.Bounded {
+		return NewBoundedStats()
 	}
-	sizeHuman := byteSize(float64(size))
-	fmt.Printf("Replies:\t%d\nTotal size:\t%s\nTotal time:\t%s\nAverage time:\t%s\n\n", conns, sizeHuman, took, average)
+	return NewStats()
+}
+
+// Empty statistics for a shard
+func (a *attack) newAccum() *accum {
+	acc := &accum{st: a.newStats(), phases: make([]*Stats, len(a.cfg.Phases)), backends: make(map[string]*Stats)}
+	for i := range acc.phases {
+		acc.phases[i] = a.newStats()
+	}
+	if len(a.named) > 1 {
+		acc.targets = make([]*Stats, len(a.named))
+		for i := range acc.targets {
+			acc.targets[i] = a.newStats()
+		}
+	}
+	if len(a.cfg.Methods) > 0 {
+		acc.methods = make([]*Stats, len(a.cfg.Methods))
+		for i := range acc.methods {
+			acc.methods[i] = a.newStats()
+		}
+	}
+	return acc
+}
+
+// Run the attack
+func (a *attack) run(ctx context.Context) (Results, error) {
+	actx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	a.cancel = cancel
+	// Requests are made in a context counting their connections, but not
+	// those of prewarming
+	if err := a.prototypes(httptrace.WithClientTrace(actx, a.conns.trace())); err != nil {
+		return Results{}, err
+	}
+	a.log.Debug("attack started", "url", a.cfg.URL, "requests", a.cfg.Requests, "concurrent", a.cfg.Concurrent, "seed", a.seed)
+	reqChan := make(chan request)
+	resChan := make(chan []Result)
+	ts := a.transports()
+	a.shards = make([]*accum, len(ts))
+	for i := range a.shards {
+		a.shards[i] = a.newAccum()
+	}
+	a.live.Store(&a.shards)
+	if a.cfg.Prewarm {
+		a.prewarm(actx, ts)
+		a.warmed = a.dial.opened()
+	}
+	mon := startMonitor()
+	a.start = time.Now()
+	if a.cfg.Breaker != nil {
+		a.breaker = &breaker{cfg: *a.cfg.Breaker, start: a.start}
+	}
+	if end := a.end(); end > 0 {
+		if drain, ok := a.drain(); ok {
+			abort := time.AfterFunc(end+drain, func() {
+				if end == a.cfg.MaxDuration {
+					a.stop(StopMaxDuration)
+				}
+				a.log.Warn("drain timed out, cancelling requests in flight", "after", end, "drain_timeout", drain)
+				cancel()
+			})
+			defer abort.Stop()
+		}
+	}
+	go a.dispatcher(actx, reqChan)
+	go a.workerPool(actx, ts, reqChan, resChan)
+	a.consumer(resChan)
+	took := time.Since(a.start)
+	a.log.Debug("attack finished", "took", took)
+	t := a.total()
+	res := t.st.Summary(a.cfg.URL, took)
+	res.Tags = a.cfg.Tags
+	res.Seed = a.seed
+	if p := a.stopped.Load(); p != nil {
+		res.Stopped = *p
+	}
+	res.Aborted = a.aborted.Load()
+	if res.Aborted > 0 {
+		a.log.Warn("requests were cancelled in flight, and aren't in the results", "aborted", res.Aborted)
+	}
+	if a.breaker != nil {
+		res.Pauses = a.breaker.result(took)
+	}
+	res.Connections = a.dial.conns()
+	res.DNS = a.dial.dns()
+	res.Conns = a.conns.stats(a.dial.opened() - a.warmed)
+	res.Certs = a.conns.certs.result()
+	for _, ch := range res.Certs {
+		for _, c := range ch.Chain {
+			if c.Expiring {
+				a.log.Warn("certificate expires soon", "host", ch.Host, "subject", c.Subject, "not_after", c.NotAfter)
+			}
+		}
+	}
+	res.Client = mon.finish()
+	a.sched.annotate(res.Client)
+	if res.Client.Bottleneck != "" {
+		a.log.Warn("the load generator, not the target, may have limited the results", "reason", res.Client.Bottleneck)
+	}
+	for i, p := range a.cfg.Phases {
+		end := p.End
+		if end == 0 || end > took {
+			end = took
+		}
+		ps := t.phases[i].Summary(a.cfg.URL, end-p.Start)
+		ps.Histogram, ps.Timeline = nil, nil
+		res.Phases = append(res.Phases, PhaseResults{p.Name, ps})
+	}
+	for i, st := range t.targets {
+		tg := a.named[i]
+		ts := st.Summary(tg.URL, took)
+		ts.Histogram, ts.Timeline = nil, nil
+		res.Targets = append(res.Targets, TargetResults{tg.Name, ts})
+	}
+	for i, st := range t.methods {
+		ms := st.Summary(a.cfg.URL, took)
+		ms.Histogram, ms.Timeline = nil, nil
+		res.Methods = append(res.Methods, MethodResults{a.cfg.Methods[i].Method, ms})
+	}
+	for b, st := range t.backends {
+		bs := st.Summary(a.cfg.URL, took)
+		bs.Histogram, bs.Timeline = nil, nil
+		res.Backends = append(res.Backends, BackendResults{Backend: b, Results: bs})
+	}
+	sort.Slice(res.Backends, func(i, j int) bool { return res.Backends[i].Backend < res.Backends[j].Backend })
+	markOutliers(res.Backends)
+	for _, b := range res.Backends {
+		if b.Outlier != "" {
+			a.log.Warn("backend stands out from the others", "backend", b.Backend, "reason", b.Outlier)
+		}
+	}
+	return res, ctx.Err()
+}
+
+// Attack runs an attack with cfg, see Attacker.Attack
+func Attack(ctx context.Context, cfg Config) (Results, error) {
+	return NewAttacker(WithConfig(cfg)).Attack(ctx)
 }
@@ -0,0 +1,162 @@
+package tensile
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Logger discarding the load generator warnings of benchmarks
+var quiet = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// Serve a body of a declared length, to every method
+func okServer(t testing.TB) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "2")
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHeadNotTruncated(t *testing.T) {
+	srv := okServer(t)
+	res, err := NewAttacker(WithURL(srv.URL), WithMethod(http.MethodHead), WithRequests(10), WithConcurrency(2)).Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Errors != 0 || len(res.Checks) != 0 {
+		t.Fatalf("HEAD requests failed: %d errors, checks %v", res.Errors, res.Checks)
+	}
+	if res.Replies != 10 {
+		t.Fatalf("got %d replies, want 10", res.Replies)
+	}
+}
+
+func TestMaxDurationStops(t *testing.T) {
+	srv := okServer(t)
+	res, err := NewAttacker(WithURL(srv.URL), WithRequests(1<<30), WithConcurrency(2), WithMaxDuration(50*time.Millisecond)).Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stopped != StopMaxDuration {
+		t.Fatalf("stopped %q, want %q", res.Stopped, StopMaxDuration)
+	}
+	if res.Aborted != 0 {
+		t.Fatalf("%d requests aborted, want none to outlast the drain", res.Aborted)
+	}
+}
+
+func TestSlowResultsFlushed(t *testing.T) {
+	release := make(chan struct{})
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1) > 2 {
+			<-release
+		} else {
+			time.Sleep(resultFlush + 10*time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	results := make(chan Result, 8)
+	go NewAttacker(WithURL(srv.URL), WithRequests(3), WithConcurrency(1), WithResults(results)).Attack(context.Background())
+	defer close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-results:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("result %d held back while a slow request is in flight", i+1)
+		}
+	}
+}
+
+// Stream the results of b.N requests, as tensile attack -record does
+func BenchmarkAttack(b *testing.B) {
+	srv := okServer(b)
+	results := make(chan Result, 1024)
+	go func() {
+		for range results {
+		}
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+	if _, err := NewAttacker(WithURL(srv.URL), WithRequests(b.N), WithConcurrency(8), WithResults(results), WithLogger(quiet)).Attack(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// Attack with the prototype request of a target with a few headers
+func protoAttack(tb testing.TB) *attack {
+	a := &attack{cfg: Config{Method: http.MethodGet, Targets: []Target{{
+		URL:    "http://example.com/path?q=1",
+		Header: http.Header{"Accept": {"*/*"}, "Authorization": {"Bearer x"}, "X-Trace": {"1"}},
+	}}}}
+	if err := a.prototypes(context.Background()); err != nil {
+		tb.Fatal(err)
+	}
+	return a
+}
+
+// Build and release a request and response as the hot path does, pooled or
+// not
+func pooledRequest(a *attack) {
+	req := a.newRequest(0)
+	r := responses.Get().(*response)
+	a.recycle(req, r)
+	*r = response{}
+	responses.Put(r)
+}
+
+var sink any
+
+func clonedRequest(a *attack) {
+	p := a.protos[0]
+	sink = p.Clone(p.Context())
+	sink = new(response)
+}
+
+func TestRequestPooling(t *testing.T) {
+	a := protoAttack(t)
+	pooled := testing.AllocsPerRun(100, func() { pooledRequest(a) })
+	cloned := testing.AllocsPerRun(100, func() { clonedRequest(a) })
+	if pooled >= cloned {
+		t.Fatalf("pooled requests take %v allocations, cloned %v", pooled, cloned)
+	}
+}
+
+func BenchmarkRequest(b *testing.B) {
+	a := protoAttack(b)
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pooledRequest(a)
+		}
+	})
+	b.Run("cloned", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			clonedRequest(a)
+		}
+	})
+}
+
+func TestTransportShards(t *testing.T) {
+	srv := okServer(t)
+	a := NewAttacker(WithURL(srv.URL), WithRequests(20), WithConcurrency(4), WithTransportShards(2))
+	if n := a.Config().TransportShards; n != 2 {
+		t.Fatalf("WithTransportShards set %d", n)
+	}
+	res, err := a.Attack(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Errors != 0 || res.Replies != 20 {
+		t.Fatalf("got %d replies and %d errors, want 20 replies", res.Replies, res.Errors)
+	}
+}
@@ -0,0 +1,99 @@
+package tensile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Threshold is a pass/fail condition on a summary metric, e.g. p99<200ms
+type Threshold struct {
+	Expr   string
+	metric string
+	less   bool
+	value  float64
+}
+
+// ParseThresholds parses a comma separated list of thresholds. Metrics are
+// min, mean, max, the percentiles, rps and errors, either as a count or a
+// percentage of requests.
+func ParseThresholds(s string) ([]Threshold, error) {
+	var ts []Threshold
+	if s == "" {
+		return ts, nil
+	}
+	for _, expr := range strings.Split(s, ",") {
+		expr = strings.TrimSpace(expr)
+		i := strings.IndexAny(expr, "<>")
+		if i < 1 {
+			return nil, fmt.Errorf("tensile: invalid threshold %q", expr)
+		}
+		t := Threshold{Expr: expr, metric: expr[:i], less: expr[i] == '<'}
+		v := expr[i+1:]
+		var err error
+		switch {
+		case t.metric == "errors" && strings.HasSuffix(v, "%"):
+			t.metric = "errors%"
+			t.value, err = strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		case t.metric == "errors" || t.metric == "rps":
+			t.value, err = strconv.ParseFloat(v, 64)
+		case isLatencyMetric(t.metric):
+			var d time.Duration
+			d, err = time.ParseDuration(v)
+			t.value = float64(d)
+		default:
+			err = fmt.Errorf("unknown metric")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tensile: invalid threshold %q", expr)
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// Latency metrics: min, mean, max and the reported percentiles
+func isLatencyMetric(m string) bool {
+	switch m {
+	case "min", "mean", "max":
+		return true
+	}
+	for _, p := range Percentiles {
+		if m == PercentileName(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value of the threshold metric in the summary, and its printable form
+func (t Threshold) actual(sum Results) (float64, string) {
+	switch t.metric {
+	case "errors%":
+		return sum.ErrorRate * 100, fmt.Sprintf("%.2f%%", sum.ErrorRate*100)
+	case "errors":
+		return float64(sum.Errors), fmt.Sprintf("%d", sum.Errors)
+	case "rps":
+		return sum.Throughput, fmt.Sprintf("%.2f req/s", sum.Throughput)
+	}
+	var d time.Duration
+	switch t.metric {
+	case "min":
+		d = sum.Min
+	case "mean":
+		d = sum.Mean
+	case "max":
+		d = sum.Max
+	default:
+		d = sum.Percentiles[t.metric]
+	}
+	return float64(d), d.String()
+}
+
+// Check the threshold against results, returning whether it passed and the
+// actual value of the metric
+func (t Threshold) Check(r Results) (bool, string) {
+	v, s := t.actual(r)
+	return (t.less && v < t.value) || (!t.less && v > t.value), s
+}
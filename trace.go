@@ -0,0 +1,131 @@
+package tensile
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnStats counts how requests got their connections, to quantify the
+// effect of keep-alive and TLS session resumption on the target
+type ConnStats struct {
+	New        int64 `json:"new"`                   // TCP connections opened, other than by prewarming
+	Reused     int64 `json:"reused"`                // Requests sent on a connection already used, or prewarmed
+	TLSFull    int64 `json:"tls_full,omitempty"`    // Full TLS handshakes
+	TLSResumed int64 `json:"tls_resumed,omitempty"` // TLS handshakes resuming a session
+}
+
+// ReuseRatio is the ratio of requests sent on kept-alive connections
+func (c ConnStats) ReuseRatio() float64 {
+	if n := c.New + c.Reused; n > 0 {
+		return float64(c.Reused) / float64(n)
+	}
+	return 0
+}
+
+// Counts of connections got and TLS handshakes, over every request of an
+// attack
+type connCounter struct {
+	got, full, resumed atomic.Int64
+	certs              certCapture
+}
+
+// Trace counting the connections of every request in its context
+func (c *connCounter) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { c.got.Add(1) },
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			switch {
+			case err != nil:
+			case cs.DidResume:
+				c.resumed.Add(1)
+			default:
+				c.full.Add(1)
+				c.certs.add(cs)
+			}
+		},
+	}
+}
+
+// The counts, given the connections opened. Whether HTTP/2 streams were
+// sent on new connections isn't traced reliably, so requests not given a new
+// connection are counted as reusing one.
+func (c *connCounter) stats(opened int64) *ConnStats {
+	return &ConnStats{New: opened, Reused: max(c.got.Load()-opened, 0), TLSFull: c.full.Load(), TLSResumed: c.resumed.Load()}
+}
+
+// Timings of the phases of a request, from an httptrace.ClientTrace. Phases
+// that didn't happen, e.g. DNS on a reused connection, are 0.
+type timings struct {
+	mu                               sync.Mutex
+	start                            time.Time
+	dnsStart, connectStart, tlsStart time.Time
+	dns, connect, tls, ttfb          time.Duration
+	reused                           bool
+}
+
+// Trace a request, recording its timings in t
+func (t *timings) trace(req *http.Request) *http.Request {
+	t.start = time.Now()
+	ct := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dns = time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) { t.set(&t.connectStart) },
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			t.connect = time.Since(t.connectStart)
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.tls = time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotConn: func(i httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = i.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.ttfb = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), ct))
+}
+
+// Set a start time
+func (t *timings) set(at *time.Time) {
+	t.mu.Lock()
+	*at = time.Now()
+	t.mu.Unlock()
+}
+
+// Log attributes of the timings
+func (t *timings) attrs() []any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return []any{"dns", t.dns, "connect", t.connect, "tls", t.tls, "ttfb", t.ttfb, "reused", t.reused}
+}
+
+// Log a request slower than cfg.SlowLog with its timings and response headers
+func (a *attack) logSlow(req *http.Request, r *response, t *timings) {
+	args := []any{"url", req.URL.String(), "latency", r.latency}
+	args = append(args, t.attrs()...)
+	if r.err != nil {
+		args = append(args, "err", r.err)
+	} else {
+		args = append(args, "status", r.Status, slog.Any("headers", r.Header))
+	}
+	a.log.Warn("slow request", args...)
+}
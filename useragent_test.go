@@ -0,0 +1,33 @@
+package tensile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestUserAgents(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		uas = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		uas[r.UserAgent()]++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	a := NewAttacker(WithURL(srv.URL), WithRequests(8), WithConcurrency(2), WithUserAgents("a", "b"))
+	if got := a.Config().UserAgents; !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("WithUserAgents set %v", got)
+	}
+	if _, err := a.Attack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if uas["a"] != 4 || uas["b"] != 4 {
+		t.Errorf("User-Agents sent %v, want 4 each of a and b", uas)
+	}
+}
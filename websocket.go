@@ -0,0 +1,223 @@
+package tensile
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+const (
+	// GUID appended to the key of a WebSocket handshake, from RFC 6455
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// Largest message read
+	wsMaxMessage = 16 << 20
+)
+
+var errWSClosed = errors.New("tensile: websocket closed by server")
+
+// Minimal RFC 6455 WebSocket client connection, without extensions
+type wsConn struct {
+	c  net.Conn
+	br *bufio.Reader
+	mu sync.Mutex // Serialises writes
+}
+
+// Open a connection to the host of a URL, with TLS for wss and https
+func dialURL(ctx context.Context, u *url.URL) (net.Conn, error) {
+	secure := u.Scheme == "wss" || u.Scheme == "https"
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+		if secure {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	}
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if secure {
+		tc := tls.Client(c, &tls.Config{ServerName: u.Hostname()})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			c.Close()
+			return nil, err
+		}
+		c = tc
+	}
+	return c, nil
+}
+
+// Open a WebSocket connection to a ws, wss, http or https URL
+func dialWS(ctx context.Context, rawURL string) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	c, err := dialURL(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		c.SetDeadline(dl)
+	}
+	ws := &wsConn{c: c, br: bufio.NewReader(c)}
+	if err := ws.handshake(u); err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.SetDeadline(time.Time{})
+	return ws, nil
+}
+
+// Upgrade the connection to a WebSocket
+func (ws *wsConn) handshake(u *url.URL) error {
+	var k [16]byte
+	rand.Read(k[:])
+	key := base64.StdEncoding.EncodeToString(k[:])
+	hu := *u
+	hu.Scheme = "http"
+	req, err := http.NewRequest("GET", hu.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", App+Version)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(ws.c); err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(ws.br, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("tensile: websocket handshake failed: %s", resp.Status)
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return errors.New("tensile: websocket handshake failed: bad Sec-WebSocket-Accept")
+	}
+	return nil
+}
+
+// Write a masked frame
+func (ws *wsConn) writeFrame(op byte, p []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	hdr := make([]byte, 2, 14)
+	hdr[0] = 0x80 | op
+	switch n := len(p); {
+	case n < 126:
+		hdr[1] = 0x80 | byte(n)
+	case n <= 0xffff:
+		hdr[1] = 0x80 | 126
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(n))
+	default:
+		hdr[1] = 0x80 | 127
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	var mask [4]byte
+	rand.Read(mask[:])
+	hdr = append(hdr, mask[:]...)
+	masked := make([]byte, len(p))
+	for i, b := range p {
+		masked[i] = b ^ mask[i%4]
+	}
+	if _, err := ws.c.Write(hdr); err != nil {
+		return err
+	}
+	_, err := ws.c.Write(masked)
+	return err
+}
+
+// Read a whole data message, answering pings and reassembling fragments
+func (ws *wsConn) readMessage() ([]byte, error) {
+	var msg []byte
+	for {
+		var hdr [2]byte
+		if _, err := io.ReadFull(ws.br, hdr[:]); err != nil {
+			return nil, err
+		}
+		fin, op := hdr[0]&0x80 != 0, hdr[0]&0x0f
+		n := uint64(hdr[1] & 0x7f)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(ws.br, b[:]); err != nil {
+				return nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(ws.br, b[:]); err != nil {
+				return nil, err
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		var mask [4]byte
+		if hdr[1]&0x80 != 0 {
+			if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+				return nil, err
+			}
+		}
+		if n > wsMaxMessage || uint64(len(msg))+n > wsMaxMessage {
+			return nil, fmt.Errorf("tensile: websocket message over %d bytes", wsMaxMessage)
+		}
+		p := make([]byte, n)
+		if _, err := io.ReadFull(ws.br, p); err != nil {
+			return nil, err
+		}
+		if hdr[1]&0x80 != 0 {
+			for i := range p {
+				p[i] ^= mask[i%4]
+			}
+		}
+		switch op {
+		case wsPing:
+			if err := ws.writeFrame(wsPong, p); err != nil {
+				return nil, err
+			}
+		case wsPong:
+		case wsClose:
+			ws.writeFrame(wsClose, p)
+			return nil, errWSClosed
+		case wsText, wsBinary, wsContinuation:
+			msg = append(msg, p...)
+			if fin {
+				return msg, nil
+			}
+		}
+	}
+}
+
+// Close the connection, with a close frame
+func (ws *wsConn) close() error {
+	ws.writeFrame(wsClose, []byte{0x03, 0xe8}) // 1000, normal closure
+	return ws.c.Close()
+}
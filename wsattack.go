@@ -0,0 +1,206 @@
+package tensile
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	ErrConnections = errors.New("tensile: Connections must be greater than 0")
+	ErrDuration    = errors.New("tensile: Duration must be greater than 0")
+)
+
+// WSConfig of a WebSocket attack. Each connection sends Message at Rate per
+// second, or each time the previous message is answered if Rate is 0. The
+// target is expected to answer every message, e.g. by echoing it, and the
+// round trip time of a message is the time until the next answer.
+type WSConfig struct {
+	URL         string // ws or wss URL
+	Connections int
+	Message     []byte
+	Rate        float64
+	Duration    time.Duration
+	Timeout     time.Duration // Connect timeout, or 10 seconds if 0
+	Logger      *slog.Logger
+}
+
+// WSResults of a WebSocket attack
+type WSResults struct {
+	URL         string        `json:"url"`
+	Connections int           `json:"connections"`
+	Connected   int64         `json:"connected"`
+	Failed      int64         `json:"failed"`  // Connections that couldn't be opened
+	Dropped     int64         `json:"dropped"` // Connections closed before the end
+	Sent        int64         `json:"sent"`
+	Received    int64         `json:"received"`
+	Duration    time.Duration `json:"duration_ns"`
+	Connect     Metric        `json:"connect"` // Time to open connections
+	RTT         Metric        `json:"rtt"`     // Message round trip time
+}
+
+// State of a WebSocket attack
+type wsAttack struct {
+	cfg WSConfig
+	log *slog.Logger
+
+	mu      sync.Mutex
+	res     WSResults
+	connect metricStats
+	rtt     metricStats
+}
+
+// AttackWS runs a WebSocket attack, holding cfg.Connections open for
+// cfg.Duration
+func AttackWS(ctx context.Context, cfg WSConfig) (WSResults, error) {
+	switch {
+	case cfg.Connections <= 0:
+		return WSResults{}, ErrConnections
+	case cfg.Duration <= 0:
+		return WSResults{}, ErrDuration
+	case cfg.Rate < 0:
+		return WSResults{}, ErrRate
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	a := &wsAttack{
+		cfg:     cfg,
+		log:     cfg.Logger,
+		res:     WSResults{URL: cfg.URL, Connections: cfg.Connections},
+		connect: metricStats{hist: NewHistogram()},
+		rtt:     metricStats{hist: NewHistogram()},
+	}
+	if a.log == nil {
+		a.log = slog.Default()
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.conn(ctx)
+		}()
+	}
+	wg.Wait()
+	a.res.Duration = time.Since(start)
+	a.res.Connect, a.res.RTT = a.connect.summary(), a.rtt.summary()
+	a.res.Connect.Histogram, a.res.RTT.Histogram = nil, nil
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return a.res, nil
+	}
+	return a.res, ctx.Err()
+}
+
+// Open a connection and exchange messages on it until ctx is done
+func (a *wsAttack) conn(ctx context.Context) {
+	start := time.Now()
+	dctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+	ws, err := dialWS(dctx, a.cfg.URL)
+	cancel()
+	a.mu.Lock()
+	if err != nil {
+		a.res.Failed++
+		a.mu.Unlock()
+		if ctx.Err() == nil {
+			a.log.Error("websocket connect failed", "err", err)
+		}
+		return
+	}
+	a.res.Connected++
+	a.connect.add(time.Since(start))
+	a.mu.Unlock()
+
+	// Send times of messages awaiting an answer, oldest first
+	var pmu sync.Mutex
+	var pending []time.Time
+	answered := make(chan struct{}, 1)
+	done := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := ws.readMessage(); err != nil {
+				done <- err
+				return
+			}
+			now := time.Now()
+			pmu.Lock()
+			var sent time.Time
+			if len(pending) > 0 {
+				sent, pending = pending[0], pending[1:]
+			}
+			pmu.Unlock()
+			a.mu.Lock()
+			a.res.Received++
+			if !sent.IsZero() {
+				a.rtt.add(now.Sub(sent))
+			}
+			a.mu.Unlock()
+			select {
+			case answered <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	var tick <-chan time.Time
+	if a.cfg.Rate > 0 {
+		t := time.NewTicker(time.Duration(float64(time.Second) / a.cfg.Rate))
+		defer t.Stop()
+		tick = t.C
+	}
+	send := func() error {
+		pmu.Lock()
+		pending = append(pending, time.Now())
+		pmu.Unlock()
+		if err := ws.writeFrame(wsText, a.cfg.Message); err != nil {
+			return err
+		}
+		a.mu.Lock()
+		a.res.Sent++
+		a.mu.Unlock()
+		return nil
+	}
+	if tick == nil {
+		if err := send(); err != nil {
+			a.drop(ws, err)
+			return
+		}
+	}
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			ws.close()
+			<-done
+			return
+		case err = <-done:
+			a.drop(ws, err)
+			return
+		case <-tick:
+			err = send()
+		case <-answered:
+			if tick == nil {
+				err = send()
+			}
+		}
+		if err != nil {
+			a.drop(ws, err)
+			<-done
+			return
+		}
+	}
+}
+
+// Count a connection dropped with err
+func (a *wsAttack) drop(ws *wsConn, err error) {
+	ws.c.Close()
+	a.mu.Lock()
+	a.res.Dropped++
+	a.mu.Unlock()
+	a.log.Error("websocket connection dropped", "err", err)
+}